@@ -2,8 +2,10 @@ package main
 
 import (
 	"golang/app"
+	eventsHub "golang/events_hub"
 	"golang/handlers"
 	htmlRender "golang/html_render"
+	tcpClient "golang/tcp_client"
 	"log"
 	"net/http"
 	"os"
@@ -32,11 +34,23 @@ func main() {
 		tmpl.Render(w, "index", map[string]interface{}{})
 	})
 
-	h := handlers.New(env, tmpl)
+	// SERVER_ADDR overrides the TCP address, e.g. unix:///run/index/server.sock
+	// when the server shares the host.
+	serverAddr := os.Getenv("SERVER_ADDR")
+	if serverAddr == "" {
+		serverAddr = tcpClient.GetConnPath(8080, env)
+	}
+
+	hub := eventsHub.New(serverAddr)
+	go hub.Run()
+
+	h := handlers.New(serverAddr, tmpl, hub)
 	mux.HandleFunc("/search", h.Search())
 	mux.HandleFunc("/download", h.Download())
 	mux.HandleFunc("/add-file", h.AddFile())
 	mux.HandleFunc("/remove-file", h.RemoveFile())
+	mux.HandleFunc("/upload", h.Upload())
+	mux.HandleFunc("/events", h.Events())
 
 	handler := Logging(mux)
 
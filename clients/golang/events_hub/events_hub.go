@@ -0,0 +1,116 @@
+package eventsHub
+
+import (
+	"errors"
+	"fmt"
+	tcpClient "golang/tcp_client"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+
+	// clientBuffer is how many events a slow browser may fall behind
+	// before it starts losing them.
+	clientBuffer = 16
+)
+
+// Hub keeps a single event subscription to the server and fans the events
+// out to every browser listening on it, so the number of open tabs does not
+// count against the server's subscriber limit. The subscription is
+// reopened with a growing backoff whenever it breaks.
+type Hub struct {
+	serverAddr string
+	clients    map[chan []byte]struct{}
+	lock       sync.Mutex
+}
+
+func New(serverAddr string) *Hub {
+	return &Hub{
+		serverAddr: serverAddr,
+		clients:    make(map[chan []byte]struct{}),
+	}
+}
+
+// Run subscribes to the server and never returns.
+func (hub *Hub) Run() {
+	backoff := minBackoff
+	for {
+		received, err := hub.subscribe()
+		if received {
+			backoff = minBackoff
+		}
+
+		log.Printf("events subscription ended: %v, retrying in %v", err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// subscribe forwards events until the subscription ends and reports
+// whether any arrived, so a long-lived subscription resets the backoff.
+func (hub *Hub) subscribe() (bool, error) {
+	client, err := tcpClient.Dial(hub.serverAddr)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+
+	it, err := client.Stream(&tcpClient.Request{
+		RequestMeta: tcpClient.RequestMeta{
+			Path:   "/events/subscribe",
+			Method: "GET",
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	received := false
+	for it.Next() {
+		response := it.Response()
+		if !response.Status.IsSuccess() {
+			return received, fmt.Errorf("server answered %v: %s", response.Status, response.Body)
+		}
+
+		received = true
+		hub.broadcast(response.Body)
+	}
+
+	if err = it.Err(); err != nil {
+		return received, err
+	}
+
+	return received, errors.New("server closed the subscription")
+}
+
+// Subscribe registers a browser. Events are JSON encoded, the channel is
+// never closed, the returned function unregisters it.
+func (hub *Hub) Subscribe() (<-chan []byte, func()) {
+	events := make(chan []byte, clientBuffer)
+
+	hub.lock.Lock()
+	hub.clients[events] = struct{}{}
+	hub.lock.Unlock()
+
+	return events, func() {
+		hub.lock.Lock()
+		delete(hub.clients, events)
+		hub.lock.Unlock()
+	}
+}
+
+func (hub *Hub) broadcast(event []byte) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	for events := range hub.clients {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
@@ -3,3 +3,5 @@ module golang
 go 1.22
 
 require github.com/ArtemLymarenko/parallel-course-work/pkg v0.0.0-20241229151828-8949c2905e16
+
+replace github.com/ArtemLymarenko/parallel-course-work/pkg => ../../pkg
@@ -28,15 +28,21 @@ func (h *Handlers) Download() func(w http.ResponseWriter, r *http.Request) {
 			},
 		}
 
-		data, err := tcpClient.Fetch(req, 8080, h.env)
+		data, err := tcpClient.Fetch(req, h.serverAddr)
 		var response tcpClient.Response
 		err = json.Unmarshal(data, &response)
-		if err != nil || response.Status != tcpClient.StatusOK {
+		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
 
+		if !response.Status.IsSuccess() {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(response.Status.String()))
+			return
+		}
+
 		var fileResponse GetFileResponse
 		err = json.Unmarshal(response.Body, &fileResponse)
 		if err != nil {
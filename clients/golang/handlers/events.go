@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Events streams index events to the browser as server-sent events.
+func (h *Handlers) Events() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		events, unsubscribe := h.events.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-events:
+				if _, err := fmt.Fprintf(w, "data: %s\n\n", event); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
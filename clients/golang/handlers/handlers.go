@@ -1,18 +1,23 @@
 package handlers
 
-import (
-	"golang/app"
-	htmlRender "golang/html_render"
-)
+import htmlRender "golang/html_render"
+
+type EventsHub interface {
+	Subscribe() (<-chan []byte, func())
+}
 
 type Handlers struct {
-	env  app.Env
-	tmpl *htmlRender.Templates
+	serverAddr string
+	tmpl       *htmlRender.Templates
+	events     EventsHub
 }
 
-func New(env app.Env, tmpl *htmlRender.Templates) *Handlers {
+// New creates the handlers for the server at serverAddr, either host:port
+// or a unix:// socket path.
+func New(serverAddr string, tmpl *htmlRender.Templates, events EventsHub) *Handlers {
 	return &Handlers{
-		env:  env,
-		tmpl: tmpl,
+		serverAddr: serverAddr,
+		tmpl:       tmpl,
+		events:     events,
 	}
 }
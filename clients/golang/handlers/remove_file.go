@@ -24,7 +24,7 @@ func (h *Handlers) RemoveFile() func(w http.ResponseWriter, r *http.Request) {
 			},
 		}
 
-		data, err := tcpClient.Fetch(req, 8080, h.env)
+		data, err := tcpClient.Fetch(req, h.serverAddr)
 		var response tcpClient.Response
 		err = json.Unmarshal(data, &response)
 		if err != nil {
@@ -1,9 +1,11 @@
 package handlers
 
 import (
-	"encoding/json"
 	tcpClient "golang/tcp_client"
 	"net/http"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/binenc"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
 )
 
 type SearchRequestDto struct {
@@ -14,6 +16,21 @@ type SearchResponse struct {
 	Files []string `json:"files"`
 }
 
+// Search is the hottest route, so it talks to the server in the binary
+// encoding, the schema mirrors the server's dto package.
+
+func (request SearchRequestDto) MarshalBinary() ([]byte, error) {
+	writer := binenc.NewWriter(len(request.Query) + 4)
+	writer.WriteString(request.Query)
+	return writer.Data(), nil
+}
+
+func (response *SearchResponse) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	response.Files = reader.ReadStrings()
+	return reader.Close()
+}
+
 func (h *Handlers) Search() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		query := r.FormValue("search")
@@ -32,19 +49,24 @@ func (h *Handlers) Search() func(w http.ResponseWriter, r *http.Request) {
 			Body: SearchRequestDto{
 				Query: query,
 			},
+			ContentType: streamer.ContentBinary,
 		}
 
-		data, err := tcpClient.Fetch(req, 8080, h.env)
-		var response tcpClient.Response
-		err = json.Unmarshal(data, &response)
+		response, err := tcpClient.FetchResponse(req, h.serverAddr)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
 
+		if !response.Status.IsSuccess() {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(response.Status.String()))
+			return
+		}
+
 		var searchResponse SearchResponse
-		err = json.Unmarshal(response.Body, &searchResponse)
+		err = response.DecodeBody(&searchResponse)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte(err.Error()))
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	tcpClient "golang/tcp_client"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// uploadChunkSize is how much of a document one upload request carries.
+	uploadChunkSize = 256 << 10
+	maxUploadMemory = 8 << 20
+	// uploadChunkTimeout bounds waiting for the answer to one chunk.
+	uploadChunkTimeout = 30 * time.Second
+)
+
+var errUploadNotText = errors.New("document is not UTF-8 text")
+
+type UploadRequestDto struct {
+	UploadId  string `json:"uploadId,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Content   string `json:"content"`
+	Offset    int64  `json:"offset,omitempty"`
+	Final     bool   `json:"final,omitempty"`
+	Overwrite bool   `json:"overwrite,omitempty"`
+}
+
+type UploadResponseDto struct {
+	UploadId string `json:"uploadId"`
+	Size     int64  `json:"size"`
+	FileName string `json:"fileName,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Upload sends the chosen document to the server in chunks over one
+// connection, the server stores and indexes it after the last one.
+func (h *Handlers) Upload() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		file, header, err := r.FormFile("document")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		defer file.Close()
+
+		name := r.FormValue("document-name")
+		if name == "" {
+			name = filepath.Base(header.Filename)
+		}
+
+		client, err := tcpClient.Dial(h.serverAddr)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		defer client.Close()
+
+		response, result, err := uploadChunks(r.Context(), client, file, UploadRequestDto{
+			Name:      name,
+			Overwrite: r.FormValue("overwrite") != "",
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		message := result.Message
+		if response.Status.IsSuccess() {
+			message = "Uploaded " + result.FileName
+		}
+
+		render := struct {
+			StatusCode    string
+			StatusMessage string
+		}{
+			StatusCode:    response.Status.String(),
+			StatusMessage: message,
+		}
+
+		_ = h.tmpl.Render(w, "status", render)
+	}
+}
+
+// uploadChunks sends the content of reader chunk by chunk and returns the
+// response to the last chunk sent, either the final one or the first one
+// the server refused. Chunks end on whole characters, since the content
+// travels as a JSON string.
+func uploadChunks(
+	ctx context.Context,
+	client *tcpClient.Client,
+	reader io.Reader,
+	body UploadRequestDto,
+) (*tcpClient.Response, UploadResponseDto, error) {
+	buffer := make([]byte, uploadChunkSize)
+	pending := 0
+
+	for {
+		n, err := io.ReadFull(reader, buffer[pending:uploadChunkSize])
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, UploadResponseDto{}, err
+		}
+
+		size := pending + n
+		body.Final = size < uploadChunkSize
+
+		chunk := size
+		if !body.Final {
+			chunk = lastRuneBoundary(buffer[:size])
+		}
+		if !utf8.Valid(buffer[:chunk]) {
+			return nil, UploadResponseDto{}, errUploadNotText
+		}
+		body.Content = string(buffer[:chunk])
+
+		chunkCtx, cancel := context.WithTimeout(ctx, uploadChunkTimeout)
+		response, err := client.Do(chunkCtx, &tcpClient.Request{
+			RequestMeta: tcpClient.RequestMeta{
+				Path:   "/index/upload",
+				Method: "POST",
+			},
+			Body: body,
+		})
+		cancel()
+		if err != nil {
+			return nil, UploadResponseDto{}, err
+		}
+
+		var result UploadResponseDto
+		if err = response.DecodeBody(&result); err != nil {
+			return nil, UploadResponseDto{}, err
+		}
+
+		if body.Final || !response.Status.IsSuccess() {
+			return response, result, nil
+		}
+
+		body.UploadId = result.UploadId
+		body.Offset = result.Size
+		pending = copy(buffer, buffer[chunk:size])
+	}
+}
+
+// lastRuneBoundary returns where the last complete character of data ends.
+func lastRuneBoundary(data []byte) int {
+	for end := len(data); end > 0 && end > len(data)-utf8.UTFMax; end-- {
+		if utf8.RuneStart(data[end-1]) {
+			if utf8.FullRune(data[end-1:]) {
+				return len(data)
+			}
+			return end - 1
+		}
+	}
+
+	return len(data)
+}
@@ -6,8 +6,11 @@ import (
 	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
 	"golang/app"
 	"net"
+	"strings"
 )
 
+const unixScheme = "unix://"
+
 func GetConnPath(port int, env app.Env) string {
 	if env.IsProduction() {
 		return fmt.Sprintf("server-app:%d", port)
@@ -16,14 +19,24 @@ func GetConnPath(port int, env app.Env) string {
 	return fmt.Sprintf("0.0.0.0:%d", port)
 }
 
-func Fetch(request *Request, port int, env app.Env) ([]byte, error) {
-	connPath := GetConnPath(port, env)
-	conn, err := net.Dial("tcp", connPath)
+// dial connects to host:port over TCP, or to a unix domain socket
+// for addresses like unix:///run/index/server.sock.
+func dial(address string) (net.Conn, error) {
+	if path, ok := strings.CutPrefix(address, unixScheme); ok {
+		return net.Dial("unix", path)
+	}
+
+	return net.Dial("tcp", address)
+}
+
+func Fetch(request *Request, address string) ([]byte, error) {
+	conn, err := dial(address)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 
+	request.AcceptEncoding = streamer.SupportedEncodings()
 	requestBin, err := request.MarshalJSONBinary()
 	if err != nil {
 		return nil, errors.New("failed to marshal JSON encode request")
@@ -41,3 +54,31 @@ func Fetch(request *Request, port int, env app.Env) ([]byte, error) {
 
 	return result, nil
 }
+
+// FetchResponse sends a single request like Fetch and decodes the response,
+// whichever encoding the server answered in.
+func FetchResponse(request *Request, address string) (*Response, error) {
+	conn, err := dial(address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	request.AcceptEncoding = streamer.SupportedEncodings()
+	frame, err := request.encode()
+	if err != nil {
+		return nil, errors.New("failed to encode request")
+	}
+
+	err = streamer.WriteFrameCompressed(conn, 2048, frame, streamer.Compression{})
+	if err != nil {
+		return nil, errors.New("failed to send request to server")
+	}
+
+	frame, err = streamer.ReadFrame(conn, streamer.DefaultMaxMessageSize)
+	if err != nil || frame.Kind != streamer.FrameMessage {
+		return nil, errors.New("failed to retrieve result from server")
+	}
+
+	return decodeResponse(frame)
+}
@@ -0,0 +1,43 @@
+package tcpClient
+
+// ResponseIterator walks over the parts of a streamed response:
+//
+//	for it.Next() {
+//		part := it.Response()
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// Every part carries the response status. When the server answers with a
+// single message instead, for example an error, it is the only part.
+type ResponseIterator struct {
+	client  *Client
+	pending *pendingRequest
+	current *Response
+}
+
+// Next waits for the next part and reports false once the stream is over.
+func (it *ResponseIterator) Next() bool {
+	response, ok := <-it.pending.responses
+	it.current = response
+	return ok
+}
+
+func (it *ResponseIterator) Response() *Response {
+	return it.current
+}
+
+// Err returns the error that cut the stream short, it is nil when the
+// stream ended normally. It should be called after Next reports false.
+func (it *ResponseIterator) Err() error {
+	it.client.lock.Lock()
+	defer it.client.lock.Unlock()
+	return it.pending.err
+}
+
+// Close drops the rest of the stream without blocking the connection.
+func (it *ResponseIterator) Close() {
+	go func() {
+		for range it.pending.responses {
+		}
+	}()
+}
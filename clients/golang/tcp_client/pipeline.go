@@ -0,0 +1,288 @@
+package tcpClient
+
+import (
+	"context"
+	"errors"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrClientClosed = errors.New("client connection is closed")
+
+// Client keeps one keep-alive connection to the server and pipelines
+// requests over it. Requests may be sent from many goroutines at once,
+// responses are matched back to their requests by id. Once the server
+// closes the connection, e.g. after it was idle for its AliveTimeout, the
+// next request dials a new one. Requests still waiting on the old
+// connection fail, they are not sent again.
+type Client struct {
+	address   string
+	conn      *pipelineConn
+	lock      sync.Mutex
+	requestId atomic.Int64
+	isClosed  bool
+}
+
+// pipelineConn is one connection of a Client. Its pending requests and err
+// are guarded by the lock of the client.
+type pipelineConn struct {
+	conn       net.Conn
+	writeLock  sync.Mutex
+	negotiated bool
+	pending    map[string]*pendingRequest
+	closed     chan struct{}
+	err        error
+}
+
+func Dial(address string) (*Client, error) {
+	client := &Client{
+		address: address,
+	}
+
+	conn, err := client.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	client.conn = conn
+	return client, nil
+}
+
+func (c *Client) dial() (*pipelineConn, error) {
+	conn, err := dial(c.address)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := &pipelineConn{
+		conn:    conn,
+		pending: make(map[string]*pendingRequest),
+		closed:  make(chan struct{}),
+	}
+
+	go c.readLoop(pipeline)
+	return pipeline, nil
+}
+
+// pendingRequest collects the frames of one request. responses is closed
+// after the last frame, err is set when the connection failed before that.
+type pendingRequest struct {
+	id        string
+	conn      *pipelineConn
+	responses chan *Response
+	err       error
+}
+
+// Send writes the request without waiting for the previous ones to be
+// answered. The returned channel receives exactly one response.
+func (c *Client) Send(request *Request) (<-chan *Response, error) {
+	request.Stream = false
+	pending, err := c.send(request, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return pending.responses, nil
+}
+
+// Stream asks the server to stream the response and returns an iterator
+// over its parts. The iterator has to be read to the end or closed,
+// otherwise it holds up the responses of every other request.
+func (c *Client) Stream(request *Request) (*ResponseIterator, error) {
+	request.Stream = true
+	pending, err := c.send(request, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseIterator{
+		client:  c,
+		pending: pending,
+	}, nil
+}
+
+func (c *Client) send(request *Request, buffer int) (*pendingRequest, error) {
+	if request.Id == "" {
+		request.Id = "c-" + strconv.FormatInt(c.requestId.Add(1), 10)
+	}
+	request.ConnectionAlive = true
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &pendingRequest{
+		id:        request.Id,
+		conn:      conn,
+		responses: make(chan *Response, buffer),
+	}
+
+	c.lock.Lock()
+	if conn.err != nil {
+		c.lock.Unlock()
+		return nil, conn.err
+	}
+	conn.pending[request.Id] = pending
+	c.lock.Unlock()
+
+	if err = conn.write(request); err != nil {
+		c.forget(pending)
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+// connect returns the current connection, or dials a new one when the
+// server has closed it.
+func (c *Client) connect() (*pipelineConn, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.isClosed {
+		return nil, ErrClientClosed
+	}
+
+	if c.conn == nil || c.conn.err != nil {
+		conn, err := c.dial()
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+
+	return c.conn, nil
+}
+
+// write sends the request, the first one written also negotiates
+// response compression for the whole connection.
+func (conn *pipelineConn) write(request *Request) error {
+	conn.writeLock.Lock()
+	defer conn.writeLock.Unlock()
+
+	if !conn.negotiated {
+		request.AcceptEncoding = streamer.SupportedEncodings()
+	}
+
+	frame, err := request.encode()
+	if err != nil {
+		return errors.New("failed to encode request")
+	}
+
+	if err = streamer.WriteFrameCompressed(conn.conn, 2048, frame, streamer.Compression{}); err != nil {
+		return errors.New("failed to send request to server")
+	}
+
+	conn.negotiated = true
+	return nil
+}
+
+// Do sends the request and waits for its response until ctx is done.
+// A response arriving after that is dropped.
+func (c *Client) Do(ctx context.Context, request *Request) (*Response, error) {
+	request.Stream = false
+	pending, err := c.send(request, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case response, ok := <-pending.responses:
+		if !ok {
+			return nil, c.pendingErr(pending)
+		}
+		return response, nil
+	case <-ctx.Done():
+		c.forget(pending)
+		return nil, ctx.Err()
+	}
+}
+
+// Close closes the connection, requests still waiting fail and no new
+// connection is dialed anymore.
+func (c *Client) Close() error {
+	c.lock.Lock()
+	c.isClosed = true
+	conn := c.conn
+	c.lock.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	err := conn.conn.Close()
+	<-conn.closed
+	return err
+}
+
+func (c *Client) readLoop(conn *pipelineConn) {
+	defer close(conn.closed)
+
+	for {
+		frame, err := streamer.ReadFrame(conn.conn, streamer.DefaultMaxMessageSize)
+		if err != nil {
+			c.failPending(conn, err)
+			return
+		}
+
+		response, err := decodeResponse(frame)
+		if err != nil {
+			c.failPending(conn, err)
+			_ = conn.conn.Close()
+			return
+		}
+
+		c.lock.Lock()
+		pending, ok := conn.pending[response.Id]
+		if ok && frame.Kind != streamer.FrameStreamChunk {
+			delete(conn.pending, response.Id)
+		}
+		c.lock.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		// A plain message ends the request even if a stream was asked for,
+		// that is how the server reports errors and routes without streaming.
+		if frame.Kind != streamer.FrameStreamEnd {
+			pending.responses <- response
+		}
+		if frame.Kind != streamer.FrameStreamChunk {
+			close(pending.responses)
+		}
+	}
+}
+
+func (c *Client) forget(pending *pendingRequest) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if pending.conn.pending[pending.id] == pending {
+		delete(pending.conn.pending, pending.id)
+	}
+}
+
+// failPending fails every request waiting on conn, the next request
+// dials a new connection.
+func (c *Client) failPending(conn *pipelineConn, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	conn.err = errors.Join(ErrClientClosed, err)
+	for requestId, pending := range conn.pending {
+		pending.err = conn.err
+		close(pending.responses)
+		delete(conn.pending, requestId)
+	}
+}
+
+func (c *Client) pendingErr(pending *pendingRequest) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return pending.err
+}
@@ -0,0 +1,242 @@
+package tcpClient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startLoopback serves every accepted connection with serve and returns
+// the address to dial and the number of connections accepted so far.
+func startLoopback(t *testing.T, serve func(conn net.Conn)) (string, *atomic.Int64) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	accepted := &atomic.Int64{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), accepted
+}
+
+func readRequest(conn net.Conn) (*Request, error) {
+	frame, err := streamer.ReadFrame(conn, streamer.DefaultMaxMessageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var request Request
+	if err = json.Unmarshal(frame.Payload, &request); err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func writeResponse(conn net.Conn, kind streamer.FrameKind, id string, body any) error {
+	rawBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(Response{Id: id, Status: StatusOK, Body: rawBody})
+	if err != nil {
+		return err
+	}
+
+	frame := streamer.Frame{Kind: kind, ContentType: streamer.ContentJSON, Payload: payload}
+	return streamer.WriteFrameCompressed(conn, 2048, frame, streamer.Compression{})
+}
+
+func dialLoopback(t *testing.T, address string) *Client {
+	t.Helper()
+
+	client, err := Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func decodeString(t *testing.T, response *Response) string {
+	t.Helper()
+
+	var body string
+	if err := response.DecodeBody(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
+
+func TestPipelinedRequestsAnsweredOutOfOrder(t *testing.T) {
+	const count = 3
+
+	// Every request is read before the first one is answered, which only
+	// works when the client does not wait for an answer before sending on.
+	address, _ := startLoopback(t, func(conn net.Conn) {
+		requests := make([]*Request, 0, count)
+		for range count {
+			request, err := readRequest(conn)
+			if err != nil {
+				return
+			}
+			requests = append(requests, request)
+		}
+
+		for i := len(requests) - 1; i >= 0; i-- {
+			if err := writeResponse(conn, streamer.FrameMessage, requests[i].Id, requests[i].RequestMeta.Path); err != nil {
+				return
+			}
+		}
+	})
+	client := dialLoopback(t, address)
+
+	paths := []string{"/first", "/second", "/third"}
+	results := make([]<-chan *Response, len(paths))
+	for i, path := range paths {
+		result, err := client.Send(&Request{RequestMeta: RequestMeta{Path: path, Method: "GET"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results[i] = result
+	}
+
+	for i, result := range results {
+		select {
+		case response := <-result:
+			if body := decodeString(t, response); body != paths[i] {
+				t.Errorf("expected the response to %v, got the one to %v", paths[i], body)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("request %v was not answered", paths[i])
+		}
+	}
+}
+
+func TestStreamIteratorBetweenOtherResponses(t *testing.T) {
+	address, _ := startLoopback(t, func(conn net.Conn) {
+		stream, err := readRequest(conn)
+		if err != nil {
+			return
+		}
+		single, err := readRequest(conn)
+		if err != nil {
+			return
+		}
+
+		_ = writeResponse(conn, streamer.FrameStreamChunk, stream.Id, "a")
+		_ = writeResponse(conn, streamer.FrameMessage, single.Id, "single")
+		_ = writeResponse(conn, streamer.FrameStreamChunk, stream.Id, "b")
+		_ = writeResponse(conn, streamer.FrameStreamEnd, stream.Id, nil)
+	})
+	client := dialLoopback(t, address)
+
+	it, err := client.Stream(&Request{RequestMeta: RequestMeta{Path: "/events", Method: "GET"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := client.Send(&Request{RequestMeta: RequestMeta{Path: "/single", Method: "GET"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parts []string
+	for it.Next() {
+		parts = append(parts, decodeString(t, it.Response()))
+	}
+	if err = it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0] != "a" || parts[1] != "b" {
+		t.Errorf("expected parts a, b, got %v", parts)
+	}
+
+	if response := <-result; decodeString(t, response) != "single" {
+		t.Errorf("expected the single response, got %s", response.Body)
+	}
+}
+
+func TestClientReconnectsAfterServerCloses(t *testing.T) {
+	// The server answers one request per connection, like one that closed
+	// an idle keep-alive connection.
+	address, accepted := startLoopback(t, func(conn net.Conn) {
+		request, err := readRequest(conn)
+		if err != nil {
+			return
+		}
+		_ = writeResponse(conn, streamer.FrameMessage, request.Id, "ok")
+	})
+	client := dialLoopback(t, address)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for i := range 2 {
+		if _, err := client.Do(ctx, &Request{RequestMeta: RequestMeta{Path: "/health", Method: "GET"}}); err != nil {
+			t.Fatalf("request %v: %v", i, err)
+		}
+
+		// Wait for the client to notice the closed connection.
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if accepted.Load() != 2 {
+		t.Errorf("expected the client to dial again once, got %v connections", accepted.Load())
+	}
+}
+
+func TestDoStopsAtContextDeadline(t *testing.T) {
+	address, _ := startLoopback(t, func(conn net.Conn) {
+		for {
+			if _, err := readRequest(conn); err != nil {
+				return
+			}
+		}
+	})
+	client := dialLoopback(t, address)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Do(ctx, &Request{RequestMeta: RequestMeta{Path: "/lost", Method: "GET"}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestClosedClientRejectsRequests(t *testing.T) {
+	address, _ := startLoopback(t, func(conn net.Conn) {
+		_, _ = readRequest(conn)
+	})
+	client := dialLoopback(t, address)
+	_ = client.Close()
+
+	if _, err := client.Send(&Request{RequestMeta: RequestMeta{Path: "/health", Method: "GET"}}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("expected ErrClientClosed, got %v", err)
+	}
+}
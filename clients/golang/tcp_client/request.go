@@ -1,6 +1,15 @@
 package tcpClient
 
-import "encoding/json"
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/binenc"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+)
+
+var ErrBinaryUnsupported = errors.New("body has no binary encoding")
 
 type RequestMeta struct {
 	Path   string `json:"path"`
@@ -8,11 +17,74 @@ type RequestMeta struct {
 }
 
 type Request struct {
+	Id              string      `json:"id,omitempty"`
 	RequestMeta     RequestMeta `json:"meta"`
 	Body            any         `json:"body,omitempty"`
 	ConnectionAlive bool        `json:"connectionAlive,omitempty"`
+	Stream          bool        `json:"stream,omitempty"`
+	AcceptEncoding  []string    `json:"acceptEncoding,omitempty"`
+	// ContentType selects the encoding of the request, the server answers
+	// in the same one. Binary requests need a binary Body.
+	ContentType streamer.ContentType `json:"-"`
 }
 
+const (
+	requestFlagConnectionAlive = 1 << iota
+	requestFlagStream
+)
+
 func (r *Request) MarshalJSONBinary() ([]byte, error) {
 	return json.Marshal(r)
 }
+
+// MarshalBinary encodes the binary request envelope, it mirrors the
+// server's tcpRouter.Request. Body has to be nil, raw bytes or an
+// encoding.BinaryMarshaler.
+func (r *Request) MarshalBinary() ([]byte, error) {
+	var body []byte
+	switch data := r.Body.(type) {
+	case nil:
+	case []byte:
+		body = data
+	case encoding.BinaryMarshaler:
+		var err error
+		if body, err = data.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrBinaryUnsupported
+	}
+
+	var flags uint64
+	if r.ConnectionAlive {
+		flags |= requestFlagConnectionAlive
+	}
+	if r.Stream {
+		flags |= requestFlagStream
+	}
+
+	writer := binenc.NewWriter(len(body) + 64)
+	writer.WriteString(r.Id)
+	writer.WriteString(r.RequestMeta.Path)
+	writer.WriteString(r.RequestMeta.Method)
+	writer.WriteUvarint(flags)
+	writer.WriteStrings(r.AcceptEncoding)
+	writer.WriteBytes(body)
+	return writer.Data(), nil
+}
+
+func (r *Request) encode() (streamer.Frame, error) {
+	frame := streamer.Frame{
+		Kind:        streamer.FrameMessage,
+		ContentType: r.ContentType,
+	}
+
+	var err error
+	if r.ContentType == streamer.ContentBinary {
+		frame.Payload, err = r.MarshalBinary()
+	} else {
+		frame.Payload, err = r.MarshalJSONBinary()
+	}
+
+	return frame, err
+}
@@ -1,42 +1,124 @@
 package tcpClient
 
 import (
+	"bytes"
+	"encoding"
 	"encoding/json"
 	"errors"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/binenc"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
 )
 
 var ErrInvalidRequestStatus = errors.New("invalid request status")
 
 type ResponseStatus int
 
+// Statuses mirror the server's tcpRouter.ResponseStatus wire values.
 const (
 	StatusOK ResponseStatus = iota
 	StatusProcessing
 	StatusNotFound
 	StatusBadRequest
 	StatusInternalServerError
+	StatusCreated
+	StatusNoContent
+	StatusConflict
+	StatusUnauthorized
+	StatusForbidden
+	StatusMethodNotAllowed
+	StatusPayloadTooLarge
+	StatusTooManyRequests
+	StatusServiceUnavailable
 )
 
+var responseStatusNames = [...]string{
+	"OK",
+	"Processing",
+	"Not Found",
+	"Bad Request",
+	"Internal Server Error",
+	"Created",
+	"No Content",
+	"Conflict",
+	"Unauthorized",
+	"Forbidden",
+	"Method Not Allowed",
+	"Payload Too Large",
+	"Too Many Requests",
+	"Service Unavailable",
+}
+
 func (requestStatus ResponseStatus) Validate() error {
-	switch requestStatus {
-	case StatusOK, StatusNotFound, StatusProcessing, StatusInternalServerError, StatusBadRequest:
-		return nil
-	default:
+	if requestStatus < 0 || int(requestStatus) >= len(responseStatusNames) {
 		return ErrInvalidRequestStatus
 	}
+
+	return nil
 }
 
 func (requestStatus ResponseStatus) String() string {
-	return [...]string{
-		"OK",
-		"Processing",
-		"Not Found",
-		"Bad Request",
-		"Internal Server Error",
-	}[requestStatus]
+	if err := requestStatus.Validate(); err != nil {
+		return "Unknown Status"
+	}
+
+	return responseStatusNames[requestStatus]
+}
+
+func (requestStatus ResponseStatus) IsSuccess() bool {
+	switch requestStatus {
+	case StatusOK, StatusCreated, StatusNoContent:
+		return true
+	default:
+		return false
+	}
 }
 
 type Response struct {
+	Id     string          `json:"id,omitempty"`
 	Status ResponseStatus  `json:"status"`
 	Body   json.RawMessage `json:"body"`
+	// ContentType is the encoding Body is in, see DecodeBody.
+	ContentType streamer.ContentType `json:"-"`
+}
+
+func (response *Response) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	response.Id = reader.ReadString()
+	response.Status = ResponseStatus(reader.ReadUvarint())
+	response.Body = bytes.Clone(reader.ReadBytes())
+	response.ContentType = streamer.ContentBinary
+	return reader.Close()
+}
+
+// DecodeBody decodes Body in the encoding the response arrived in,
+// binary bodies need body to implement encoding.BinaryUnmarshaler.
+func (response *Response) DecodeBody(body any) error {
+	if response.ContentType != streamer.ContentBinary {
+		return json.Unmarshal(response.Body, body)
+	}
+
+	unmarshaler, ok := body.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return ErrBinaryUnsupported
+	}
+
+	return unmarshaler.UnmarshalBinary(response.Body)
+}
+
+func decodeResponse(frame streamer.Frame) (*Response, error) {
+	response := &Response{}
+
+	var err error
+	if frame.ContentType == streamer.ContentBinary {
+		err = response.UnmarshalBinary(frame.Payload)
+	} else {
+		err = json.Unmarshal(frame.Payload, response)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
 }
@@ -1,6 +1,47 @@
 import socket
 import struct
 import json
+import zlib
+from enum import IntEnum
+
+class ResponseStatus(IntEnum):
+    # Mirrors the server's tcpRouter.ResponseStatus wire values.
+    OK = 0
+    PROCESSING = 1
+    NOT_FOUND = 2
+    BAD_REQUEST = 3
+    INTERNAL_SERVER_ERROR = 4
+    CREATED = 5
+    NO_CONTENT = 6
+    CONFLICT = 7
+    UNAUTHORIZED = 8
+    FORBIDDEN = 9
+    METHOD_NOT_ALLOWED = 10
+    PAYLOAD_TOO_LARGE = 11
+    TOO_MANY_REQUESTS = 12
+    SERVICE_UNAVAILABLE = 13
+
+    def is_success(self):
+        return self in (ResponseStatus.OK, ResponseStatus.CREATED, ResponseStatus.NO_CONTENT)
+
+FRAME_MAGIC = 0xA7
+FRAME_VERSION = 3
+FRAME_MESSAGE = 0
+FRAME_STREAM_CHUNK = 1
+FRAME_STREAM_END = 2
+MAX_CHUNK_SIZE = 1 << 20
+MAX_MESSAGE_SIZE = 16 << 20
+
+# Frame flags hold the payload codec in the low 4 bits and the content type
+# above them, zlib's wbits select the matching codec format.
+CODEC_MASK = 0x0F
+CONTENT_TYPE_SHIFT = 4
+CONTENT_JSON = 0
+CODEC_NONE = 0
+CODEC_GZIP = 1
+CODEC_DEFLATE = 2
+CODEC_WBITS = {CODEC_GZIP: 16 + zlib.MAX_WBITS, CODEC_DEFLATE: -zlib.MAX_WBITS}
+ACCEPT_ENCODING = ["gzip", "deflate"]
 
 class TcpSocketClient:
     def __init__(self, host, port, buff_size):
@@ -8,11 +49,13 @@ class TcpSocketClient:
         self.port = port
         self.buff_size = buff_size
         self.sock = None
+        self.negotiated = False
 
     def connect(self):
         if not self.sock:
             self.sock = socket.socket(socket.AF_INET, socket.SOCK_STREAM)
             self.sock.connect((self.host, self.port))
+            self.negotiated = False
 
     def close(self):
         if self.sock:
@@ -28,10 +71,10 @@ class TcpSocketClient:
     def send_request_raw(self, sock, data):
         request_bin = json.dumps(data).encode('utf-8')
         request_len = len(request_bin)
-        total_chunks = (request_len + self.buff_size - 1) // self.buff_size
 
+        sock.sendall(bytes([FRAME_MAGIC, FRAME_VERSION, FRAME_MESSAGE, CODEC_NONE]))
         sock.sendall(self.write_int32_to_buffer(self.buff_size))
-        sock.sendall(self.write_int32_to_buffer(total_chunks))
+        sock.sendall(self.write_int32_to_buffer(request_len))
 
         offset = 0
         while offset < request_len:
@@ -39,32 +82,79 @@ class TcpSocketClient:
             sock.sendall(request_bin[offset:end])
             offset = end
 
-    def read_response(self, sock):
-        chunk_size_buff = sock.recv(4)
-        if not chunk_size_buff:
-            raise ConnectionError("Failed to read chunk size.")
-        chunk_size = self.parse_buffered_int32(chunk_size_buff)
+    def recv_exact(self, sock, size):
+        data = bytearray()
+        while len(data) < size:
+            chunk = sock.recv(size - len(data))
+            if not chunk:
+                raise ConnectionError("Connection closed in the middle of a frame.")
+            data.extend(chunk)
+        return bytes(data)
+
+    def read_frame(self, sock):
+        magic, version, kind, flags = self.recv_exact(sock, 4)
+        if magic != FRAME_MAGIC:
+            raise ConnectionError("Invalid frame magic.")
+        if version != FRAME_VERSION:
+            raise ConnectionError(f"Unsupported frame version {version}.")
+        if kind not in (FRAME_MESSAGE, FRAME_STREAM_CHUNK, FRAME_STREAM_END):
+            raise ConnectionError("Invalid frame header.")
+        codec = flags & CODEC_MASK
+        if codec != CODEC_NONE and codec not in CODEC_WBITS:
+            raise ConnectionError("Unsupported frame codec.")
+        if flags >> CONTENT_TYPE_SHIFT != CONTENT_JSON:
+            raise ConnectionError("Only JSON frames are supported.")
 
-        total_chunks_buff = sock.recv(4)
-        if not total_chunks_buff:
-            raise ConnectionError("Failed to read total chunks.")
-        total_chunks = self.parse_buffered_int32(total_chunks_buff)
+        chunk_size = self.parse_buffered_int32(self.recv_exact(sock, 4))
+        length = self.parse_buffered_int32(self.recv_exact(sock, 4))
+        if chunk_size == 0 or chunk_size > MAX_CHUNK_SIZE:
+            raise ConnectionError("Invalid frame header.")
+        if length > MAX_MESSAGE_SIZE:
+            raise ConnectionError("Response is too large.")
 
         response_data = bytearray()
-        for _ in range(total_chunks):
-            chunk = sock.recv(chunk_size)
-            if not chunk:
-                raise ConnectionError("Failed to read a chunk.")
-            response_data.extend(chunk)
+        while len(response_data) < length:
+            size = min(chunk_size, length - len(response_data))
+            response_data.extend(self.recv_exact(sock, size))
+
+        if codec != CODEC_NONE:
+            response_data = self.decompress(codec, bytes(response_data))
+
+        return kind, json.loads(response_data.decode('utf-8'))
+
+    def decompress(self, codec, data):
+        decompressor = zlib.decompressobj(CODEC_WBITS[codec])
+        result = decompressor.decompress(data, MAX_MESSAGE_SIZE + 1)
+        if len(result) > MAX_MESSAGE_SIZE:
+            raise ConnectionError("Response is too large.")
+        return result
+
+    def read_response(self, sock):
+        kind, response = self.read_frame(sock)
+        if kind != FRAME_MESSAGE:
+            raise ConnectionError("Unexpected stream frame, use read_stream.")
+        return response
 
-        return json.loads(response_data.decode('utf-8'))
+    def read_stream(self, sock):
+        # Yields the parts of a streamed response, a single message is the only part.
+        while True:
+            kind, response = self.read_frame(sock)
+            if kind == FRAME_STREAM_END:
+                return
+            yield response
+            if kind == FRAME_MESSAGE:
+                return
 
     def fetch(self, request):
         with socket.socket(socket.AF_INET, socket.SOCK_STREAM) as sock:
             sock.connect((self.host, self.port))
-            self.send_request_raw(sock, request)
+            self.send_request_raw(sock, {**request, "acceptEncoding": ACCEPT_ENCODING})
             return self.read_response(sock)
 
     def fetch_open_conn(self, request):
+        # Only the first request of a connection negotiates compression.
+        if not self.negotiated:
+            request = {**request, "acceptEncoding": ACCEPT_ENCODING}
+            self.negotiated = True
         self.send_request_raw(self.sock, request)
         return self.read_response(self.sock)
\ No newline at end of file
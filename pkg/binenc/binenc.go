@@ -0,0 +1,151 @@
+package binenc
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// The encoding is a plain sequence of fields without names or tags, both
+// sides have to agree on the schema. Integers are uvarints, strings and
+// byte slices are prefixed with their length, lists with their item count.
+
+var (
+	ErrTruncated    = errors.New("binary payload is truncated")
+	ErrTrailingData = errors.New("binary payload has trailing data")
+)
+
+type Writer struct {
+	buffer []byte
+}
+
+func NewWriter(size int) *Writer {
+	return &Writer{
+		buffer: make([]byte, 0, size),
+	}
+}
+
+func (writer *Writer) WriteUvarint(value uint64) {
+	writer.buffer = binary.AppendUvarint(writer.buffer, value)
+}
+
+func (writer *Writer) WriteBool(value bool) {
+	if value {
+		writer.WriteUvarint(1)
+	} else {
+		writer.WriteUvarint(0)
+	}
+}
+
+func (writer *Writer) WriteBytes(value []byte) {
+	writer.WriteUvarint(uint64(len(value)))
+	writer.buffer = append(writer.buffer, value...)
+}
+
+func (writer *Writer) WriteString(value string) {
+	writer.WriteUvarint(uint64(len(value)))
+	writer.buffer = append(writer.buffer, value...)
+}
+
+func (writer *Writer) WriteStrings(values []string) {
+	writer.WriteUvarint(uint64(len(values)))
+	for _, value := range values {
+		writer.WriteString(value)
+	}
+}
+
+func (writer *Writer) Data() []byte {
+	return writer.buffer
+}
+
+// Reader decodes fields in the order they were written. The first error
+// sticks, every following read returns a zero value, so a whole schema can
+// be read before checking Err once.
+type Reader struct {
+	data []byte
+	err  error
+}
+
+func NewReader(data []byte) *Reader {
+	return &Reader{
+		data: data,
+	}
+}
+
+func (reader *Reader) ReadUvarint() uint64 {
+	if reader.err != nil {
+		return 0
+	}
+
+	value, n := binary.Uvarint(reader.data)
+	if n <= 0 {
+		reader.err = ErrTruncated
+		return 0
+	}
+
+	reader.data = reader.data[n:]
+	return value
+}
+
+func (reader *Reader) ReadBool() bool {
+	return reader.ReadUvarint() != 0
+}
+
+// Bytes returns a slice of the underlying data, not a copy.
+func (reader *Reader) ReadBytes() []byte {
+	length := reader.ReadUvarint()
+	if reader.err != nil {
+		return nil
+	}
+
+	if length > uint64(len(reader.data)) {
+		reader.err = ErrTruncated
+		return nil
+	}
+
+	value := reader.data[:length]
+	reader.data = reader.data[length:]
+	return value
+}
+
+func (reader *Reader) ReadString() string {
+	return string(reader.ReadBytes())
+}
+
+func (reader *Reader) ReadStrings() []string {
+	count := reader.ReadUvarint()
+	if reader.err != nil {
+		return nil
+	}
+
+	// Every item takes at least one byte, a larger count can only come
+	// from a forged payload and must not drive the allocation.
+	if count > uint64(len(reader.data)) {
+		reader.err = ErrTruncated
+		return nil
+	}
+
+	values := make([]string, 0, count)
+	for range count {
+		values = append(values, reader.ReadString())
+	}
+
+	if reader.err != nil {
+		return nil
+	}
+
+	return values
+}
+
+func (reader *Reader) Err() error {
+	return reader.err
+}
+
+// Close reports the first error, or ErrTrailingData when the payload
+// holds more than the schema read from it.
+func (reader *Reader) Close() error {
+	if reader.err == nil && len(reader.data) > 0 {
+		reader.err = ErrTrailingData
+	}
+
+	return reader.err
+}
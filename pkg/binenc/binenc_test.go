@@ -0,0 +1,67 @@
+package binenc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	writer := NewWriter(64)
+	writer.WriteUvarint(300)
+	writer.WriteBool(true)
+	writer.WriteString("héllo")
+	writer.WriteBytes([]byte{0, 1, 2})
+	writer.WriteStrings([]string{"a", "", "bc"})
+	writer.WriteStrings(nil)
+
+	reader := NewReader(writer.Data())
+	if value := reader.ReadUvarint(); value != 300 {
+		t.Errorf("expected 300, got %v", value)
+	}
+	if !reader.ReadBool() {
+		t.Error("expected true")
+	}
+	if value := reader.ReadString(); value != "héllo" {
+		t.Errorf("expected héllo, got %q", value)
+	}
+	if value := reader.ReadBytes(); !reflect.DeepEqual(value, []byte{0, 1, 2}) {
+		t.Errorf("expected [0 1 2], got %v", value)
+	}
+	if value := reader.ReadStrings(); !reflect.DeepEqual(value, []string{"a", "", "bc"}) {
+		t.Errorf("expected [a  bc], got %q", value)
+	}
+	if value := reader.ReadStrings(); len(value) != 0 {
+		t.Errorf("expected an empty list, got %q", value)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	writer := NewWriter(16)
+	writer.WriteString("truncated")
+	data := writer.Data()
+
+	reader := NewReader(data[:4])
+	if value := reader.ReadString(); value != "" || !errors.Is(reader.Err(), ErrTruncated) {
+		t.Errorf("expected ErrTruncated, got %q, %v", value, reader.Err())
+	}
+	if reader.ReadUvarint() != 0 || !errors.Is(reader.Close(), ErrTruncated) {
+		t.Error("the first error should stick")
+	}
+
+	reader = NewReader(append(data, 0))
+	_ = reader.ReadString()
+	if err := reader.Close(); !errors.Is(err, ErrTrailingData) {
+		t.Errorf("expected ErrTrailingData, got %v", err)
+	}
+
+	forged := NewWriter(16)
+	forged.WriteUvarint(1 << 40)
+	reader = NewReader(forged.Data())
+	if values := reader.ReadStrings(); values != nil || !errors.Is(reader.Err(), ErrTruncated) {
+		t.Errorf("forged item count should be rejected, got %v", reader.Err())
+	}
+}
@@ -105,6 +105,24 @@ func (list *LinkedList[T]) Remove(cb func(current *T) bool) {
 	}
 }
 
+// Front returns the first element without removing it, nil for an empty list.
+func (list *LinkedList[T]) Front() *T {
+	if list.head == nil {
+		return nil
+	}
+
+	return list.head.element
+}
+
+func (list *LinkedList[T]) ToSlice() []*T {
+	result := make([]*T, 0, list.length)
+	for current := list.head; current != nil; current = current.nextNode {
+		result = append(result, current.element)
+	}
+
+	return result
+}
+
 func (list *LinkedList[T]) RemoveFront() *T {
 	element := list.head.element
 	list.head = list.head.nextNode
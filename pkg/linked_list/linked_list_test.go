@@ -63,3 +63,24 @@ func Test(t *testing.T) {
 		t.Errorf("Expected %d, got %d", maxPushFront+maxPushBack-maxRemoveFront-maxRemoveByName, list.GetSize())
 	}
 }
+
+func TestFrontAndToSlice(t *testing.T) {
+	list := New[int]()
+	if list.Front() != nil || len(list.ToSlice()) != 0 {
+		t.Error("empty list should have no front and no elements")
+	}
+
+	for i := range 3 {
+		list.AddBack(&i)
+	}
+
+	if *list.Front() != 0 {
+		t.Errorf("expected front 0, got %v", *list.Front())
+	}
+
+	for idx, element := range list.ToSlice() {
+		if *element != idx {
+			t.Errorf("expected %v at %v, got %v", idx, idx, *element)
+		}
+	}
+}
@@ -29,6 +29,14 @@ func (h *heap[T]) Empty() bool {
 	return len(h.data) == 0
 }
 
+func (h *heap[T]) Peek() *T {
+	if h.Empty() {
+		return nil
+	}
+
+	return h.data[0]
+}
+
 func (h *heap[T]) Push(element *T) {
 	h.data = append(h.data, element)
 	lastIdx := h.Size() - 1
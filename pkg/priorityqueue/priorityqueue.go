@@ -34,6 +34,12 @@ func (pq *PriorityQueue[T]) Empty() bool {
 	return pq.heap.Empty()
 }
 
+func (pq *PriorityQueue[T]) Peek() *T {
+	pq.RLock()
+	defer pq.RUnlock()
+	return pq.heap.Peek()
+}
+
 func (pq *PriorityQueue[T]) Push(element *T) {
 	pq.Lock()
 	defer pq.Unlock()
@@ -0,0 +1,159 @@
+package streamer
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrUnsupportedCodec = errors.New("unsupported compression codec")
+	ErrCorruptPayload   = errors.New("corrupt compressed payload")
+)
+
+// Codec is the compression of a frame payload, stored in the low bits of
+// the frame flags. The remaining flag bits are reserved and must be zero.
+type Codec byte
+
+const (
+	CodecNone Codec = iota
+	CodecGzip
+	CodecDeflate
+)
+
+const (
+	codecMask = 0x0f
+
+	// DefaultCompressionThreshold is the payload size below which compressing
+	// costs more than it saves.
+	DefaultCompressionThreshold = 1024
+)
+
+var codecNames = map[Codec]string{
+	CodecNone:    "identity",
+	CodecGzip:    "gzip",
+	CodecDeflate: "deflate",
+}
+
+func (codec Codec) Validate() error {
+	if _, ok := codecNames[codec]; !ok {
+		return fmt.Errorf("%w: %v", ErrUnsupportedCodec, byte(codec))
+	}
+
+	return nil
+}
+
+func (codec Codec) String() string {
+	if name, ok := codecNames[codec]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// SupportedEncodings lists the codec names a peer may offer, most preferred first.
+func SupportedEncodings() []string {
+	return []string{CodecGzip.String(), CodecDeflate.String()}
+}
+
+// NegotiateCodec picks the first of the encodings offered by the peer that
+// is supported here, so the peer's order of preference wins.
+func NegotiateCodec(accepted []string) Codec {
+	for _, name := range accepted {
+		for codec, codecName := range codecNames {
+			if codec != CodecNone && name == codecName {
+				return codec
+			}
+		}
+	}
+
+	return CodecNone
+}
+
+// Compression describes how outgoing payloads are compressed. Payloads
+// shorter than Threshold, or ones that do not shrink, are sent as is.
+type Compression struct {
+	Codec     Codec
+	Threshold int
+}
+
+func (compression Compression) encode(payload []byte) (Codec, []byte, error) {
+	if compression.Codec == CodecNone || len(payload) < compression.Threshold {
+		return CodecNone, payload, nil
+	}
+
+	compressed, err := compress(compression.Codec, payload)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(compressed) >= len(payload) {
+		return CodecNone, payload, nil
+	}
+
+	return compression.Codec, compressed, nil
+}
+
+func compress(codec Codec, payload []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	var err error
+
+	switch codec {
+	case CodecGzip:
+		writer = gzip.NewWriter(&buffer)
+	case CodecDeflate:
+		writer, err = flate.NewWriter(&buffer, flate.DefaultCompression)
+	default:
+		err = codec.Validate()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = writer.Write(payload); err != nil {
+		return nil, err
+	}
+
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// decompress inflates payload, stopping as soon as the result grows past
+// maxMessageSize, so a small compressed frame cannot expand without bound.
+func decompress(codec Codec, payload []byte, maxMessageSize int) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+
+	switch codec {
+	case CodecNone:
+		return payload, nil
+	case CodecGzip:
+		reader, err = gzip.NewReader(bytes.NewReader(payload))
+	case CodecDeflate:
+		reader = flate.NewReader(bytes.NewReader(payload))
+	default:
+		err = codec.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptPayload, err)
+	}
+	defer reader.Close()
+
+	result, err := io.ReadAll(io.LimitReader(reader, int64(maxMessageSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptPayload, err)
+	}
+
+	if len(result) > maxMessageSize {
+		return nil, fmt.Errorf("%w: decompressed payload exceeds %v bytes", ErrMessageTooLarge, maxMessageSize)
+	}
+
+	return result, nil
+}
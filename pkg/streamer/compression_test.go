@@ -0,0 +1,141 @@
+package streamer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func frameCodec(t *testing.T, conn *bytes.Buffer) Codec {
+	t.Helper()
+	if conn.Len() < HeaderSize {
+		t.Fatal("frame is shorter than its header")
+	}
+	return Codec(conn.Bytes()[3])
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	payload := []byte(strings.Repeat(`{"files":["resources/data/train/pos/1_7.txt"]}`, 200))
+
+	for _, codec := range []Codec{CodecNone, CodecGzip, CodecDeflate} {
+		t.Run(codec.String(), func(t *testing.T) {
+			var conn bytes.Buffer
+			compression := Compression{Codec: codec, Threshold: DefaultCompressionThreshold}
+			if err := WriteFrameCompressed(&conn, 64, Frame{Kind: FrameMessage, Payload: payload}, compression); err != nil {
+				t.Fatal(err)
+			}
+
+			if frameCodec(t, &conn) != codec {
+				t.Errorf("expected codec %v, got %v", codec, frameCodec(t, &conn))
+			}
+
+			if codec != CodecNone && conn.Len() >= len(payload) {
+				t.Errorf("%v did not shrink the payload: %v bytes", codec, conn.Len())
+			}
+
+			result, err := ReadBuff(&conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(result, payload) {
+				t.Error("payload changed after round trip")
+			}
+		})
+	}
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	var conn bytes.Buffer
+	compression := Compression{Codec: CodecGzip, Threshold: 100}
+	frame := Frame{Kind: FrameMessage, Payload: []byte(strings.Repeat("a", 99))}
+	if err := WriteFrameCompressed(&conn, 64, frame, compression); err != nil {
+		t.Fatal(err)
+	}
+
+	if frameCodec(t, &conn) != CodecNone {
+		t.Error("payload below the threshold should stay uncompressed")
+	}
+}
+
+func TestIncompressiblePayloadIsSentAsIs(t *testing.T) {
+	payload := make([]byte, 4096)
+	if _, err := rand.Read(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	var conn bytes.Buffer
+	compression := Compression{Codec: CodecDeflate, Threshold: 0}
+	if err := WriteFrameCompressed(&conn, 512, Frame{Kind: FrameStreamChunk, Payload: payload}, compression); err != nil {
+		t.Fatal(err)
+	}
+
+	if frameCodec(t, &conn) != CodecNone {
+		t.Error("payload that does not shrink should stay uncompressed")
+	}
+
+	frame, err := ReadFrame(&conn, DefaultMaxMessageSize)
+	if err != nil || frame.Kind != FrameStreamChunk || !bytes.Equal(frame.Payload, payload) {
+		t.Errorf("payload did not survive a round trip: %v", err)
+	}
+}
+
+func TestDecompressedSizeIsLimited(t *testing.T) {
+	var conn bytes.Buffer
+	compression := Compression{Codec: CodecGzip}
+	frame := Frame{Kind: FrameMessage, Payload: make([]byte, 64*1024)}
+	if err := WriteFrameCompressed(&conn, 64, frame, compression); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadBuffLimit(&conn, 1024); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+}
+
+func TestReadFrameRejectsBadCompression(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags byte
+		err   error
+	}{
+		{"corrupt gzip", byte(CodecGzip), ErrCorruptPayload},
+		{"corrupt deflate", byte(CodecDeflate), ErrCorruptPayload},
+		{"unknown codec", 0x0f, ErrInvalidHeader},
+		{"unknown content type", 0x20, ErrInvalidHeader},
+		{"reserved flag", 0x40, ErrInvalidHeader},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := frame(Magic, Version, 8, 4, []byte("junk"))
+			data[3] = test.flags
+
+			if _, err := ReadBuff(bytes.NewBuffer(data)); !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestNegotiateCodec(t *testing.T) {
+	tests := []struct {
+		accepted []string
+		codec    Codec
+	}{
+		{nil, CodecNone},
+		{[]string{"zstd", "br"}, CodecNone},
+		{[]string{"identity"}, CodecNone},
+		{[]string{"deflate", "gzip"}, CodecDeflate},
+		{[]string{"zstd", "gzip"}, CodecGzip},
+		{SupportedEncodings(), CodecGzip},
+	}
+
+	for _, test := range tests {
+		if codec := NegotiateCodec(test.accepted); codec != test.codec {
+			t.Errorf("%v: expected %v, got %v", test.accepted, test.codec, codec)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package streamer
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrStreamClosed = errors.New("stream is closed")
+
+// StreamWriter sends a message whose size is not known up front: every
+// Write becomes one FrameStreamChunk and Close sends the FrameStreamEnd marker,
+// so the writer never has to buffer the whole message.
+type StreamWriter struct {
+	conn      Connection
+	chunkSize int
+	closed    bool
+}
+
+func NewStreamWriter(conn Connection, chunkSize int) *StreamWriter {
+	return &StreamWriter{
+		conn:      conn,
+		chunkSize: chunkSize,
+	}
+}
+
+func (stream *StreamWriter) Write(p []byte) (int, error) {
+	if stream.closed {
+		return 0, ErrStreamClosed
+	}
+
+	if err := WriteFrame(stream.conn, stream.chunkSize, FrameStreamChunk, p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (stream *StreamWriter) Close() error {
+	if stream.closed {
+		return ErrStreamClosed
+	}
+
+	stream.closed = true
+	return WriteFrame(stream.conn, stream.chunkSize, FrameStreamEnd, nil)
+}
+
+// StreamReader reads the parts of a message written by a StreamWriter.
+type StreamReader struct {
+	conn           Connection
+	maxMessageSize int
+	done           bool
+}
+
+func NewStreamReader(conn Connection, maxMessageSize int) *StreamReader {
+	return &StreamReader{
+		conn:           conn,
+		maxMessageSize: maxMessageSize,
+	}
+}
+
+// Next returns the next part of the stream, or io.EOF after the end marker.
+func (stream *StreamReader) Next() ([]byte, error) {
+	if stream.done {
+		return nil, io.EOF
+	}
+
+	frame, err := ReadFrame(stream.conn, stream.maxMessageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	switch frame.Kind {
+	case FrameStreamChunk:
+		return frame.Payload, nil
+	case FrameStreamEnd:
+		stream.done = true
+		return nil, io.EOF
+	default:
+		return nil, ErrUnexpectedFrame
+	}
+}
@@ -2,29 +2,153 @@ package streamer
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 )
 
+// Frame header layout, all integers are big-endian:
+//
+//	| magic (1) | version (1) | kind (1) | flags (1) | chunkSize (4) | length (4) | payload (length) |
+//
+// The payload is written in chunks of chunkSize bytes, the last one may be shorter.
+// The flags hold the payload Codec in the low bits and its ContentType above
+// them, length is the size of the payload on the wire.
+const (
+	Magic      byte = 0xA7
+	Version    byte = 3
+	HeaderSize      = 12
+
+	MaxChunkSize          = 1 << 20
+	DefaultMaxMessageSize = 16 << 20
+)
+
+var (
+	ErrInvalidMagic       = errors.New("invalid frame magic")
+	ErrUnsupportedVersion = errors.New("unsupported frame version")
+	ErrInvalidHeader      = errors.New("invalid frame header")
+	ErrMessageTooLarge    = errors.New("message is too large")
+	ErrInvalidChunkSize   = errors.New("invalid chunk size")
+	ErrUnexpectedFrame    = errors.New("unexpected frame kind")
+)
+
+// FrameKind tells a complete message from the parts of a streamed one.
+// A stream is any number of FrameStreamChunk frames closed by FrameStreamEnd.
+type FrameKind byte
+
+const (
+	FrameMessage FrameKind = iota
+	FrameStreamChunk
+	FrameStreamEnd
+)
+
+func (kind FrameKind) Validate() error {
+	switch kind {
+	case FrameMessage, FrameStreamChunk, FrameStreamEnd:
+		return nil
+	default:
+		return ErrInvalidHeader
+	}
+}
+
+// ContentType is the encoding of the message carried by a frame.
+type ContentType byte
+
+const (
+	ContentJSON ContentType = iota
+	ContentBinary
+)
+
+const (
+	contentTypeShift = 4
+	contentTypeMask  = 0x30
+)
+
+func (contentType ContentType) Validate() error {
+	switch contentType {
+	case ContentJSON, ContentBinary:
+		return nil
+	default:
+		return ErrInvalidHeader
+	}
+}
+
+func (contentType ContentType) String() string {
+	switch contentType {
+	case ContentJSON:
+		return "json"
+	case ContentBinary:
+		return "binary"
+	default:
+		return "unknown"
+	}
+}
+
+// Frame is one decoded frame, its payload is already decompressed.
+type Frame struct {
+	Kind        FrameKind
+	ContentType ContentType
+	Payload     []byte
+}
+
 type Connection interface {
 	Read([]byte) (int, error)
 	Write([]byte) (int, error)
 }
 
-func readChunks(conn Connection) (chunkSize int, totalChunks int, err error) {
-	chunkSizeBuff := make([]byte, 4)
-	_, err = conn.Read(chunkSizeBuff)
-	if err != nil {
-		return 0, 0, err
+// readHeader reads and validates the frame header before anything is
+// allocated for the payload, so a forged header cannot force huge allocations.
+type frameHeader struct {
+	kind        FrameKind
+	codec       Codec
+	contentType ContentType
+	chunkSize   int
+	length      int
+}
+
+func readHeader(conn Connection, maxMessageSize int) (frameHeader, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return frameHeader{}, err
 	}
-	chunkSize = int(ParseBufferedInt32(chunkSizeBuff))
 
-	totalChunksBuff := make([]byte, 4)
-	_, err = conn.Read(totalChunksBuff)
-	if err != nil {
-		return 0, 0, err
+	if header[0] != Magic {
+		return frameHeader{}, ErrInvalidMagic
+	}
+
+	if header[1] != Version {
+		return frameHeader{}, fmt.Errorf("%w: %v", ErrUnsupportedVersion, header[1])
+	}
+
+	kind := FrameKind(header[2])
+	if err := kind.Validate(); err != nil {
+		return frameHeader{}, err
+	}
+
+	codec := Codec(header[3] & codecMask)
+	contentType := ContentType((header[3] & contentTypeMask) >> contentTypeShift)
+	if header[3]&^(codecMask|contentTypeMask) != 0 || codec.Validate() != nil || contentType.Validate() != nil {
+		return frameHeader{}, ErrInvalidHeader
+	}
+
+	chunkSize := int(ParseBufferedInt32(header[4:8]))
+	length := int(ParseBufferedInt32(header[8:12]))
+	if chunkSize <= 0 || chunkSize > MaxChunkSize || length < 0 {
+		return frameHeader{}, ErrInvalidHeader
+	}
+
+	if length > maxMessageSize {
+		return frameHeader{}, fmt.Errorf("%w: %v bytes, limit is %v", ErrMessageTooLarge, length, maxMessageSize)
 	}
-	totalChunks = int(ParseBufferedInt32(totalChunksBuff))
 
-	return chunkSize, totalChunks, nil
+	return frameHeader{
+		kind:        kind,
+		codec:       codec,
+		contentType: contentType,
+		chunkSize:   chunkSize,
+		length:      length,
+	}, nil
 }
 
 func ParseBufferedInt32(buff []byte) int32 {
@@ -34,21 +158,68 @@ func ParseBufferedInt32(buff []byte) int32 {
 	return int32(buff[0])<<24 | int32(buff[1])<<16 | int32(buff[2])<<8 | int32(buff[3])
 }
 
+// ReadBuff reads exactly one message frame of at most DefaultMaxMessageSize bytes.
 func ReadBuff(conn Connection) ([]byte, error) {
-	chunkSize, totalChunks, err := readChunks(conn)
+	return ReadBuffLimit(conn, DefaultMaxMessageSize)
+}
+
+// ReadBuffLimit reads exactly one message frame, so several frames sent back
+// to back over the same connection are never mixed up. Frames longer than
+// maxMessageSize are rejected with ErrMessageTooLarge, stream frames with
+// ErrUnexpectedFrame.
+func ReadBuffLimit(conn Connection, maxMessageSize int) ([]byte, error) {
+	frame, err := ReadFrame(conn, maxMessageSize)
 	if err != nil {
 		return nil, err
 	}
 
+	if frame.Kind != FrameMessage {
+		return nil, ErrUnexpectedFrame
+	}
+
+	return frame.Payload, nil
+}
+
+// ReadFrame reads exactly one frame of any kind and decompresses its payload.
+// maxMessageSize bounds both the payload on the wire and the decompressed one.
+func ReadFrame(conn Connection, maxMessageSize int) (Frame, error) {
+	header, err := readHeader(conn, maxMessageSize)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	payload, err := readPayload(conn, header.chunkSize, header.length)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	payload, err = decompress(header.codec, payload, maxMessageSize)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	return Frame{
+		Kind:        header.kind,
+		ContentType: header.contentType,
+		Payload:     payload,
+	}, nil
+}
+
+func readPayload(conn Connection, chunkSize int, length int) ([]byte, error) {
+	var err error
 	var buffer bytes.Buffer
-	for i := 0; i < totalChunks; i++ {
-		chunk := make([]byte, chunkSize)
-		n, err := conn.Read(chunk)
-		if err != nil {
+	chunk := make([]byte, min(chunkSize, length))
+	for remaining := length; remaining > 0; {
+		n := min(chunkSize, remaining)
+		if _, err = io.ReadFull(conn, chunk[:n]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
 			return nil, err
 		}
 
 		buffer.Write(chunk[:n])
+		remaining -= n
 	}
 
 	return buffer.Bytes(), nil
@@ -63,23 +234,61 @@ func WriteInt32ToBuffer(value int) []byte {
 	}
 }
 
+func writeHeader(conn Connection, frame Frame, codec Codec, chunkSize int, length int) error {
+	flags := byte(codec) | byte(frame.ContentType)<<contentTypeShift
+	header := make([]byte, 0, HeaderSize)
+	header = append(header, Magic, Version, byte(frame.Kind), flags)
+	header = append(header, WriteInt32ToBuffer(chunkSize)...)
+	header = append(header, WriteInt32ToBuffer(length)...)
+
+	_, err := conn.Write(header)
+	return err
+}
+
+// WriteBuff writes requestBin as a single message frame.
 func WriteBuff(conn Connection, chunkSize int, requestBin []byte) error {
-	requestLen := len(requestBin)
-	totalChunks := requestLen / chunkSize
-	if requestLen%chunkSize != 0 {
-		totalChunks++
+	return WriteFrame(conn, chunkSize, FrameMessage, requestBin)
+}
+
+// WriteFrame writes requestBin uncompressed as a single JSON frame of the given kind.
+func WriteFrame(conn Connection, chunkSize int, kind FrameKind, requestBin []byte) error {
+	frame := Frame{
+		Kind:    kind,
+		Payload: requestBin,
 	}
 
-	_, err := conn.Write(WriteInt32ToBuffer(chunkSize))
-	if err != nil {
+	return WriteFrameCompressed(conn, chunkSize, frame, Compression{})
+}
+
+// WriteFrameCompressed writes frame, compressing its payload when
+// compression allows it.
+func WriteFrameCompressed(conn Connection, chunkSize int, frame Frame, compression Compression) error {
+	if err := frame.Kind.Validate(); err != nil {
 		return err
 	}
 
-	_, err = conn.Write(WriteInt32ToBuffer(totalChunks))
+	if err := frame.ContentType.Validate(); err != nil {
+		return err
+	}
+
+	if chunkSize <= 0 || chunkSize > MaxChunkSize {
+		return ErrInvalidChunkSize
+	}
+
+	codec, requestBin, err := compression.encode(frame.Payload)
 	if err != nil {
 		return err
 	}
 
+	requestLen := len(requestBin)
+	if requestLen > math.MaxInt32 {
+		return ErrMessageTooLarge
+	}
+
+	if err := writeHeader(conn, frame, codec, chunkSize, requestLen); err != nil {
+		return err
+	}
+
 	offset := 0
 	for offset < requestLen {
 		end := offset + chunkSize
@@ -0,0 +1,208 @@
+package streamer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func frame(magic, version byte, chunkSize, length int, payload []byte) []byte {
+	return kindFrame(magic, version, FrameMessage, chunkSize, length, payload)
+}
+
+func kindFrame(magic, version byte, kind FrameKind, chunkSize, length int, payload []byte) []byte {
+	data := []byte{magic, version, byte(kind), byte(CodecNone)}
+	data = append(data, WriteInt32ToBuffer(chunkSize)...)
+	data = append(data, WriteInt32ToBuffer(length)...)
+	return append(data, payload...)
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	payloads := [][]byte{
+		{},
+		[]byte("short"),
+		[]byte(strings.Repeat("chunked payload ", 1000)),
+	}
+
+	for _, payload := range payloads {
+		for _, chunkSize := range []int{1, 7, 2048} {
+			var conn bytes.Buffer
+			if err := WriteBuff(&conn, chunkSize, payload); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := ReadBuff(&conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(result, payload) {
+				t.Errorf("chunk size %v: payload changed after round trip", chunkSize)
+			}
+		}
+	}
+}
+
+func TestReadBuffFramesBackToBack(t *testing.T) {
+	var conn bytes.Buffer
+	messages := []string{"first", "second message", "third"}
+	for _, message := range messages {
+		if err := WriteBuff(&conn, 4, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, message := range messages {
+		result, err := ReadBuff(&conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(result) != message {
+			t.Errorf("expected %q, got %q", message, result)
+		}
+	}
+}
+
+func TestReadBuffRejectsInvalidFrames(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"bad magic", frame(0x00, Version, 8, 1, []byte("x")), ErrInvalidMagic},
+		{"bad version", frame(Magic, Version+1, 8, 1, []byte("x")), ErrUnsupportedVersion},
+		{"zero chunk size", frame(Magic, Version, 0, 1, []byte("x")), ErrInvalidHeader},
+		{"huge chunk size", frame(Magic, Version, MaxChunkSize+1, 1, []byte("x")), ErrInvalidHeader},
+		{"negative length", frame(Magic, Version, 8, -1, nil), ErrInvalidHeader},
+		{"too large", frame(Magic, Version, 8, 1025, nil), ErrMessageTooLarge},
+		{"truncated header", frame(Magic, Version, 8, 1, nil)[:5], io.ErrUnexpectedEOF},
+		{"truncated payload", frame(Magic, Version, 8, 10, []byte("abc")), io.ErrUnexpectedEOF},
+		{"unknown kind", kindFrame(Magic, Version, FrameStreamEnd+1, 8, 1, []byte("x")), ErrInvalidHeader},
+		{"stream chunk", kindFrame(Magic, Version, FrameStreamChunk, 8, 1, []byte("x")), ErrUnexpectedFrame},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadBuffLimit(bytes.NewBuffer(test.data), 1024)
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestWriteBuffRejectsInvalidChunkSize(t *testing.T) {
+	for _, chunkSize := range []int{0, -1, MaxChunkSize + 1} {
+		if err := WriteBuff(&bytes.Buffer{}, chunkSize, []byte("x")); !errors.Is(err, ErrInvalidChunkSize) {
+			t.Errorf("chunk size %v: expected ErrInvalidChunkSize, got %v", chunkSize, err)
+		}
+	}
+}
+
+func TestContentTypeRoundTrip(t *testing.T) {
+	var conn bytes.Buffer
+	sent := Frame{Kind: FrameMessage, ContentType: ContentBinary, Payload: []byte{0, 1, 2, 3}}
+	compression := Compression{Codec: CodecGzip}
+	if err := WriteFrameCompressed(&conn, 2, sent, compression); err != nil {
+		t.Fatal(err)
+	}
+
+	received, err := ReadFrame(&conn, DefaultMaxMessageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if received.ContentType != ContentBinary || !bytes.Equal(received.Payload, sent.Payload) {
+		t.Errorf("expected %v frame %v, got %v frame %v",
+			sent.ContentType, sent.Payload, received.ContentType, received.Payload)
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	var conn bytes.Buffer
+	parts := []string{"first part", "", strings.Repeat("long part ", 500)}
+
+	writer := NewStreamWriter(&conn, 64)
+	for _, part := range parts {
+		if _, err := writer.Write([]byte(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.Write([]byte("late")); !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("expected ErrStreamClosed, got %v", err)
+	}
+
+	if err := WriteBuff(&conn, 64, []byte("next message")); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewStreamReader(&conn, DefaultMaxMessageSize)
+	for _, part := range parts {
+		result, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(result) != part {
+			t.Errorf("expected %q, got %q", part, result)
+		}
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF after the end marker, got %v", err)
+	}
+
+	result, err := ReadBuff(&conn)
+	if err != nil || string(result) != "next message" {
+		t.Errorf("message after the stream was not read back: %q, %v", result, err)
+	}
+}
+
+func TestStreamReaderRejectsMessageFrame(t *testing.T) {
+	var conn bytes.Buffer
+	if err := WriteBuff(&conn, 64, []byte("message")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewStreamReader(&conn, DefaultMaxMessageSize).Next(); !errors.Is(err, ErrUnexpectedFrame) {
+		t.Errorf("expected ErrUnexpectedFrame, got %v", err)
+	}
+}
+
+func FuzzReadBuff(f *testing.F) {
+	f.Add(frame(Magic, Version, 4, 5, []byte("hello")))
+	f.Add(frame(Magic, Version, 2048, 0, nil))
+	f.Add(frame(Magic, Version, 1, 3, []byte("ab")))
+	f.Add(frame(Magic, Version, 0x7fffffff, 0x7fffffff, nil))
+	f.Add([]byte{Magic})
+
+	const maxMessageSize = 4096
+	f.Fuzz(func(t *testing.T, data []byte) {
+		result, err := ReadBuffLimit(bytes.NewBuffer(data), maxMessageSize)
+		if err != nil {
+			return
+		}
+
+		if len(result) > maxMessageSize {
+			t.Fatalf("read %v bytes, limit is %v", len(result), maxMessageSize)
+		}
+
+		var conn bytes.Buffer
+		if err = WriteBuff(&conn, 16, result); err != nil {
+			t.Fatal(err)
+		}
+
+		again, err := ReadBuffLimit(&conn, maxMessageSize)
+		if err != nil || !bytes.Equal(again, result) {
+			t.Fatalf("payload did not survive a round trip: %v", err)
+		}
+	})
+}
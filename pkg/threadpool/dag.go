@@ -0,0 +1,359 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDuplicateStep     = errors.New("step is already added")
+	ErrUnknownDependency = errors.New("step depends on an unknown step")
+	ErrDependencyCycle   = errors.New("steps depend on each other in a cycle")
+	ErrDependencyFailed  = errors.New("dependency did not complete")
+)
+
+type StepStatus int
+
+const (
+	StepCompleted StepStatus = iota
+	StepFailed
+	// StepSkipped is a step that never ran, because a dependency did not
+	// complete or the graph was cancelled.
+	StepSkipped
+)
+
+func (status StepStatus) String() string {
+	switch status {
+	case StepCompleted:
+		return "completed"
+	case StepFailed:
+		return "failed"
+	case StepSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
+type step struct {
+	name      string
+	runFunc   RunFunc
+	dependsOn []string
+	// dependents are the steps depending on this one, set by Validate.
+	dependents []string
+}
+
+// Graph is a set of steps that run on a thread pool once the steps they
+// depend on have completed. Steps without a path between them run
+// concurrently. A graph is built and run by one goroutine.
+type Graph struct {
+	steps    map[string]*step
+	order    []string
+	priority Priority
+	taskType string
+}
+
+func NewGraph() *Graph {
+	return &Graph{
+		steps:    make(map[string]*step),
+		priority: PriorityNormal,
+		taskType: "graph step",
+	}
+}
+
+// SetPriority sets the priority every step is queued with.
+func (graph *Graph) SetPriority(priority Priority) error {
+	if err := priority.Validate(); err != nil {
+		return err
+	}
+
+	graph.priority = priority
+	return nil
+}
+
+// SetType sets the type of the tasks of the steps, see Task.SetType.
+func (graph *Graph) SetType(taskType string) {
+	graph.taskType = taskType
+}
+
+// AddStep adds a step that runs runFunc once every step of dependsOn has
+// completed. Dependencies may be added after the step that names them.
+func (graph *Graph) AddStep(name string, runFunc RunFunc, dependsOn ...string) error {
+	if _, ok := graph.steps[name]; ok {
+		return fmt.Errorf("%w: %v", ErrDuplicateStep, name)
+	}
+
+	var deps []string
+	for _, dep := range dependsOn {
+		if !slices.Contains(deps, dep) {
+			deps = append(deps, dep)
+		}
+	}
+
+	graph.steps[name] = &step{
+		name:      name,
+		runFunc:   runFunc,
+		dependsOn: deps,
+	}
+	graph.order = append(graph.order, name)
+	return nil
+}
+
+// Validate checks that every dependency is a step of the graph and that
+// no step depends on itself, directly or through others.
+func (graph *Graph) Validate() error {
+	for _, name := range graph.order {
+		graph.steps[name].dependents = nil
+	}
+
+	waiting := make(map[string]int, len(graph.steps))
+	for _, name := range graph.order {
+		current := graph.steps[name]
+		for _, dep := range current.dependsOn {
+			dependency, ok := graph.steps[dep]
+			if !ok {
+				return fmt.Errorf("%w: %v depends on %v", ErrUnknownDependency, name, dep)
+			}
+			dependency.dependents = append(dependency.dependents, name)
+		}
+		waiting[name] = len(current.dependsOn)
+	}
+
+	// Steps left waiting once every step that can complete has are on
+	// a cycle or depend on one.
+	ready := graph.readySteps(waiting)
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		delete(waiting, name)
+
+		for _, dependent := range graph.steps[name].dependents {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(waiting) > 0 {
+		var names []string
+		for name := range waiting {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("%w: %v", ErrDependencyCycle, strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+func (graph *Graph) readySteps(waiting map[string]int) []string {
+	var ready []string
+	for _, name := range graph.order {
+		if waiting[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	return ready
+}
+
+// StepTrace is how one step of a graph went. StartedAt and FinishedAt are
+// zero for a step that never ran.
+type StepTrace struct {
+	Name       string
+	DependsOn  []string
+	Status     StepStatus
+	Err        error
+	QueuedAt   time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Trace lists the steps of a graph run in the order they finished.
+type Trace struct {
+	Steps []StepTrace
+}
+
+// Step returns the trace of the named step.
+func (trace *Trace) Step(name string) (StepTrace, bool) {
+	for _, stepTrace := range trace.Steps {
+		if stepTrace.Name == name {
+			return stepTrace, true
+		}
+	}
+
+	return StepTrace{}, false
+}
+
+func (trace *Trace) String() string {
+	var builder strings.Builder
+	for _, stepTrace := range trace.Steps {
+		fmt.Fprintf(&builder, "%v: %v", stepTrace.Name, stepTrace.Status)
+		if !stepTrace.StartedAt.IsZero() {
+			fmt.Fprintf(&builder, " in %v", stepTrace.FinishedAt.Sub(stepTrace.StartedAt))
+		}
+		if stepTrace.Err != nil {
+			fmt.Fprintf(&builder, ": %v", stepTrace.Err)
+		}
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+// graphRun is the state of one Run, owned by the goroutine of Run.
+type graphRun struct {
+	graph    *Graph
+	executor Executor
+	ctx      context.Context
+	waiting  map[string]int
+	done     map[string]bool
+	traces   map[string]*StepTrace
+	results  chan StepTrace
+	// pending counts the steps added to the executor that have not
+	// reported back yet.
+	pending int
+	taskIds int64
+	trace   *Trace
+	err     error
+}
+
+// Run validates the graph and runs its steps on executor, a step as soon
+// as its dependencies have completed. A step that fails, or that the
+// executor does not take, skips every step depending on it. Cancelling
+// ctx cancels the running steps and skips the ones not started yet. Run
+// returns once every step has finished or was skipped, with the error of
+// the first step that did not complete.
+func (graph *Graph) Run(ctx context.Context, executor Executor) (*Trace, error) {
+	if err := graph.Validate(); err != nil {
+		return nil, err
+	}
+
+	run := &graphRun{
+		graph:    graph,
+		executor: executor,
+		ctx:      ctx,
+		waiting:  make(map[string]int, len(graph.steps)),
+		done:     make(map[string]bool, len(graph.steps)),
+		traces:   make(map[string]*StepTrace, len(graph.steps)),
+		results:  make(chan StepTrace, len(graph.steps)),
+		trace:    &Trace{},
+	}
+	for _, name := range graph.order {
+		run.waiting[name] = len(graph.steps[name].dependsOn)
+		run.traces[name] = &StepTrace{
+			Name:      name,
+			DependsOn: graph.steps[name].dependsOn,
+		}
+	}
+
+	for _, name := range graph.readySteps(run.waiting) {
+		run.start(name)
+	}
+
+	for run.pending > 0 {
+		result := <-run.results
+		run.pending--
+		run.complete(result)
+	}
+
+	return run.trace, run.err
+}
+
+func (run *graphRun) start(name string) {
+	if run.done[name] {
+		return
+	}
+
+	stepTrace := run.traces[name]
+	stepTrace.QueuedAt = time.Now()
+	if err := run.ctx.Err(); err != nil {
+		result := *stepTrace
+		result.Err = err
+		run.complete(result)
+		return
+	}
+
+	current := run.graph.steps[name]
+	run.taskIds++
+	task := NewTask(run.taskIds, func(ctx context.Context) error {
+		result := *stepTrace
+		result.StartedAt = time.Now()
+		result.Err = CatchPanic(func() error {
+			return current.runFunc(ctx)
+		})
+		result.FinishedAt = time.Now()
+		run.results <- result
+		return result.Err
+	})
+	task.SetContext(run.ctx)
+	task.Priority = run.graph.priority
+	task.SetType(run.graph.taskType)
+	task.SetOnSkip(func(err error) {
+		result := *stepTrace
+		result.Err = err
+		run.results <- result
+	})
+
+	if err := run.executor.AddTask(task); err != nil {
+		result := *stepTrace
+		result.Err = err
+		run.complete(result)
+		return
+	}
+	run.pending++
+}
+
+// complete records a step that ran, or was dropped before it could, and
+// starts the dependents it was the last dependency of. A step that did
+// not complete skips its dependents instead.
+func (run *graphRun) complete(result StepTrace) {
+	switch {
+	case result.Err == nil:
+		result.Status = StepCompleted
+	case result.StartedAt.IsZero():
+		result.Status = StepSkipped
+	default:
+		result.Status = StepFailed
+	}
+	run.record(result)
+
+	if result.Err != nil {
+		if run.err == nil {
+			run.err = fmt.Errorf("step %v: %w", result.Name, result.Err)
+		}
+		run.skipDependents(result.Name)
+		return
+	}
+
+	for _, dependent := range run.graph.steps[result.Name].dependents {
+		run.waiting[dependent]--
+		if run.waiting[dependent] == 0 {
+			run.start(dependent)
+		}
+	}
+}
+
+func (run *graphRun) skipDependents(name string) {
+	for _, dependent := range run.graph.steps[name].dependents {
+		if run.done[dependent] {
+			continue
+		}
+
+		result := *run.traces[dependent]
+		result.Status = StepSkipped
+		result.Err = fmt.Errorf("%w: %v", ErrDependencyFailed, name)
+		run.record(result)
+		run.skipDependents(dependent)
+	}
+}
+
+func (run *graphRun) record(result StepTrace) {
+	run.done[result.Name] = true
+	run.trace.Steps = append(run.trace.Steps, result)
+}
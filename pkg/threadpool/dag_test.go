@@ -0,0 +1,190 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newGraphPool(t *testing.T) *ThreadPool {
+	pool := New(logs)
+	pool.MustRun(4)
+	t.Cleanup(pool.MustTerminate)
+	return pool
+}
+
+func TestGraphRunsStepsInDependencyOrder(t *testing.T) {
+	pool := newGraphPool(t)
+
+	// truncate and merge wait for each other, which only works if they run
+	// at the same time.
+	var branches sync.WaitGroup
+	branches.Add(2)
+	branch := func(ctx context.Context) error {
+		branches.Done()
+		done := make(chan struct{})
+		go func() {
+			branches.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("branches did not run concurrently")
+		}
+	}
+
+	graph := NewGraph()
+	for _, err := range []error{
+		graph.AddStep("report", noop, "truncate", "merge"),
+		graph.AddStep("snapshot", noop),
+		graph.AddStep("truncate", branch, "snapshot"),
+		graph.AddStep("merge", branch, "snapshot", "snapshot"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trace, err := graph.Run(context.Background(), pool)
+	if err != nil {
+		t.Fatalf("graph failed: %v\n%v", err, trace)
+	}
+
+	if len(trace.Steps) != 4 || trace.Steps[0].Name != "snapshot" || trace.Steps[3].Name != "report" {
+		t.Fatalf("expected snapshot first and report last, got\n%v", trace)
+	}
+
+	snapshot, _ := trace.Step("snapshot")
+	report, _ := trace.Step("report")
+	for _, name := range []string{"truncate", "merge"} {
+		stepTrace, ok := trace.Step(name)
+		if !ok || stepTrace.Status != StepCompleted {
+			t.Errorf("%v: expected the step to complete, got %+v", name, stepTrace)
+		}
+		if stepTrace.StartedAt.Before(snapshot.FinishedAt) || report.StartedAt.Before(stepTrace.FinishedAt) {
+			t.Errorf("%v: ran out of dependency order\n%v", name, trace)
+		}
+	}
+}
+
+func TestGraphFailureSkipsDependents(t *testing.T) {
+	pool := newGraphPool(t)
+	errSnapshot := errors.New("disk is full")
+
+	graph := NewGraph()
+	_ = graph.AddStep("snapshot", func(ctx context.Context) error { return errSnapshot })
+	_ = graph.AddStep("truncate", noop, "snapshot")
+	_ = graph.AddStep("report", noop, "truncate")
+	_ = graph.AddStep("merge", func(ctx context.Context) error { panic("corrupt segment") })
+	_ = graph.AddStep("cleanup", noop)
+
+	trace, err := graph.Run(context.Background(), pool)
+	if !errors.Is(err, errSnapshot) && !errors.Is(err, ErrTaskPanicked) {
+		t.Errorf("expected the error of a failed step, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		status StepStatus
+		err    error
+	}{
+		{"snapshot", StepFailed, errSnapshot},
+		{"truncate", StepSkipped, ErrDependencyFailed},
+		{"report", StepSkipped, ErrDependencyFailed},
+		{"merge", StepFailed, ErrTaskPanicked},
+		{"cleanup", StepCompleted, nil},
+	}
+
+	for _, test := range tests {
+		stepTrace, ok := trace.Step(test.name)
+		if !ok || stepTrace.Status != test.status || !errors.Is(stepTrace.Err, test.err) {
+			t.Errorf("%v: expected %v with %v, got %+v", test.name, test.status, test.err, stepTrace)
+		}
+	}
+}
+
+func TestGraphCancellation(t *testing.T) {
+	pool := newGraphPool(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	graph := NewGraph()
+	_ = graph.AddStep("snapshot", func(ctx context.Context) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	_ = graph.AddStep("truncate", noop, "snapshot")
+
+	trace, err := graph.Run(ctx, pool)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if stepTrace, _ := trace.Step("truncate"); stepTrace.Status != StepSkipped || !stepTrace.StartedAt.IsZero() {
+		t.Errorf("expected the dependent to be skipped, got %+v", stepTrace)
+	}
+
+	// A graph run with a cancelled context starts no step at all.
+	trace, err = graph.Run(ctx, pool)
+	if !errors.Is(err, context.Canceled) || len(trace.Steps) != 2 {
+		t.Errorf("expected both steps skipped with context.Canceled, got %v\n%v", err, trace)
+	}
+}
+
+func TestGraphRefusedStep(t *testing.T) {
+	graph := NewGraph()
+	_ = graph.AddStep("snapshot", noop)
+	_ = graph.AddStep("report", noop, "snapshot")
+
+	trace, err := graph.Run(context.Background(), New(logs))
+	if !errors.Is(err, ErrTaskNotAdded) {
+		t.Errorf("expected ErrTaskNotAdded from a pool that is not running, got %v", err)
+	}
+	if stepTrace, _ := trace.Step("report"); !errors.Is(stepTrace.Err, ErrDependencyFailed) {
+		t.Errorf("expected the dependent to be skipped, got %+v", stepTrace)
+	}
+}
+
+func TestGraphRejectsInvalidDependencies(t *testing.T) {
+	ran := false
+	step := func(ctx context.Context) error {
+		ran = true
+		return nil
+	}
+
+	graph := NewGraph()
+	_ = graph.AddStep("start", step)
+	_ = graph.AddStep("a", step, "start", "c")
+	_ = graph.AddStep("b", step, "a")
+	_ = graph.AddStep("c", step, "b")
+	_ = graph.AddStep("after", step, "c")
+
+	if err := graph.AddStep("a", step); !errors.Is(err, ErrDuplicateStep) {
+		t.Errorf("expected ErrDuplicateStep, got %v", err)
+	}
+
+	_, err := graph.Run(context.Background(), newGraphPool(t))
+	if !errors.Is(err, ErrDependencyCycle) || err.Error() != "steps depend on each other in a cycle: a, after, b, c" {
+		t.Errorf("expected a cycle error naming its steps, got %v", err)
+	}
+	if ran {
+		t.Error("no step should run in a graph with a cycle")
+	}
+
+	self := NewGraph()
+	_ = self.AddStep("self", step, "self")
+	if err = self.Validate(); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("expected a step depending on itself to be a cycle, got %v", err)
+	}
+
+	unknown := NewGraph()
+	_ = unknown.AddStep("report", step, "missing")
+	if err = unknown.Validate(); !errors.Is(err, ErrUnknownDependency) {
+		t.Errorf("expected ErrUnknownDependency, got %v", err)
+	}
+}
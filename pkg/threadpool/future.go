@@ -0,0 +1,177 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+var (
+	ErrFutureNotDone = errors.New("future is not done yet")
+	ErrNoFutures     = errors.New("no futures to wait for")
+)
+
+type Executor interface {
+	AddTask(task *Task) error
+}
+
+// futureIds numbers the tasks of futures, which have no caller given id.
+var futureIds atomic.Int64
+
+// Future is the result of a function running on a thread pool.
+type Future[T any] struct {
+	task  *Task
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// Submit runs fn on pool and returns its future. A pool that does not
+// accept the task fails the future with the error of AddTask right away.
+func Submit[T any](pool Executor, fn func(ctx context.Context) (T, error)) *Future[T] {
+	return SubmitContext(context.Background(), pool, fn)
+}
+
+// SubmitContext is Submit with the task running in ctx. A ctx done before
+// a worker picks the task up fails the future with ctx.Err() without
+// running fn.
+func SubmitContext[T any](ctx context.Context, pool Executor, fn func(ctx context.Context) (T, error)) *Future[T] {
+	future := &Future[T]{
+		done: make(chan struct{}),
+	}
+
+	// A panic of fn fails the future instead of leaving it pending.
+	task := NewTask(futureIds.Add(1), func(ctx context.Context) error {
+		var value T
+		err := CatchPanic(func() (err error) {
+			value, err = fn(ctx)
+			return err
+		})
+		future.complete(value, err)
+		return err
+	})
+	task.SetContext(ctx)
+	task.SetOnSkip(func(err error) {
+		future.complete(*new(T), err)
+	})
+	future.task = task
+
+	if err := pool.AddTask(task); err != nil {
+		future.complete(*new(T), err)
+	}
+
+	return future
+}
+
+func (future *Future[T]) complete(value T, err error) {
+	future.value, future.err = value, err
+	close(future.done)
+}
+
+// Cancel cancels the context of the function. A function still queued
+// is skipped and fails the future with context.Canceled.
+func (future *Future[T]) Cancel() {
+	future.task.Cancel()
+}
+
+// Done is closed once the result is ready.
+func (future *Future[T]) Done() <-chan struct{} {
+	return future.done
+}
+
+// Wait blocks until the result is ready and returns it. A function that
+// was still queued when the pool was terminated fails with ErrPoolTerminated.
+func (future *Future[T]) Wait() (T, error) {
+	<-future.done
+	return future.value, future.err
+}
+
+// WaitContext is Wait that gives up with ctx.Err() once ctx is done,
+// the function keeps running.
+func (future *Future[T]) WaitContext(ctx context.Context) (T, error) {
+	select {
+	case <-future.done:
+		return future.value, future.err
+	case <-ctx.Done():
+		return *new(T), ctx.Err()
+	}
+}
+
+// Result returns the result without blocking, ErrFutureNotDone while
+// the function is still queued or running.
+func (future *Future[T]) Result() (T, error) {
+	select {
+	case <-future.done:
+		return future.value, future.err
+	default:
+		return *new(T), ErrFutureNotDone
+	}
+}
+
+// WaitAll waits for every future and returns their values in order along
+// with the first error in that order, or ctx.Err() once ctx is done.
+func WaitAll[T any](ctx context.Context, futures ...*Future[T]) ([]T, error) {
+	values := make([]T, len(futures))
+
+	var firstErr error
+	for idx, future := range futures {
+		value, err := future.WaitContext(ctx)
+		if ctx.Err() != nil {
+			return values, ctx.Err()
+		}
+
+		values[idx] = value
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return values, firstErr
+}
+
+// WaitAny waits for the first of futures to finish and returns its index
+// and result. It returns -1 with ctx.Err() once ctx is done, or with
+// ErrNoFutures when there are none.
+func WaitAny[T any](ctx context.Context, futures ...*Future[T]) (int, T, error) {
+	if len(futures) == 0 {
+		return -1, *new(T), ErrNoFutures
+	}
+
+	for idx, future := range futures {
+		if future.isDone() {
+			value, err := future.Wait()
+			return idx, value, err
+		}
+	}
+
+	finished := make(chan int, len(futures))
+	stop := make(chan struct{})
+	defer close(stop)
+
+	for idx, future := range futures {
+		go func() {
+			select {
+			case <-future.done:
+				finished <- idx
+			case <-stop:
+			}
+		}()
+	}
+
+	select {
+	case idx := <-finished:
+		value, err := futures[idx].Wait()
+		return idx, value, err
+	case <-ctx.Done():
+		return -1, *new(T), ctx.Err()
+	}
+}
+
+func (future *Future[T]) isDone() bool {
+	select {
+	case <-future.done:
+		return true
+	default:
+		return false
+	}
+}
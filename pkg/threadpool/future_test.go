@@ -0,0 +1,133 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubmitReturnsResult(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(2)
+	defer pool.MustTerminate()
+
+	errOdd := errors.New("odd")
+	futures := make([]*Future[int], 10)
+	for i := range futures {
+		futures[i] = Submit(pool, func(ctx context.Context) (int, error) {
+			if i%2 == 1 {
+				return 0, errOdd
+			}
+			return i * i, nil
+		})
+	}
+
+	for i, future := range futures {
+		value, err := future.Wait()
+		if i%2 == 1 && !errors.Is(err, errOdd) {
+			t.Errorf("future %v: expected errOdd, got %v", i, err)
+		}
+		if i%2 == 0 && (err != nil || value != i*i) {
+			t.Errorf("future %v: expected %v, got %v, %v", i, i*i, value, err)
+		}
+	}
+
+	values, err := WaitAll(context.Background(), futures...)
+	if !errors.Is(err, errOdd) || values[4] != 16 {
+		t.Errorf("expected the values with errOdd, got %v, %v", values, err)
+	}
+}
+
+func TestFutureWaitContextAndResult(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	release := make(chan struct{})
+	slow := Submit(pool, func(ctx context.Context) (string, error) {
+		<-release
+		return "slow", nil
+	})
+
+	if _, err := slow.Result(); !errors.Is(err, ErrFutureNotDone) {
+		t.Errorf("expected ErrFutureNotDone, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := slow.WaitContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to time out, got %v", err)
+	}
+
+	// The only worker is busy, the cancelled task fails without running.
+	cancelled, cancelQueued := context.WithCancel(context.Background())
+	queued := SubmitContext(cancelled, pool, func(ctx context.Context) (string, error) {
+		t.Error("cancelled task should not run")
+		return "", nil
+	})
+	cancelQueued()
+	close(release)
+
+	if value, err := slow.Wait(); value != "slow" || err != nil {
+		t.Errorf("expected slow, got %v, %v", value, err)
+	}
+	if _, err := queued.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	select {
+	case <-slow.Done():
+	default:
+		t.Error("Done should be closed after Wait returned")
+	}
+}
+
+func TestWaitAny(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(3)
+	defer pool.MustTerminate()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	futures := []*Future[int]{
+		Submit(pool, func(ctx context.Context) (int, error) { <-release; return 0, nil }),
+		Submit(pool, func(ctx context.Context) (int, error) { return 1, nil }),
+		Submit(pool, func(ctx context.Context) (int, error) { <-release; return 2, nil }),
+	}
+
+	idx, value, err := WaitAny(context.Background(), futures...)
+	if idx != 1 || value != 1 || err != nil {
+		t.Errorf("expected the second future, got %v, %v, %v", idx, value, err)
+	}
+
+	if idx, _, err = WaitAny[int](context.Background()); idx != -1 || !errors.Is(err, ErrNoFutures) {
+		t.Errorf("expected ErrNoFutures, got %v, %v", idx, err)
+	}
+}
+
+func TestSubmitToStoppedPool(t *testing.T) {
+	pool := New(logs)
+
+	future := Submit(pool, func(ctx context.Context) (int, error) { return 1, nil })
+	if _, err := future.Wait(); !errors.Is(err, ErrTaskNotAdded) {
+		t.Errorf("expected ErrTaskNotAdded, got %v", err)
+	}
+}
+
+func TestPanickingFutureFails(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	future := Submit(pool, func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := future.WaitContext(ctx); !errors.Is(err, ErrTaskPanicked) {
+		t.Errorf("expected ErrTaskPanicked, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package threadpool
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+var ErrTaskPanicked = errors.New("task panicked")
+
+// untypedTask counts the panics of tasks without a type.
+const untypedTask = "untyped"
+
+// PanicError is a panic recovered from a task, with the stack of the
+// goroutine that panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (panicErr *PanicError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrTaskPanicked, panicErr.Value)
+}
+
+func (panicErr *PanicError) Unwrap() error {
+	return ErrTaskPanicked
+}
+
+// CatchPanic runs fn and turns a panic into a *PanicError. Tasks run
+// through it already, callers use it to answer for a panic themselves,
+// returning the error lets the pool still count it.
+func CatchPanic(fn func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = &PanicError{Value: recovered, Stack: debug.Stack()}
+		}
+	}()
+
+	return fn()
+}
+
+// Panics returns how many tasks panicked, by task type.
+func (threadPool *ThreadPool) Panics() map[string]int64 {
+	threadPool.sync.commonLock.RLock()
+	defer threadPool.sync.commonLock.RUnlock()
+	return threadPool.panicsUnsafe()
+}
+
+func (threadPool *ThreadPool) panicsUnsafe() map[string]int64 {
+	panics := make(map[string]int64, len(threadPool.panics))
+	for taskType, count := range threadPool.panics {
+		panics[taskType] = count
+	}
+
+	return panics
+}
+
+func (threadPool *ThreadPool) countPanic(task *Task, panicErr *PanicError) {
+	taskType := task.Type
+	if taskType == "" {
+		taskType = untypedTask
+	}
+
+	msg := fmt.Sprintf("task [%v] of type %v panicked: %v\n%s", task.Id, taskType, panicErr.Value, panicErr.Stack)
+	threadPool.logger.Log(msg)
+
+	threadPool.sync.commonLock.Lock()
+	defer threadPool.sync.commonLock.Unlock()
+	threadPool.panics[taskType]++
+}
+
+// replaceWorker stands in for a worker killed by a panic outside of a task,
+// e.g. in a skip function. The task it ran, if any, is accounted for and
+// a new worker takes its place while the pool runs.
+func (threadPool *ThreadPool) replaceWorker(workerId int64, task *Task, recovered any) {
+	msg := fmt.Sprintf("worker [%v] panicked: %v\n%s", workerId, recovered, debug.Stack())
+	threadPool.logger.Log(msg)
+
+	threadPool.sync.commonLock.Lock()
+	defer threadPool.sync.commonLock.Unlock()
+
+	if task != nil {
+		threadPool.stats.failed++
+		threadPool.activeTasks--
+		threadPool.notifyDrainedUnsafe()
+	}
+
+	delete(threadPool.idleSince, workerId)
+	threadPool.workers--
+	if threadPool.IsWorkingUnsafe() {
+		threadPool.startWorkersUnsafe()
+	}
+}
@@ -0,0 +1,92 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPanickingTaskIsRecovered(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	for i := range 2 {
+		task := NewTask(int64(i), func(ctx context.Context) error {
+			panic("malformed file")
+		})
+		task.SetType("parser")
+		_ = pool.AddTask(task)
+	}
+
+	finished := make(chan struct{})
+	_ = pool.AddTask(NewTask(3, func(ctx context.Context) error {
+		close(finished)
+		return nil
+	}))
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("the worker did not survive the panics")
+	}
+
+	if panics := pool.Panics(); panics["parser"] != 2 {
+		t.Errorf("expected 2 panics of parser tasks, got %v", panics)
+	}
+}
+
+func TestPanicOutsideTaskReplacesWorker(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	release := make(chan struct{})
+	_ = pool.AddTask(NewTask(1, func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+
+	cancelled := NewTask(2, noop)
+	cancelled.SetOnSkip(func(err error) {
+		panic("broken skip function")
+	})
+	_ = pool.AddTask(cancelled)
+	cancelled.Cancel()
+	close(release)
+
+	finished := make(chan struct{})
+	_ = pool.AddTask(NewTask(3, func(ctx context.Context) error {
+		close(finished)
+		return nil
+	}))
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("the dead worker was not replaced")
+	}
+
+	if size := pool.Size(); size.Workers != 1 {
+		t.Errorf("expected the pool to keep its worker, got %+v", size)
+	}
+}
+
+func TestCatchPanic(t *testing.T) {
+	err := CatchPanic(func() error {
+		panic("boom")
+	})
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || !errors.Is(err, ErrTaskPanicked) {
+		t.Fatalf("expected a PanicError, got %v", err)
+	}
+	if panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Errorf("expected the panic value and a stack, got %+v", panicErr)
+	}
+
+	if err = CatchPanic(func() error { return nil }); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package threadpool
+
+import "errors"
+
+var ErrInvalidPriority = errors.New("invalid task priority")
+
+// Priority is the class a task is scheduled in. Higher classes run first,
+// tasks of one class run in the order they were added.
+type Priority int
+
+const (
+	// PriorityLow is for background work, such as indexing jobs.
+	PriorityLow Priority = iota
+	// PriorityNormal is the default, e.g. for searches.
+	PriorityNormal
+	// PriorityHigh is for short requests that should never wait behind
+	// others, such as health checks.
+	PriorityHigh
+
+	priorityCount = int(PriorityHigh) + 1
+)
+
+func (priority Priority) Validate() error {
+	switch priority {
+	case PriorityLow, PriorityNormal, PriorityHigh:
+		return nil
+	default:
+		return ErrInvalidPriority
+	}
+}
+
+func (priority Priority) String() string {
+	switch priority {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
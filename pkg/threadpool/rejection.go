@@ -0,0 +1,58 @@
+package threadpool
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrQueueFull              = errors.New("task queue is full")
+	ErrInvalidRejectionPolicy = errors.New("invalid rejection policy")
+	ErrInvalidQueueCapacity   = errors.New("invalid task queue capacity")
+)
+
+// DefaultBlockTimeout is how long AddTask waits for room in a full queue
+// under PolicyBlock.
+const DefaultBlockTimeout = time.Second
+
+// RejectionPolicy decides what AddTask does when the queue of a bounded
+// pool is full.
+type RejectionPolicy int
+
+const (
+	// PolicyReject fails AddTask with ErrQueueFull.
+	PolicyReject RejectionPolicy = iota
+	// PolicyBlock makes AddTask wait for room up to the block timeout,
+	// then fail with ErrQueueFull.
+	PolicyBlock
+	// PolicyCallerRuns runs the task on the goroutine calling AddTask,
+	// which slows the caller down to the pace of the pool.
+	PolicyCallerRuns
+	// PolicyDropOldest skips the task that has been queued the longest with
+	// ErrQueueFull to make room for the new one.
+	PolicyDropOldest
+)
+
+func (policy RejectionPolicy) Validate() error {
+	switch policy {
+	case PolicyReject, PolicyBlock, PolicyCallerRuns, PolicyDropOldest:
+		return nil
+	default:
+		return ErrInvalidRejectionPolicy
+	}
+}
+
+func (policy RejectionPolicy) String() string {
+	switch policy {
+	case PolicyReject:
+		return "reject"
+	case PolicyBlock:
+		return "block"
+	case PolicyCallerRuns:
+		return "caller-runs"
+	case PolicyDropOldest:
+		return "drop-oldest"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,141 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newBusyPool returns a pool with one worker that is busy until release
+// is closed and a queue holding capacity tasks at most.
+func newBusyPool(t *testing.T, capacity int, policy RejectionPolicy) (*ThreadPool, chan struct{}) {
+	pool := New(logs)
+	if err := pool.SetQueueCapacity(capacity, policy); err != nil {
+		t.Fatal(err)
+	}
+	pool.MustRun(1)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	_ = pool.AddTask(NewTask(0, func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	<-started
+
+	return pool, release
+}
+
+func noop(ctx context.Context) error {
+	return nil
+}
+
+func TestRejectPolicy(t *testing.T) {
+	pool, release := newBusyPool(t, 2, PolicyReject)
+	defer pool.MustTerminate()
+	defer close(release)
+
+	for i := range 2 {
+		if err := pool.AddTask(NewTask(int64(i+1), noop)); err != nil {
+			t.Fatalf("task %v should fit in the queue, got %v", i+1, err)
+		}
+	}
+
+	if err := pool.AddTask(NewTask(3, noop)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestBlockPolicy(t *testing.T) {
+	pool, release := newBusyPool(t, 1, PolicyBlock)
+	defer pool.MustTerminate()
+	pool.SetBlockTimeout(30 * time.Millisecond)
+
+	_ = pool.AddTask(NewTask(1, noop))
+
+	start := time.Now()
+	if err := pool.AddTask(NewTask(2, noop)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull after the block timeout, got %v", err)
+	}
+	if waited := time.Since(start); waited < 30*time.Millisecond {
+		t.Errorf("AddTask should wait for the block timeout, waited %v", waited)
+	}
+
+	pool.SetBlockTimeout(time.Second)
+	time.AfterFunc(20*time.Millisecond, func() { close(release) })
+	if err := pool.AddTask(NewTask(3, noop)); err != nil {
+		t.Errorf("AddTask should get room once the worker is free, got %v", err)
+	}
+}
+
+func TestCallerRunsPolicy(t *testing.T) {
+	pool, release := newBusyPool(t, 1, PolicyCallerRuns)
+	defer pool.MustTerminate()
+	defer close(release)
+
+	_ = pool.AddTask(NewTask(1, noop))
+
+	ran := false
+	if err := pool.AddTask(NewTask(2, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ran {
+		t.Error("the task should have run on the caller before AddTask returned")
+	}
+}
+
+func TestDropOldestPolicy(t *testing.T) {
+	pool, release := newBusyPool(t, 2, PolicyDropOldest)
+	defer pool.MustTerminate()
+
+	skipped := make(chan error, 1)
+	oldest := NewTask(1, func(ctx context.Context) error {
+		t.Error("the dropped task should not run")
+		return nil
+	})
+	oldest.SetOnSkip(func(err error) { skipped <- err })
+
+	// The oldest task is dropped even though it has the higher priority.
+	_ = oldest.SetPriority(PriorityHigh)
+	_ = pool.AddTask(oldest)
+	_ = pool.AddTask(NewTask(2, noop))
+
+	ran := make(chan struct{})
+	if err := pool.AddTask(NewTask(3, func(ctx context.Context) error {
+		close(ran)
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-skipped:
+		if !errors.Is(err, ErrQueueFull) {
+			t.Errorf("expected ErrQueueFull, got %v", err)
+		}
+	default:
+		t.Error("the oldest task should be skipped before AddTask returns")
+	}
+
+	close(release)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("the new task did not run")
+	}
+}
+
+func TestInvalidQueueCapacity(t *testing.T) {
+	pool := New(logs)
+	if err := pool.SetQueueCapacity(-1, PolicyReject); !errors.Is(err, ErrInvalidQueueCapacity) {
+		t.Errorf("expected ErrInvalidQueueCapacity, got %v", err)
+	}
+	if err := pool.SetQueueCapacity(10, RejectionPolicy(42)); !errors.Is(err, ErrInvalidRejectionPolicy) {
+		t.Errorf("expected ErrInvalidRejectionPolicy, got %v", err)
+	}
+}
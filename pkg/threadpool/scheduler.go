@@ -0,0 +1,294 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/priorityqueue"
+)
+
+var (
+	ErrInvalidDelay        = errors.New("delay must not be negative")
+	ErrInvalidInterval     = errors.New("interval must be positive")
+	ErrInvalidJitter       = errors.New("jitter must not be negative")
+	ErrSchedulerNotRunning = errors.New("scheduler is not running")
+)
+
+// ScheduledTask is the handle of a task added to a Scheduler.
+type ScheduledTask struct {
+	task     *Task
+	interval time.Duration
+	// base is when the task is due without jitter, runAt when it fires.
+	// Both are guarded by the lock of the scheduler.
+	base    time.Time
+	runAt   time.Time
+	running atomic.Bool
+	runs    atomic.Int64
+	skipped atomic.Int64
+}
+
+// Cancel stops the task from running again. A run already added to the
+// pool is cancelled like any other task.
+func (scheduled *ScheduledTask) Cancel() {
+	scheduled.task.Cancel()
+}
+
+func (scheduled *ScheduledTask) IsCancelled() bool {
+	return scheduled.task.Context().Err() != nil
+}
+
+// Runs is how many times the task was added to the pool.
+func (scheduled *ScheduledTask) Runs() int64 {
+	return scheduled.runs.Load()
+}
+
+// Skipped is how many times the task was due while its previous run had
+// not finished yet, or the pool did not take it.
+func (scheduled *ScheduledTask) Skipped() int64 {
+	return scheduled.skipped.Load()
+}
+
+func (scheduled *ScheduledTask) isPeriodic() bool {
+	return scheduled.interval > 0
+}
+
+// newRun makes the task added to the pool for one run. It shares the
+// context of the scheduled task, so Cancel reaches every run.
+func (scheduled *ScheduledTask) newRun() *Task {
+	task := scheduled.task
+	run := NewTask(task.Id, func(ctx context.Context) error {
+		defer scheduled.running.Store(false)
+		return task.RunFunc(ctx)
+	})
+	run.SetContext(task.Context())
+	run.SetTimeout(task.timeout)
+	run.Priority = task.Priority
+	run.Type = task.Type
+	run.SetOnSkip(func(err error) {
+		scheduled.running.Store(false)
+		if task.onSkip != nil {
+			task.onSkip(err)
+		}
+	})
+
+	return run
+}
+
+// Scheduler adds tasks to an executor once a delay has passed or every
+// interval. Due times are kept in a heap watched by a single goroutine.
+// A periodic task whose previous run is still queued or running skips
+// the run instead of piling up behind it.
+type Scheduler struct {
+	executor  Executor
+	logger    Logger
+	timers    *priorityqueue.PriorityQueue[ScheduledTask]
+	jitter    time.Duration
+	wake      chan struct{}
+	lock      sync.Mutex
+	wg        sync.WaitGroup
+	isRunning bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func NewScheduler(executor Executor, logger Logger) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		executor: executor,
+		logger:   logger,
+		timers: priorityqueue.New(func(a, b *ScheduledTask) bool {
+			return a.runAt.After(b.runAt)
+		}),
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// SetJitter delays every run by a random duration below jitter, so tasks
+// scheduled together, e.g. by many servers started at once, spread out.
+// Periodic tasks keep their interval on average.
+func (scheduler *Scheduler) SetJitter(jitter time.Duration) error {
+	if jitter < 0 {
+		return ErrInvalidJitter
+	}
+
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+	scheduler.jitter = jitter
+	return nil
+}
+
+func (scheduler *Scheduler) MustRun() {
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+
+	if scheduler.isRunning || scheduler.ctx.Err() != nil {
+		log.Fatal("scheduler is already running or terminated")
+	}
+
+	scheduler.isRunning = true
+	scheduler.wg.Add(1)
+	go scheduler.watch()
+	scheduler.logger.Log("scheduler is running...")
+}
+
+// MustTerminate stops the scheduler, tasks that are not due yet never run.
+// Runs already added to the pool are left to it.
+func (scheduler *Scheduler) MustTerminate() {
+	scheduler.lock.Lock()
+	if !scheduler.isRunning {
+		scheduler.lock.Unlock()
+		return
+	}
+
+	scheduler.isRunning = false
+	scheduler.cancel()
+	scheduler.lock.Unlock()
+
+	scheduler.wg.Wait()
+	scheduler.logger.Log("scheduler terminated...")
+}
+
+// Schedule adds task to the executor once delay has passed.
+func (scheduler *Scheduler) Schedule(task *Task, delay time.Duration) (*ScheduledTask, error) {
+	if delay < 0 {
+		return nil, ErrInvalidDelay
+	}
+
+	return scheduler.add(task, delay, 0)
+}
+
+// ScheduleEvery adds task to the executor every interval, the first time
+// once interval has passed. The task runs until it is cancelled or the
+// scheduler terminates.
+func (scheduler *Scheduler) ScheduleEvery(task *Task, interval time.Duration) (*ScheduledTask, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	return scheduler.add(task, interval, interval)
+}
+
+func (scheduler *Scheduler) add(task *Task, delay, interval time.Duration) (*ScheduledTask, error) {
+	if err := task.Priority.Validate(); err != nil {
+		return nil, err
+	}
+
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+
+	if !scheduler.isRunning {
+		return nil, ErrSchedulerNotRunning
+	}
+
+	scheduled := &ScheduledTask{
+		task:     task,
+		interval: interval,
+		base:     time.Now().Add(delay),
+	}
+	scheduler.pushUnsafe(scheduled)
+	return scheduled, nil
+}
+
+// pushUnsafe queues the task for its base time plus jitter and wakes the
+// watcher, which may be waiting for a later task.
+func (scheduler *Scheduler) pushUnsafe(scheduled *ScheduledTask) {
+	scheduled.runAt = scheduled.base
+	if scheduler.jitter > 0 {
+		scheduled.runAt = scheduled.runAt.Add(rand.N(scheduler.jitter))
+	}
+	scheduler.timers.Push(scheduled)
+
+	select {
+	case scheduler.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (scheduler *Scheduler) watch() {
+	defer scheduler.wg.Done()
+
+	for {
+		due, wait := scheduler.takeDue(time.Now())
+		for _, scheduled := range due {
+			scheduler.fire(scheduled)
+		}
+
+		if len(due) > 0 {
+			continue
+		}
+
+		var timer <-chan time.Time
+		if wait >= 0 {
+			timer = time.After(wait)
+		}
+
+		select {
+		case <-timer:
+		case <-scheduler.wake:
+		case <-scheduler.ctx.Done():
+			return
+		}
+	}
+}
+
+// takeDue pops the tasks due by now and reschedules the periodic ones.
+// Without any due task it returns how long until the next one, or -1
+// when no task is scheduled.
+func (scheduler *Scheduler) takeDue(now time.Time) ([]*ScheduledTask, time.Duration) {
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+
+	var due []*ScheduledTask
+	for next := scheduler.timers.Peek(); next != nil && !next.runAt.After(now); next = scheduler.timers.Peek() {
+		scheduled := scheduler.timers.Pop()
+		if scheduled.IsCancelled() {
+			continue
+		}
+
+		due = append(due, scheduled)
+		if scheduled.isPeriodic() {
+			// A task that fell behind by more than an interval resumes from
+			// now instead of firing for every missed interval.
+			scheduled.base = scheduled.base.Add(scheduled.interval)
+			if scheduled.base.Before(now) {
+				scheduled.base = now.Add(scheduled.interval)
+			}
+			scheduler.pushUnsafe(scheduled)
+		}
+	}
+
+	if next := scheduler.timers.Peek(); len(due) == 0 && next != nil {
+		return due, next.runAt.Sub(now)
+	}
+
+	return due, -1
+}
+
+// fire adds a run of the task to the executor, unless the previous run
+// has not finished yet.
+func (scheduler *Scheduler) fire(scheduled *ScheduledTask) {
+	if !scheduled.running.CompareAndSwap(false, true) {
+		scheduled.skipped.Add(1)
+		msg := fmt.Sprintf("scheduled task [%v] is still running, skipping the run", scheduled.task.Id)
+		scheduler.logger.Log(msg)
+		return
+	}
+
+	if err := scheduler.executor.AddTask(scheduled.newRun()); err != nil {
+		scheduled.running.Store(false)
+		scheduled.skipped.Add(1)
+		msg := fmt.Sprintf("scheduled task [%v] was not added: %v", scheduled.task.Id, err)
+		scheduler.logger.Log(msg)
+		return
+	}
+
+	scheduled.runs.Add(1)
+}
@@ -0,0 +1,159 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestScheduler(t *testing.T) *Scheduler {
+	pool := New(logs)
+	pool.MustRun(2)
+	t.Cleanup(pool.MustTerminate)
+
+	scheduler := NewScheduler(pool, logs)
+	scheduler.MustRun()
+	t.Cleanup(scheduler.MustTerminate)
+	return scheduler
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition was not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduleRunsInOrderOfDelay(t *testing.T) {
+	scheduler := newTestScheduler(t)
+
+	var lock sync.Mutex
+	var order []int64
+	start := time.Now()
+	for id, delay := range []time.Duration{30, 10, 20} {
+		_, err := scheduler.Schedule(NewTask(int64(id), func(ctx context.Context) error {
+			lock.Lock()
+			defer lock.Unlock()
+			order = append(order, int64(id))
+			return nil
+		}), delay*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(order) == 3
+	})
+
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("tasks ran before their delay, after %v", elapsed)
+	}
+	if !slices.Equal(order, []int64{1, 2, 0}) {
+		t.Errorf("expected tasks to run in order of their delay, got %v", order)
+	}
+}
+
+func TestScheduleEveryUntilCancelled(t *testing.T) {
+	scheduler := newTestScheduler(t)
+
+	scheduled, err := scheduler.ScheduleEvery(NewTask(1, noop), 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool { return scheduled.Runs() >= 3 })
+	scheduled.Cancel()
+	if !scheduled.IsCancelled() {
+		t.Error("cancelled task should report it")
+	}
+
+	runs := scheduled.Runs()
+	time.Sleep(30 * time.Millisecond)
+	if scheduled.Runs() != runs {
+		t.Errorf("cancelled task kept running, %v runs after %v", scheduled.Runs(), runs)
+	}
+}
+
+func TestScheduleEverySkipsWhileRunning(t *testing.T) {
+	scheduler := newTestScheduler(t)
+
+	release := make(chan struct{})
+	scheduled, err := scheduler.ScheduleEvery(NewTask(1, func(ctx context.Context) error {
+		<-release
+		return nil
+	}), 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool { return scheduled.Skipped() >= 2 })
+	if runs := scheduled.Runs(); runs != 1 {
+		t.Errorf("expected a single run while it is blocked, got %v", runs)
+	}
+
+	close(release)
+	waitFor(t, func() bool { return scheduled.Runs() >= 2 })
+	scheduled.Cancel()
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	scheduler := NewScheduler(New(logs), logs)
+	scheduler.MustRun()
+	defer scheduler.MustTerminate()
+
+	const jitter = time.Hour
+	if err := scheduler.SetJitter(jitter); err != nil {
+		t.Fatal(err)
+	}
+
+	runAt := make(map[time.Time]bool)
+	for i := range 10 {
+		scheduled, err := scheduler.Schedule(NewTask(int64(i), noop), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		scheduler.lock.Lock()
+		delay := scheduled.runAt.Sub(scheduled.base)
+		runAt[scheduled.runAt] = true
+		scheduler.lock.Unlock()
+
+		if delay < 0 || delay >= jitter {
+			t.Errorf("expected a jitter below %v, got %v", jitter, delay)
+		}
+	}
+
+	if len(runAt) < 2 {
+		t.Error("expected jitter to spread the tasks out")
+	}
+}
+
+func TestSchedulerInvalidArguments(t *testing.T) {
+	scheduler := NewScheduler(New(logs), logs)
+	if _, err := scheduler.Schedule(NewTask(1, noop), 0); !errors.Is(err, ErrSchedulerNotRunning) {
+		t.Errorf("expected ErrSchedulerNotRunning, got %v", err)
+	}
+
+	scheduler.MustRun()
+	defer scheduler.MustTerminate()
+
+	if _, err := scheduler.Schedule(NewTask(1, noop), -time.Second); !errors.Is(err, ErrInvalidDelay) {
+		t.Errorf("expected ErrInvalidDelay, got %v", err)
+	}
+	if _, err := scheduler.ScheduleEvery(NewTask(1, noop), 0); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("expected ErrInvalidInterval, got %v", err)
+	}
+	if err := scheduler.SetJitter(-time.Second); !errors.Is(err, ErrInvalidJitter) {
+		t.Errorf("expected ErrInvalidJitter, got %v", err)
+	}
+}
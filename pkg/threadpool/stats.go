@@ -0,0 +1,153 @@
+package threadpool
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// histogramMin and histogramMax bound the durations histograms tell
+	// apart, shorter and longer ones fall into the first and last bucket.
+	histogramMin = time.Microsecond
+	histogramMax = time.Hour
+	// histogramGrowth is the ratio between the bounds of neighbouring
+	// buckets, quantiles are exact to within it.
+	histogramGrowth = 1.25
+)
+
+// histogramBounds are the upper bounds of the buckets, shared by every
+// histogram.
+var histogramBounds = func() []time.Duration {
+	var bounds []time.Duration
+	for bound := float64(histogramMin); bound < float64(histogramMax); bound *= histogramGrowth {
+		bounds = append(bounds, time.Duration(bound))
+	}
+
+	return append(bounds, histogramMax)
+}()
+
+// Percentiles summarize a histogram, zero while it is empty.
+type Percentiles struct {
+	Count int64
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Stats describe the pool since it was created. QueueWait is the time
+// tasks waited for a worker, RunTime the time they ran. Failed includes
+// the tasks that panicked, Skipped the ones dropped without running.
+type Stats struct {
+	Queued    int
+	Workers   int
+	Busy      int
+	Completed int64
+	Failed    int64
+	Skipped   int64
+	Rejected  int64
+	Panics    map[string]int64
+	QueueWait Percentiles
+	RunTime   Percentiles
+}
+
+type histogram struct {
+	buckets []int64
+	count   int64
+	max     time.Duration
+}
+
+func newHistogram() *histogram {
+	return &histogram{
+		buckets: make([]int64, len(histogramBounds)),
+	}
+}
+
+func (hist *histogram) record(duration time.Duration) {
+	idx := sort.Search(len(histogramBounds), func(i int) bool {
+		return histogramBounds[i] >= duration
+	})
+
+	hist.buckets[min(idx, len(hist.buckets)-1)]++
+	hist.count++
+	hist.max = max(hist.max, duration)
+}
+
+// quantile is the upper bound of the bucket holding the q-th duration,
+// at most the longest duration recorded. The last bucket has no upper
+// bound, its durations report the longest one.
+func (hist *histogram) quantile(q float64) time.Duration {
+	if hist.count == 0 {
+		return 0
+	}
+
+	rank := int64(q*float64(hist.count-1)) + 1
+	var seen int64
+	for idx, count := range hist.buckets[:len(hist.buckets)-1] {
+		seen += count
+		if seen >= rank {
+			return min(histogramBounds[idx], hist.max)
+		}
+	}
+
+	return hist.max
+}
+
+func (hist *histogram) percentiles() Percentiles {
+	return Percentiles{
+		Count: hist.count,
+		P50:   hist.quantile(0.50),
+		P95:   hist.quantile(0.95),
+		P99:   hist.quantile(0.99),
+		Max:   hist.max,
+	}
+}
+
+// poolStats are guarded by the lock of the pool.
+type poolStats struct {
+	completed int64
+	failed    int64
+	skipped   int64
+	rejected  int64
+	queueWait *histogram
+	runTime   *histogram
+}
+
+func newPoolStats() *poolStats {
+	return &poolStats{
+		queueWait: newHistogram(),
+		runTime:   newHistogram(),
+	}
+}
+
+func (stats *poolStats) recordRun(task *Task, timeTaken time.Duration, err error) {
+	if task.Status == CANCELLED {
+		stats.skipped++
+		return
+	}
+
+	stats.runTime.record(timeTaken)
+	if err != nil {
+		stats.failed++
+	} else {
+		stats.completed++
+	}
+}
+
+func (threadPool *ThreadPool) Stats() Stats {
+	threadPool.sync.commonLock.RLock()
+	defer threadPool.sync.commonLock.RUnlock()
+
+	return Stats{
+		Queued:    threadPool.mainTaskQueue.Size(),
+		Workers:   threadPool.workers,
+		Busy:      threadPool.activeTasks,
+		Completed: threadPool.stats.completed,
+		Failed:    threadPool.stats.failed,
+		Skipped:   threadPool.stats.skipped,
+		Rejected:  threadPool.stats.rejected,
+		Panics:    threadPool.panicsUnsafe(),
+		QueueWait: threadPool.stats.queueWait.percentiles(),
+		RunTime:   threadPool.stats.runTime.percentiles(),
+	}
+}
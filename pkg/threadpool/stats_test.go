@@ -0,0 +1,94 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantiles(t *testing.T) {
+	hist := newHistogram()
+	if quantile := hist.quantile(0.5); quantile != 0 {
+		t.Errorf("an empty histogram should report zero, got %v", quantile)
+	}
+
+	for i := 1; i <= 100; i++ {
+		hist.record(time.Duration(i) * time.Millisecond)
+	}
+
+	tests := []struct {
+		q        float64
+		expected time.Duration
+	}{
+		{0.50, 50 * time.Millisecond},
+		{0.95, 95 * time.Millisecond},
+		{0.99, 99 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		quantile := hist.quantile(test.q)
+		if quantile < test.expected || float64(quantile) > float64(test.expected)*histogramGrowth {
+			t.Errorf("p%v: expected %v within the bucket growth, got %v", test.q*100, test.expected, quantile)
+		}
+	}
+
+	if percentiles := hist.percentiles(); percentiles.Count != 100 || percentiles.Max != 100*time.Millisecond {
+		t.Errorf("expected 100 durations up to 100ms, got %+v", percentiles)
+	}
+
+	hist.record(2 * histogramMax)
+	if quantile := hist.quantile(1); quantile != 2*histogramMax {
+		t.Errorf("durations beyond the last bucket should report the max, got %v", quantile)
+	}
+}
+
+func TestStatsCountTasks(t *testing.T) {
+	pool := New(logs)
+	if err := pool.SetQueueCapacity(3, PolicyReject); err != nil {
+		t.Fatal(err)
+	}
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	_ = pool.AddTask(NewTask(0, func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	<-started
+
+	_ = pool.AddTask(NewTask(1, func(ctx context.Context) error {
+		return errors.New("failed")
+	}))
+	cancelled := NewTask(2, noop)
+	_ = pool.AddTask(cancelled)
+	cancelled.Cancel()
+	_ = pool.AddTask(NewTask(3, noop))
+	_ = pool.AddTask(NewTask(4, noop))
+
+	if stats := pool.Stats(); stats.Queued != 3 || stats.Busy != 1 || stats.Rejected != 1 {
+		t.Errorf("expected 3 queued, 1 busy and 1 rejected task, got %+v", stats)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := pool.Stats()
+	if stats.Completed != 2 || stats.Failed != 1 || stats.Skipped != 1 || stats.Queued != 0 || stats.Busy != 0 {
+		t.Errorf("expected 2 completed, 1 failed and 1 skipped task, got %+v", stats)
+	}
+	if stats.RunTime.Count != 3 || stats.RunTime.Max < 20*time.Millisecond {
+		t.Errorf("expected 3 run times with the blocking task the longest, got %+v", stats.RunTime)
+	}
+	if stats.QueueWait.Count != 4 || stats.QueueWait.P99 < 20*time.Millisecond {
+		t.Errorf("expected 4 queue waits of the tasks behind the blocking one, got %+v", stats.QueueWait)
+	}
+}
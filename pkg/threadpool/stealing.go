@@ -0,0 +1,327 @@
+package threadpool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// StealingPool runs tasks like ThreadPool, but every worker has a deque of
+// its own instead of sharing one queue under one lock. AddTask deals tasks
+// out to the deques in turn, a worker runs its tasks in order and, once its
+// deque is empty, steals half of the deque of another worker. Workers only
+// contend when they steal, which suits many small CPU-bound tasks such as
+// indexing files. Priorities and aging are not taken into account.
+type StealingPool struct {
+	logger  Logger
+	deques  []*deque
+	next    atomic.Uint64
+	wake    chan struct{}
+	pending atomic.Int64
+	wg      sync.WaitGroup
+	// lock guards the state of the pool, tasks are queued under its read lock.
+	lock          sync.RWMutex
+	isInitialized bool
+	isTerminated  bool
+	isDraining    bool
+	drained       chan struct{}
+	drainOnce     sync.Once
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+func NewStealingPool(logger Logger) *StealingPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &StealingPool{
+		logger:  logger,
+		drained: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (pool *StealingPool) IsWorking() bool {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+	return pool.isInitialized && !pool.isTerminated
+}
+
+func (pool *StealingPool) MustRun(threadCount int) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.isInitialized || pool.isTerminated {
+		log.Fatal("stealing pool is already initialized or terminated")
+	}
+
+	if threadCount < 1 {
+		log.Fatal("stealing pool needs at least one worker")
+	}
+
+	pool.deques = make([]*deque, threadCount)
+	for idx := range pool.deques {
+		pool.deques[idx] = &deque{}
+	}
+	pool.wake = make(chan struct{}, threadCount)
+
+	for idx := range pool.deques {
+		pool.wg.Add(1)
+		go pool.routineThread(idx)
+	}
+
+	pool.isInitialized = true
+	pool.logger.Log("stealing pool is running...")
+}
+
+// MustTerminate stops the workers and cancels the context of every running
+// task. Tasks still queued are skipped with ErrPoolTerminated.
+func (pool *StealingPool) MustTerminate() {
+	pool.lock.Lock()
+	if !pool.isInitialized || pool.isTerminated {
+		pool.lock.Unlock()
+		return
+	}
+
+	pool.isInitialized = false
+	pool.isTerminated = true
+	pool.cancel()
+	pool.lock.Unlock()
+
+	pool.wg.Wait()
+
+	for _, queue := range pool.deques {
+		for _, task := range queue.takeAll() {
+			task.skip(ErrPoolTerminated)
+		}
+	}
+
+	pool.logger.Log("stealing pool terminated...")
+}
+
+// Drain stops accepting new tasks and waits until every queued and running
+// task has finished, or until ctx is done.
+func (pool *StealingPool) Drain(ctx context.Context) error {
+	pool.lock.Lock()
+	if !pool.isInitialized || pool.isTerminated {
+		pool.lock.Unlock()
+		return nil
+	}
+	pool.isDraining = true
+	pool.lock.Unlock()
+
+	pool.notifyDrained()
+
+	select {
+	case <-pool.drained:
+		pool.logger.Log("stealing pool drained...")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (pool *StealingPool) notifyDrained() {
+	pool.lock.RLock()
+	draining := pool.isDraining
+	pool.lock.RUnlock()
+
+	if draining && pool.pending.Load() == 0 {
+		pool.drainOnce.Do(func() {
+			close(pool.drained)
+		})
+	}
+}
+
+// AddTask queues the task on the deque whose turn it is and wakes an idle
+// worker. A task with an invalid priority is not added.
+func (pool *StealingPool) AddTask(task *Task) error {
+	if err := task.Priority.Validate(); err != nil {
+		return err
+	}
+
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	if !pool.isInitialized || pool.isTerminated || pool.isDraining {
+		return ErrTaskNotAdded
+	}
+
+	pool.pending.Add(1)
+	idx := pool.next.Add(1) % uint64(len(pool.deques))
+	pool.deques[idx].pushBack(task)
+
+	select {
+	case pool.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (pool *StealingPool) routineThread(idx int) {
+	defer pool.wg.Done()
+
+	var current *Task
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			pool.replaceWorker(idx, current, recovered)
+		}
+	}()
+
+	for {
+		task := pool.nextTask(idx)
+		if task == nil {
+			return
+		}
+
+		current = task
+		if _, err := task.run(pool.ctx); err != nil && task.Status != CANCELLED {
+			msg := fmt.Sprintf("task [%v] failed with error: %v", task.Id, err)
+			pool.logger.Log(msg)
+		}
+		current = nil
+
+		pool.finishTask()
+	}
+}
+
+// nextTask takes the next task of the worker, steals one when its deque is
+// empty and waits for new tasks when there is nothing to steal either. It
+// returns nil once the pool is terminated.
+func (pool *StealingPool) nextTask(idx int) *Task {
+	own := pool.deques[idx]
+	for {
+		if pool.ctx.Err() != nil {
+			return nil
+		}
+
+		if task := own.popFront(); task != nil {
+			return task
+		}
+
+		if task := pool.steal(idx); task != nil {
+			return task
+		}
+
+		select {
+		case <-pool.wake:
+		case <-pool.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// steal moves half of the tasks of the first other deque that has any to
+// the deque of the worker and returns the first of them.
+func (pool *StealingPool) steal(idx int) *Task {
+	own := pool.deques[idx]
+	for offset := 1; offset < len(pool.deques); offset++ {
+		victim := pool.deques[(idx+offset)%len(pool.deques)]
+		stolen := victim.stealHalf()
+		if len(stolen) == 0 {
+			continue
+		}
+
+		for _, task := range stolen[1:] {
+			own.pushBack(task)
+		}
+		return stolen[0]
+	}
+
+	return nil
+}
+
+func (pool *StealingPool) finishTask() {
+	if pool.pending.Add(-1) == 0 {
+		pool.notifyDrained()
+	}
+}
+
+// replaceWorker starts a new worker in place of one killed by a panic
+// outside of a task.
+func (pool *StealingPool) replaceWorker(idx int, task *Task, recovered any) {
+	msg := fmt.Sprintf("worker [%v] panicked: %v\n%s", idx, recovered, debug.Stack())
+	pool.logger.Log(msg)
+
+	if task != nil {
+		pool.finishTask()
+	}
+
+	if pool.ctx.Err() == nil {
+		pool.wg.Add(1)
+		go pool.routineThread(idx)
+	}
+}
+
+// deque is a queue of tasks its worker takes from the front while thieves
+// take from the back.
+type deque struct {
+	lock  sync.Mutex
+	tasks []*Task
+	head  int
+}
+
+func (queue *deque) pushBack(task *Task) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	queue.tasks = append(queue.tasks, task)
+}
+
+func (queue *deque) popFront() *Task {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	if queue.head == len(queue.tasks) {
+		return nil
+	}
+
+	task := queue.tasks[queue.head]
+	queue.tasks[queue.head] = nil
+	queue.head++
+	queue.compact()
+	return task
+}
+
+// stealHalf takes the back half of the tasks, at least one.
+func (queue *deque) stealHalf() []*Task {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	size := len(queue.tasks) - queue.head
+	if size == 0 {
+		return nil
+	}
+
+	from := len(queue.tasks) - (size+1)/2
+	stolen := append([]*Task(nil), queue.tasks[from:]...)
+	clear(queue.tasks[from:])
+	queue.tasks = queue.tasks[:from]
+	queue.compact()
+	return stolen
+}
+
+func (queue *deque) takeAll() []*Task {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	tasks := queue.tasks[queue.head:]
+	queue.tasks, queue.head = nil, 0
+	return tasks
+}
+
+// compact drops the taken tasks from the front once they make up half of
+// the slice, or resets an empty deque.
+func (queue *deque) compact() {
+	if queue.head == len(queue.tasks) {
+		queue.tasks, queue.head = queue.tasks[:0], 0
+		return
+	}
+
+	if queue.head > len(queue.tasks)/2 {
+		queue.tasks = append(queue.tasks[:0], queue.tasks[queue.head:]...)
+		queue.head = 0
+	}
+}
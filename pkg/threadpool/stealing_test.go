@@ -0,0 +1,174 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pool is what callers of either pool rely on.
+type pool interface {
+	Executor
+	IsWorking() bool
+	MustRun(threadCount int)
+	MustTerminate()
+	Drain(ctx context.Context) error
+}
+
+var (
+	_ pool = (*ThreadPool)(nil)
+	_ pool = (*StealingPool)(nil)
+)
+
+func TestStealingPoolStealsFromBusyWorker(t *testing.T) {
+	pool := NewStealingPool(logs)
+	pool.MustRun(2)
+	defer pool.MustTerminate()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	if err := pool.AddTask(NewTask(0, func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	// Half of the tasks are queued behind the blocked worker, the other
+	// worker has to steal them.
+	var finished sync.WaitGroup
+	for i := range 20 {
+		finished.Add(1)
+		_ = pool.AddTask(NewTask(int64(i+1), func(ctx context.Context) error {
+			finished.Done()
+			return nil
+		}))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		finished.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("tasks queued behind a busy worker were not stolen")
+	}
+	close(release)
+}
+
+func TestStealingPoolDrain(t *testing.T) {
+	pool := NewStealingPool(logs)
+	pool.MustRun(4)
+	defer pool.MustTerminate()
+
+	finished := atomic.Int64{}
+	for i := range 100 {
+		task := NewTask(int64(i), func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			finished.Add(1)
+			return nil
+		})
+		if err := pool.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := pool.Drain(ctx); err != nil {
+		t.Fatalf("drain failed: %v", err)
+	}
+
+	if finished.Load() != 100 {
+		t.Errorf("expected all 100 tasks to finish before drain returns, got %v", finished.Load())
+	}
+
+	if err := pool.AddTask(NewTask(101, noop)); !errors.Is(err, ErrTaskNotAdded) {
+		t.Errorf("draining pool should reject tasks, got %v", err)
+	}
+}
+
+func TestStealingPoolTerminateSkipsQueuedTasks(t *testing.T) {
+	pool := NewStealingPool(logs)
+	pool.MustRun(1)
+
+	started := make(chan struct{})
+	_ = pool.AddTask(NewTask(0, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	<-started
+
+	future := Submit(pool, func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+
+	pool.MustTerminate()
+	if _, err := future.Wait(); !errors.Is(err, ErrPoolTerminated) {
+		t.Errorf("expected queued task to be skipped with ErrPoolTerminated, got %v", err)
+	}
+	if pool.IsWorking() {
+		t.Error("terminated pool should not be working")
+	}
+}
+
+func TestStealingPoolRecoversPanics(t *testing.T) {
+	pool := NewStealingPool(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	future := Submit(pool, func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	if _, err := future.Wait(); !errors.Is(err, ErrTaskPanicked) {
+		t.Errorf("expected ErrTaskPanicked, got %v", err)
+	}
+
+	if value, err := Submit(pool, func(ctx context.Context) (int, error) {
+		return 2, nil
+	}).Wait(); err != nil || value != 2 {
+		t.Errorf("worker should keep running after a panic, got %v, %v", value, err)
+	}
+}
+
+// benchmarkPool runs b.N small CPU-bound tasks on the pool.
+func benchmarkPool(b *testing.B, pool pool) {
+	pool.MustRun(8)
+	defer pool.MustTerminate()
+
+	var sink atomic.Int64
+	futures := make([]*Future[int], b.N)
+	b.ResetTimer()
+	for i := range futures {
+		futures[i] = Submit(pool, func(ctx context.Context) (int, error) {
+			sum := 0
+			for j := range 1000 {
+				sum += j * j
+			}
+			sink.Add(int64(sum))
+			return sum, nil
+		})
+	}
+
+	for _, future := range futures {
+		if _, err := future.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkThreadPool(b *testing.B) {
+	benchmarkPool(b, New(logs))
+}
+
+func BenchmarkStealingPool(b *testing.B) {
+	benchmarkPool(b, NewStealingPool(logs))
+}
@@ -1,33 +1,140 @@
 package threadpool
 
 import (
+	"context"
 	"time"
 )
 
-type RunFunc func() error
+type RunFunc func(ctx context.Context) error
 
 type Task struct {
 	Id        int64
 	CreatedAt time.Time
 	Status    TaskStatus
+	Priority  Priority
+	Type      string
 	RunFunc   RunFunc
+	timeout   time.Duration
+	ctx       context.Context
+	cancel    context.CancelFunc
+	onSkip    func(err error)
+	// queuedAt and queueSeq are set by the queue of the pool.
+	queuedAt time.Time
+	queueSeq uint64
 }
 
 func NewTask(id int64, runFunc RunFunc) *Task {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Task{
 		Id:        id,
 		CreatedAt: time.Now(),
 		Status:    IDLE,
+		Priority:  PriorityNormal,
 		RunFunc:   runFunc,
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 }
 
+// Run runs the task on the calling goroutine. A task whose context is
+// done already is skipped instead.
 func (task *Task) Run() (time.Duration, error) {
+	return task.run(context.Background())
+}
+
+// run runs the task with a context that is also cancelled with stop, the
+// context of the pool. A deadline or timeout cancels that context, RunFunc
+// has to return on its own and the worker stays with the task until it
+// does. A panic of RunFunc is returned as a *PanicError.
+func (task *Task) run(stop context.Context) (time.Duration, error) {
+	if err := task.ctx.Err(); err != nil {
+		task.skip(err)
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(task.ctx)
+	defer cancel()
+	defer context.AfterFunc(stop, cancel)()
+
+	if task.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, task.timeout)
+		defer cancel()
+	}
+
 	now := time.Now()
-	err := task.RunFunc()
+	err := CatchPanic(func() error {
+		return task.RunFunc(ctx)
+	})
 	return time.Since(now), err
 }
 
+// skip marks a task that is dropped without running and tells its owner.
+func (task *Task) skip(err error) {
+	task.Status = CANCELLED
+	if task.onSkip != nil {
+		task.onSkip(err)
+	}
+}
+
+// Cancel cancels the context of the task. A queued task is skipped,
+// a running one has to stop on its own once its context is done.
+func (task *Task) Cancel() {
+	task.cancel()
+}
+
+// Context is the context RunFunc runs with, without the timeout,
+// which only starts once a worker picks the task up.
+func (task *Task) Context() context.Context {
+	return task.ctx
+}
+
+// SetContext derives the context of the task from ctx, so the task is
+// cancelled along with it. Like every setter it has to be called before
+// the task is added to a pool.
+func (task *Task) SetContext(ctx context.Context) {
+	task.cancel()
+	task.ctx, task.cancel = context.WithCancel(ctx)
+}
+
+// SetDeadline cancels the task at deadline, whether it is still queued
+// or running by then.
+func (task *Task) SetDeadline(deadline time.Time) {
+	ctx, cancel := context.WithDeadline(task.ctx, deadline)
+	parentCancel := task.cancel
+	task.ctx, task.cancel = ctx, func() {
+		cancel()
+		parentCancel()
+	}
+}
+
+// SetTimeout cancels the context of the task once it has run for timeout,
+// counted from the moment a worker starts it. Zero means no bound.
+func (task *Task) SetTimeout(timeout time.Duration) {
+	task.timeout = timeout
+}
+
+// SetOnSkip sets a function called instead of RunFunc when the task is
+// dropped without running, because its context was done before a worker
+// picked it up or because the pool was terminated.
+func (task *Task) SetOnSkip(onSkip func(err error)) {
+	task.onSkip = onSkip
+}
+
+func (task *Task) SetPriority(priority Priority) error {
+	if err := priority.Validate(); err != nil {
+		return err
+	}
+
+	task.Priority = priority
+	return nil
+}
+
+// SetType groups the task with others of its kind, e.g. requests of one
+// route, in the panic counters of the pool.
+func (task *Task) SetType(taskType string) {
+	task.Type = taskType
+}
+
 func (task *Task) SetRunFunc(runFunc RunFunc) {
 	task.RunFunc = runFunc
 }
@@ -0,0 +1,117 @@
+package threadpool
+
+import (
+	"time"
+
+	linkedList "github.com/ArtemLymarenko/parallel-course-work/pkg/linked_list"
+)
+
+// DefaultAgingInterval is how long a task waits to be treated as one
+// priority class higher.
+const DefaultAgingInterval = 500 * time.Millisecond
+
+// taskQueue keeps a FIFO queue per priority class. Pop takes the task
+// whose priority, raised by one class for every aging interval it has
+// waited, is the highest, so low priority tasks run under load too.
+// Within a class the oldest task always has waited the longest, so only
+// the first task of every class has to be compared. It is not safe for
+// concurrent use, the pool guards it with its own lock.
+type taskQueue struct {
+	classes       [priorityCount]*linkedList.LinkedList[Task]
+	agingInterval time.Duration
+	size          int
+	pushed        uint64
+	now           func() time.Time
+}
+
+func newTaskQueue(agingInterval time.Duration) *taskQueue {
+	queue := &taskQueue{
+		agingInterval: agingInterval,
+		now:           time.Now,
+	}
+	for idx := range queue.classes {
+		queue.classes[idx] = linkedList.New[Task]()
+	}
+
+	return queue
+}
+
+func (queue *taskQueue) Size() int {
+	return queue.size
+}
+
+func (queue *taskQueue) Empty() bool {
+	return queue.size == 0
+}
+
+func (queue *taskQueue) Push(task *Task) {
+	queue.pushed++
+	task.queuedAt = queue.now()
+	task.queueSeq = queue.pushed
+
+	queue.classes[task.Priority].AddBack(task)
+	queue.size++
+}
+
+func (queue *taskQueue) Pop() *Task {
+	if queue.size == 0 {
+		return nil
+	}
+
+	now := queue.now()
+	var best *linkedList.LinkedList[Task]
+	bestPriority := 0
+	for _, class := range queue.classes {
+		task := class.Front()
+		if task == nil {
+			continue
+		}
+
+		priority := queue.effectivePriority(task, now)
+		if best == nil || priority > bestPriority ||
+			priority == bestPriority && task.queueSeq < best.Front().queueSeq {
+			best, bestPriority = class, priority
+		}
+	}
+
+	queue.size--
+	return best.RemoveFront()
+}
+
+// DropOldest removes the task that has been queued the longest, whatever
+// its class.
+func (queue *taskQueue) DropOldest() *Task {
+	var oldest *linkedList.LinkedList[Task]
+	for _, class := range queue.classes {
+		task := class.Front()
+		if task != nil && (oldest == nil || task.queueSeq < oldest.Front().queueSeq) {
+			oldest = class
+		}
+	}
+
+	if oldest == nil {
+		return nil
+	}
+
+	queue.size--
+	return oldest.RemoveFront()
+}
+
+// GetItems returns the queued tasks class by class, highest class first.
+func (queue *taskQueue) GetItems() []*Task {
+	items := make([]*Task, 0, queue.size)
+	for idx := len(queue.classes) - 1; idx >= 0; idx-- {
+		items = append(items, queue.classes[idx].ToSlice()...)
+	}
+
+	return items
+}
+
+func (queue *taskQueue) effectivePriority(task *Task, now time.Time) int {
+	priority := int(task.Priority)
+	if queue.agingInterval > 0 {
+		priority += int(now.Sub(task.queuedAt) / queue.agingInterval)
+	}
+
+	return priority
+}
@@ -0,0 +1,73 @@
+package threadpool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskQueueOrdersByPriorityThenAge(t *testing.T) {
+	queue := newTaskQueue(0)
+
+	priorities := []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityNormal, PriorityLow, PriorityHigh}
+	for idx, priority := range priorities {
+		task := NewTask(int64(idx), nil)
+		if err := task.SetPriority(priority); err != nil {
+			t.Fatal(err)
+		}
+		queue.Push(task)
+	}
+
+	expected := []int64{2, 5, 1, 3, 0, 4}
+	for _, id := range expected {
+		if task := queue.Pop(); task.Id != id {
+			t.Errorf("expected task %v, got %v", id, task.Id)
+		}
+	}
+
+	if !queue.Empty() || queue.Pop() != nil {
+		t.Error("queue should be empty")
+	}
+}
+
+func TestTaskQueueAgesLowPriorityTasks(t *testing.T) {
+	now := time.Now()
+	queue := newTaskQueue(time.Second)
+	queue.now = func() time.Time { return now }
+
+	low := NewTask(1, nil)
+	_ = low.SetPriority(PriorityLow)
+	queue.Push(low)
+
+	now = now.Add(2 * time.Second)
+	for id := int64(2); id < 5; id++ {
+		task := NewTask(id, nil)
+		_ = task.SetPriority(PriorityHigh)
+		queue.Push(task)
+	}
+
+	// Two intervals lift the low task to the high class, where it is the oldest.
+	if task := queue.Pop(); task.Id != 1 {
+		t.Errorf("expected the aged low priority task first, got %v", task.Id)
+	}
+
+	items := queue.GetItems()
+	if len(items) != 3 || items[0].Id != 2 {
+		t.Errorf("expected the high priority tasks in order, got %v items", len(items))
+	}
+}
+
+func TestInvalidPriorityIsRejected(t *testing.T) {
+	task := NewTask(1, nil)
+	if err := task.SetPriority(Priority(7)); err == nil {
+		t.Error("expected an invalid priority to be rejected")
+	}
+
+	pool := New(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	task.Priority = Priority(-1)
+	if err := pool.AddTask(task); err == nil {
+		t.Error("expected a task with an invalid priority not to be added")
+	}
+}
@@ -10,11 +10,12 @@ const (
 	IDLE TaskStatus = iota
 	PROCESSING
 	FINISHED
+	CANCELLED
 )
 
 func (taskStatus TaskStatus) Validate() error {
 	switch taskStatus {
-	case IDLE, PROCESSING, FINISHED:
+	case IDLE, PROCESSING, FINISHED, CANCELLED:
 		return nil
 	default:
 		return ErrInvalidTaskStatus
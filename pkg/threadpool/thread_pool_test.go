@@ -1,8 +1,11 @@
 package threadpool
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/ArtemLymarenko/parallel-course-work/pkg/mock"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -13,7 +16,7 @@ func TestRun(t *testing.T) {
 	pool := New(logs)
 	for range 50000 {
 		go func() {
-			task := NewTask(1, func() error {
+			task := NewTask(1, func(ctx context.Context) error {
 				time.Sleep(1 * time.Second)
 				return nil
 			})
@@ -24,3 +27,234 @@ func TestRun(t *testing.T) {
 	time.Sleep(4 * time.Second)
 	fmt.Println(pool.mainTaskQueue.Size())
 }
+
+func TestDrain(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(4)
+	defer pool.MustTerminate()
+
+	finished := atomic.Int64{}
+	for i := range 20 {
+		task := NewTask(int64(i), func(ctx context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			finished.Add(1)
+			return nil
+		})
+		if err := pool.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := pool.Drain(ctx); err != nil {
+		t.Fatalf("drain failed: %v", err)
+	}
+
+	if finished.Load() != 20 {
+		t.Errorf("expected all 20 tasks to finish before drain returns, got %v", finished.Load())
+	}
+
+	if err := pool.AddTask(NewTask(21, func(ctx context.Context) error { return nil })); !errors.Is(err, ErrTaskNotAdded) {
+		t.Errorf("draining pool should reject tasks, got %v", err)
+	}
+}
+
+func TestDrainDeadline(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	for i := range 5 {
+		_ = pool.AddTask(NewTask(int64(i), func(ctx context.Context) error {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := pool.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected drain to hit the deadline, got %v", err)
+	}
+}
+
+func TestDrainSkipsTasksThatAreNotIdle(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	release := make(chan struct{})
+	_ = pool.AddTask(NewTask(1, func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+
+	stale := NewTask(2, func(ctx context.Context) error { return nil })
+	if err := pool.AddTask(stale); err != nil {
+		t.Fatal(err)
+	}
+	_ = stale.SetStatus(CANCELLED)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.Drain(ctx); err != nil {
+		t.Fatalf("expected drain to finish once the stale task is discarded, got %v", err)
+	}
+
+	if stats := pool.Stats(); stats.Completed != 1 || stats.Skipped != 1 {
+		t.Errorf("expected 1 completed and 1 skipped task, got %+v", stats)
+	}
+}
+
+func TestCancelledTasksAreSkipped(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	release := make(chan struct{})
+	_ = pool.AddTask(NewTask(1, func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+
+	skipped := make(chan error, 2)
+	cancelled := NewTask(2, func(ctx context.Context) error {
+		t.Error("cancelled task should not run")
+		return nil
+	})
+	cancelled.SetOnSkip(func(err error) { skipped <- err })
+
+	expired := NewTask(3, func(ctx context.Context) error {
+		t.Error("expired task should not run")
+		return nil
+	})
+	expired.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	expired.SetOnSkip(func(err error) { skipped <- err })
+
+	_ = pool.AddTask(cancelled)
+	_ = pool.AddTask(expired)
+	cancelled.Cancel()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	for range 2 {
+		select {
+		case err := <-skipped:
+			if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("unexpected skip error %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("queued tasks were not skipped")
+		}
+	}
+}
+
+func TestTaskTimeoutCancelsRunFunc(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	slow := NewTask(1, func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		return ctx.Err()
+	})
+	slow.SetTimeout(20 * time.Millisecond)
+	_ = pool.AddTask(slow)
+
+	// The worker stays with the slow task until its RunFunc returns, even
+	// after the timeout.
+	start := time.Now()
+	finished := make(chan time.Duration, 1)
+	_ = pool.AddTask(NewTask(2, func(ctx context.Context) error {
+		finished <- time.Since(start)
+		return nil
+	}))
+
+	select {
+	case waited := <-finished:
+		if waited < 40*time.Millisecond {
+			t.Errorf("the next task started before the timed out one returned, after %v", waited)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a timed out task should return the worker once it returns")
+	}
+
+	if slow.Context().Err() != nil {
+		t.Errorf("the timeout should not cancel the task context, got %v", slow.Context().Err())
+	}
+	if stats := pool.Stats(); stats.Failed != 1 || stats.RunTime.Max < 40*time.Millisecond {
+		t.Errorf("expected the timed out task to fail after its whole run time, got %+v", stats)
+	}
+}
+
+func TestTerminateCancelsTasks(t *testing.T) {
+	pool := New(logs)
+	pool.MustRun(1)
+
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	_ = pool.AddTask(NewTask(1, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return ctx.Err()
+	}))
+
+	skipped := make(chan error, 1)
+	queued := NewTask(2, func(ctx context.Context) error { return nil })
+	queued.SetOnSkip(func(err error) { skipped <- err })
+	_ = pool.AddTask(queued)
+
+	<-started
+	pool.MustTerminate()
+
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Errorf("running task: expected context.Canceled, got %v", err)
+	}
+	if err := <-skipped; !errors.Is(err, ErrPoolTerminated) {
+		t.Errorf("queued task: expected ErrPoolTerminated, got %v", err)
+	}
+}
+
+func TestTasksRunByPriority(t *testing.T) {
+	pool := New(logs)
+	pool.SetAgingInterval(0)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	release := make(chan struct{})
+	_ = pool.AddTask(NewTask(0, func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+
+	order := make(chan int64, 6)
+	priorities := []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityLow, PriorityNormal, PriorityHigh}
+	for idx, priority := range priorities {
+		task := NewTask(int64(idx+1), func(ctx context.Context) error {
+			order <- int64(idx + 1)
+			return nil
+		})
+		_ = task.SetPriority(priority)
+		_ = pool.AddTask(task)
+	}
+	close(release)
+
+	for _, expected := range []int64{3, 6, 2, 5, 1, 4} {
+		select {
+		case id := <-order:
+			if id != expected {
+				t.Errorf("expected task %v, got %v", expected, id)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("tasks did not run")
+		}
+	}
+}
@@ -1,23 +1,32 @@
 package threadpool
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"github.com/ArtemLymarenko/parallel-course-work/pkg/priorityqueue"
 	"log"
 	"sync"
+	"time"
+)
+
+var (
+	ErrTaskNotAdded   = errors.New("task was not added")
+	ErrPoolTerminated = errors.New("thread pool was terminated")
 )
 
 type TaskPriorityQueue interface {
 	Size() int
 	Push(element *Task)
 	Pop() *Task
+	DropOldest() *Task
 	GetItems() []*Task
 	Empty() bool
 }
 
 type SyncPrimitives struct {
 	mainWaiter *sync.Cond
+	// notFull wakes AddTask calls waiting for room in a full queue.
+	notFull    *sync.Cond
 	commonLock sync.RWMutex
 	wg         sync.WaitGroup
 }
@@ -32,26 +41,57 @@ type ThreadPool struct {
 	sync          *SyncPrimitives
 	isInitialized bool
 	isTerminated  bool
+	isDraining    bool
+	activeTasks   int
+	drained       chan struct{}
+	// capacity bounds the queued tasks, zero means unbounded. A full queue
+	// is handled by policy.
+	capacity     int
+	policy       RejectionPolicy
+	blockTimeout time.Duration
+	// workers counts the running workers and target the count the pool is
+	// sized to, workers above it retire. idleSince holds when each waiting
+	// worker became idle.
+	workers   int
+	target    int
+	workerIds int64
+	idleSince map[int64]time.Time
+	// minWorkers and maxWorkers bound an autoscaling pool, maxWorkers is
+	// zero when the pool does not scale.
+	minWorkers  int
+	maxWorkers  int
+	idleTimeout time.Duration
+	// panics counts the tasks that panicked by task type.
+	panics map[string]int64
+	stats  *poolStats
+	// ctx is cancelled by MustTerminate, which cancels every running task.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func New(logger Logger) *ThreadPool {
-	compareFunc := func(a, b *Task) bool {
-		return a.CreatedAt.After(b.CreatedAt)
-	}
-
 	sp := &SyncPrimitives{
 		commonLock: sync.RWMutex{},
 		wg:         sync.WaitGroup{},
 	}
 
 	sp.mainWaiter = sync.NewCond(&sp.commonLock)
+	sp.notFull = sync.NewCond(&sp.commonLock)
+	ctx, cancel := context.WithCancel(context.Background())
 
 	return &ThreadPool{
 		logger:        logger,
-		mainTaskQueue: priorityqueue.New(compareFunc),
+		mainTaskQueue: newTaskQueue(DefaultAgingInterval),
 		sync:          sp,
 		isInitialized: false,
 		isTerminated:  false,
+		drained:       make(chan struct{}),
+		blockTimeout:  DefaultBlockTimeout,
+		idleSince:     make(map[int64]time.Time),
+		panics:        make(map[string]int64),
+		stats:         newPoolStats(),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 }
 
@@ -73,89 +113,315 @@ func (threadPool *ThreadPool) MustRun(mainThreadCount int) {
 		log.Fatal("thread pool is already initialized or terminated")
 	}
 
-	for range mainThreadCount {
+	if threadPool.autoscalingUnsafe() {
+		mainThreadCount = min(max(mainThreadCount, threadPool.minWorkers), threadPool.maxWorkers)
 		threadPool.sync.wg.Add(1)
-		go threadPool.routineThread()
+		go threadPool.reapIdleWorkers()
 	}
+	threadPool.resizeUnsafe(mainThreadCount)
 
 	threadPool.isInitialized = true
 	threadPool.logger.Log("thread pool is running...")
 }
 
+// MustTerminate stops the workers and cancels the context of every running
+// task. Tasks still queued are skipped, their owners learn about it through
+// their skip function with ErrPoolTerminated.
 func (threadPool *ThreadPool) MustTerminate() {
 	defer threadPool.sync.wg.Wait()
 
 	threadPool.sync.commonLock.Lock()
-	defer threadPool.sync.commonLock.Unlock()
-
 	if !threadPool.isInitialized || threadPool.isTerminated {
+		threadPool.sync.commonLock.Unlock()
 		return
 	}
 
+	threadPool.cancel()
 	threadPool.sync.mainWaiter.Broadcast()
+	threadPool.sync.notFull.Broadcast()
 
 	threadPool.isInitialized = false
 	threadPool.isTerminated = true
 
+	var queued []*Task
+	for !threadPool.mainTaskQueue.Empty() {
+		queued = append(queued, threadPool.mainTaskQueue.Pop())
+	}
+	threadPool.stats.skipped += int64(len(queued))
+	threadPool.sync.commonLock.Unlock()
+
+	for _, task := range queued {
+		task.skip(ErrPoolTerminated)
+	}
+
 	threadPool.logger.Log("threadPool terminated...")
 }
 
-func (threadPool *ThreadPool) AddTask(task *Task) error {
-	if !threadPool.IsWorking() {
-		return errors.New("task was not added")
+// Drain stops accepting new tasks and waits until every queued and running
+// task has finished, or until ctx is done. Workers keep running, so the pool
+// still has to be stopped with MustTerminate afterwards.
+func (threadPool *ThreadPool) Drain(ctx context.Context) error {
+	threadPool.sync.commonLock.Lock()
+	if !threadPool.IsWorkingUnsafe() {
+		threadPool.sync.commonLock.Unlock()
+		return nil
+	}
+
+	threadPool.isDraining = true
+	threadPool.sync.notFull.Broadcast()
+	threadPool.notifyDrainedUnsafe()
+	threadPool.sync.commonLock.Unlock()
+
+	select {
+	case <-threadPool.drained:
+		threadPool.logger.Log("thread pool drained...")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (threadPool *ThreadPool) notifyDrainedUnsafe() {
+	if !threadPool.isDraining || threadPool.activeTasks > 0 || !threadPool.mainTaskQueue.Empty() {
+		return
+	}
+
+	select {
+	case <-threadPool.drained:
+	default:
+		close(threadPool.drained)
+	}
+}
+
+// SetAgingInterval sets how long a queued task waits to be treated as one
+// priority class higher, zero turns aging off.
+func (threadPool *ThreadPool) SetAgingInterval(agingInterval time.Duration) {
+	threadPool.sync.commonLock.Lock()
+	defer threadPool.sync.commonLock.Unlock()
+
+	if queue, ok := threadPool.mainTaskQueue.(*taskQueue); ok {
+		queue.agingInterval = agingInterval
 	}
+}
+
+// SetQueueCapacity bounds the number of queued tasks, running ones do not
+// count. Zero makes the queue unbounded again. policy decides what AddTask
+// does once the queue is full.
+func (threadPool *ThreadPool) SetQueueCapacity(capacity int, policy RejectionPolicy) error {
+	if capacity < 0 {
+		return ErrInvalidQueueCapacity
+	}
+
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	threadPool.sync.commonLock.Lock()
+	defer threadPool.sync.commonLock.Unlock()
+
+	threadPool.capacity = capacity
+	threadPool.policy = policy
+	threadPool.sync.notFull.Broadcast()
+	return nil
+}
 
+// SetBlockTimeout sets how long AddTask waits for room under PolicyBlock.
+func (threadPool *ThreadPool) SetBlockTimeout(timeout time.Duration) {
 	threadPool.sync.commonLock.Lock()
 	defer threadPool.sync.commonLock.Unlock()
+	threadPool.blockTimeout = timeout
+}
+
+// AddTask queues the task in its priority class. A task with an invalid
+// priority is not added. When the queue is full the rejection policy
+// decides, AddTask then fails with ErrQueueFull, waits for room, runs
+// the task itself or drops the oldest queued task.
+func (threadPool *ThreadPool) AddTask(task *Task) error {
+	if err := task.Priority.Validate(); err != nil {
+		return err
+	}
+
+	threadPool.sync.commonLock.Lock()
+
+	if !threadPool.IsWorkingUnsafe() || threadPool.isDraining {
+		threadPool.sync.commonLock.Unlock()
+		return ErrTaskNotAdded
+	}
+
+	var dropped *Task
+	if threadPool.isFullUnsafe() {
+		switch threadPool.policy {
+		case PolicyBlock:
+			if err := threadPool.waitNotFullUnsafe(); err != nil {
+				if errors.Is(err, ErrQueueFull) {
+					threadPool.stats.rejected++
+				}
+				threadPool.sync.commonLock.Unlock()
+				return err
+			}
+		case PolicyCallerRuns:
+			_ = task.SetStatus(PROCESSING)
+			threadPool.activeTasks++
+			threadPool.sync.commonLock.Unlock()
+
+			timeTaken, err := threadPool.runTask(task)
+			threadPool.finishTask(task, timeTaken, err)
+			return nil
+		case PolicyDropOldest:
+			dropped = threadPool.mainTaskQueue.DropOldest()
+			threadPool.stats.skipped++
+		default:
+			threadPool.stats.rejected++
+			threadPool.sync.commonLock.Unlock()
+			return ErrQueueFull
+		}
+	}
 
 	threadPool.mainTaskQueue.Push(task)
 	threadPool.sync.mainWaiter.Signal()
+	threadPool.growUnsafe()
+	threadPool.sync.commonLock.Unlock()
+
+	if dropped != nil {
+		msg := fmt.Sprintf("task [%v] dropped: %v", dropped.Id, ErrQueueFull)
+		threadPool.logger.Log(msg)
+		dropped.skip(ErrQueueFull)
+	}
+
+	return nil
+}
+
+func (threadPool *ThreadPool) isFullUnsafe() bool {
+	return threadPool.capacity > 0 && threadPool.mainTaskQueue.Size() >= threadPool.capacity
+}
+
+// waitNotFullUnsafe waits until the queue has room, for the block timeout
+// at most. It fails with ErrTaskNotAdded when the pool stops meanwhile.
+func (threadPool *ThreadPool) waitNotFullUnsafe() error {
+	deadline := time.Now().Add(threadPool.blockTimeout)
+	timer := time.AfterFunc(threadPool.blockTimeout, func() {
+		threadPool.sync.commonLock.Lock()
+		defer threadPool.sync.commonLock.Unlock()
+		threadPool.sync.notFull.Broadcast()
+	})
+	defer timer.Stop()
+
+	for threadPool.isFullUnsafe() {
+		if !time.Now().Before(deadline) {
+			return ErrQueueFull
+		}
+
+		threadPool.sync.notFull.Wait()
+
+		if !threadPool.IsWorkingUnsafe() || threadPool.isDraining {
+			return ErrTaskNotAdded
+		}
+	}
 
 	return nil
 }
 
-func (threadPool *ThreadPool) routineThread() {
+// routineThread runs tasks until the pool is terminated or shrunk. Panics
+// of tasks are errors of their own, a panic anywhere else ends the worker
+// and a new one takes its place.
+func (threadPool *ThreadPool) routineThread(workerId int64) {
 	defer threadPool.sync.wg.Done()
 
-	for threadPool.IsWorking() {
-		task := threadPool.getTaskFromQueue()
+	var current *Task
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			threadPool.replaceWorker(workerId, current, recovered)
+		}
+	}()
+
+	for {
+		task, ok := threadPool.getTaskFromQueue(workerId)
+		if !ok {
+			return
+		}
+
 		if task == nil {
 			continue
 		}
 
-		timeTaken, err := task.Run()
+		current = task
+		timeTaken, err := threadPool.runTask(task)
+		current = nil
+		threadPool.finishTask(task, timeTaken, err)
+	}
+}
 
-		if err != nil {
-			msg := fmt.Sprintf("task [%v] failed with error: %v", task.Id, err.Error())
-			threadPool.logger.Log(msg)
-		}
+func (threadPool *ThreadPool) runTask(task *Task) (time.Duration, error) {
+	timeTaken, err := task.run(threadPool.ctx)
+
+	if task.Status == CANCELLED {
+		msg := fmt.Sprintf("task [%v] skipped: %v", task.Id, err)
+		threadPool.logger.Log(msg)
+		return timeTaken, err
+	}
 
-		msg := fmt.Sprintf("task [%v], finished in %v", task.Id, timeTaken)
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		threadPool.countPanic(task, panicErr)
+	} else if err != nil {
+		msg := fmt.Sprintf("task [%v] failed with error: %v", task.Id, err.Error())
 		threadPool.logger.Log(msg)
 	}
+
+	msg := fmt.Sprintf("task [%v], finished in %v", task.Id, timeTaken)
+	threadPool.logger.Log(msg)
+	return timeTaken, err
+}
+
+func (threadPool *ThreadPool) finishTask(task *Task, timeTaken time.Duration, err error) {
+	threadPool.sync.commonLock.Lock()
+	defer threadPool.sync.commonLock.Unlock()
+
+	threadPool.stats.recordRun(task, timeTaken, err)
+	threadPool.activeTasks--
+	threadPool.notifyDrainedUnsafe()
 }
 
-func (threadPool *ThreadPool) getTaskFromQueue() *Task {
+// getTaskFromQueue waits for the next task. It reports false when the
+// worker has to stop, because the pool was terminated or shrunk.
+func (threadPool *ThreadPool) getTaskFromQueue(workerId int64) (*Task, bool) {
 	queue, waiter := threadPool.mainTaskQueue, threadPool.sync.mainWaiter
 
 	threadPool.sync.commonLock.Lock()
 	defer threadPool.sync.commonLock.Unlock()
 
-	for queue.Empty() && !threadPool.isTerminated {
+	threadPool.idleSince[workerId] = time.Now()
+	for queue.Empty() && !threadPool.isTerminated && !threadPool.retiringUnsafe() {
 		waiter.Wait()
 	}
+	delete(threadPool.idleSince, workerId)
+
+	if threadPool.isTerminated || threadPool.retiringUnsafe() {
+		threadPool.workers--
+		return nil, false
+	}
 
 	for {
 		task := queue.Pop()
 		if task == nil {
-			return nil
+			// The tasks discarded below may have been the last ones a
+			// drain was waiting for.
+			threadPool.notifyDrainedUnsafe()
+			return nil, true
 		}
+		threadPool.sync.notFull.Signal()
 
-		if task != nil && task.Status == IDLE {
+		if task.Status == IDLE {
 			_ = task.SetStatus(PROCESSING)
+			threadPool.stats.queueWait.record(time.Since(task.queuedAt))
+			threadPool.activeTasks++
 			msg := fmt.Sprintf("task [%v] was taken", task.Id)
 			threadPool.logger.Log(msg)
-			return task
+			return task, true
 		}
+
+		threadPool.stats.skipped++
+		msg := fmt.Sprintf("task [%v] skipped, it was not idle when taken", task.Id)
+		threadPool.logger.Log(msg)
 	}
 }
@@ -0,0 +1,169 @@
+package threadpool
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidWorkerCount = errors.New("invalid worker count")
+	ErrPoolNotRunning     = errors.New("thread pool is not running")
+)
+
+// DefaultIdleTimeout is how long a worker of an autoscaling pool waits
+// for a task before it is reaped.
+const DefaultIdleTimeout = 30 * time.Second
+
+// PoolSize describes the workers of a pool. Workers may exceed Target for
+// a while after the pool was shrunk, busy workers retire once their task
+// is done.
+type PoolSize struct {
+	Workers    int
+	Target     int
+	Busy       int
+	Queued     int
+	MinWorkers int
+	MaxWorkers int
+}
+
+// SetAutoscale makes the pool grow, up to maxWorkers, whenever tasks are
+// queued that no idle worker is going to pick up, and reap workers idle
+// for idleTimeout, down to minWorkers. It has to be called before MustRun,
+// which then starts the worker count it is given within these bounds.
+func (threadPool *ThreadPool) SetAutoscale(minWorkers, maxWorkers int, idleTimeout time.Duration) error {
+	if minWorkers < 1 || maxWorkers < minWorkers {
+		return ErrInvalidWorkerCount
+	}
+
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+
+	threadPool.sync.commonLock.Lock()
+	defer threadPool.sync.commonLock.Unlock()
+
+	threadPool.minWorkers = minWorkers
+	threadPool.maxWorkers = maxWorkers
+	threadPool.idleTimeout = idleTimeout
+	return nil
+}
+
+// Resize changes the number of workers of a running pool. New workers
+// start right away, surplus ones retire once they finish their task.
+// An autoscaling pool only accepts a count within its bounds.
+func (threadPool *ThreadPool) Resize(workers int) error {
+	threadPool.sync.commonLock.Lock()
+	defer threadPool.sync.commonLock.Unlock()
+
+	if workers < 1 || threadPool.autoscalingUnsafe() &&
+		(workers < threadPool.minWorkers || workers > threadPool.maxWorkers) {
+		return ErrInvalidWorkerCount
+	}
+
+	if !threadPool.IsWorkingUnsafe() {
+		return ErrPoolNotRunning
+	}
+
+	threadPool.resizeUnsafe(workers)
+	return nil
+}
+
+func (threadPool *ThreadPool) Size() PoolSize {
+	threadPool.sync.commonLock.RLock()
+	defer threadPool.sync.commonLock.RUnlock()
+
+	return PoolSize{
+		Workers:    threadPool.workers,
+		Target:     threadPool.target,
+		Busy:       threadPool.activeTasks,
+		Queued:     threadPool.mainTaskQueue.Size(),
+		MinWorkers: threadPool.minWorkers,
+		MaxWorkers: threadPool.maxWorkers,
+	}
+}
+
+func (threadPool *ThreadPool) autoscalingUnsafe() bool {
+	return threadPool.maxWorkers > 0
+}
+
+// resizeUnsafe starts the workers missing to target. Waiting workers are
+// woken up to check whether they are the surplus.
+func (threadPool *ThreadPool) resizeUnsafe(target int) {
+	if target == threadPool.target {
+		return
+	}
+
+	msg := fmt.Sprintf("thread pool resized from %v to %v workers", threadPool.target, target)
+	threadPool.logger.Log(msg)
+
+	threadPool.target = target
+	threadPool.startWorkersUnsafe()
+	threadPool.sync.mainWaiter.Broadcast()
+}
+
+func (threadPool *ThreadPool) startWorkersUnsafe() {
+	for threadPool.workers < threadPool.target {
+		threadPool.workers++
+		threadPool.workerIds++
+		threadPool.sync.wg.Add(1)
+		go threadPool.routineThread(threadPool.workerIds)
+	}
+}
+
+// retiringUnsafe reports whether the pool has more workers than it
+// was resized to, the worker asking should then stop.
+func (threadPool *ThreadPool) retiringUnsafe() bool {
+	return threadPool.workers > threadPool.target
+}
+
+// growUnsafe adds a worker to an autoscaling pool when more tasks are
+// queued than workers wait for them.
+func (threadPool *ThreadPool) growUnsafe() {
+	if !threadPool.autoscalingUnsafe() || threadPool.target >= threadPool.maxWorkers {
+		return
+	}
+
+	if threadPool.mainTaskQueue.Size() > len(threadPool.idleSince) {
+		threadPool.resizeUnsafe(threadPool.target + 1)
+	}
+}
+
+// reapIdleWorkers shrinks an autoscaling pool by the workers that waited
+// for a task for the idle timeout, until the pool is terminated.
+func (threadPool *ThreadPool) reapIdleWorkers() {
+	defer threadPool.sync.wg.Done()
+
+	ticker := time.NewTicker(max(threadPool.idleTimeout/2, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-threadPool.ctx.Done():
+			return
+		case <-ticker.C:
+			threadPool.reap()
+		}
+	}
+}
+
+func (threadPool *ThreadPool) reap() {
+	threadPool.sync.commonLock.Lock()
+	defer threadPool.sync.commonLock.Unlock()
+
+	now := time.Now()
+	idle := 0
+	for _, since := range threadPool.idleSince {
+		if now.Sub(since) >= threadPool.idleTimeout {
+			idle++
+		}
+	}
+
+	// Workers above target are already retiring, and while they wait they
+	// are counted as idle too. Only the idle workers beyond them shrink
+	// the pool further.
+	surplus := threadPool.workers - threadPool.target
+	if target := max(threadPool.minWorkers, threadPool.target-(idle-surplus)); target < threadPool.target {
+		threadPool.resizeUnsafe(target)
+	}
+}
@@ -0,0 +1,160 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForSize polls the pool until check accepts its size.
+func waitForSize(t *testing.T, pool *ThreadPool, check func(size PoolSize) bool) PoolSize {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		size := pool.Size()
+		if check(size) {
+			return size
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("pool did not reach the expected size, got %+v", size)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestResize(t *testing.T) {
+	pool := New(logs)
+	if err := pool.Resize(2); !errors.Is(err, ErrPoolNotRunning) {
+		t.Errorf("expected ErrPoolNotRunning, got %v", err)
+	}
+
+	pool.MustRun(2)
+	defer pool.MustTerminate()
+
+	release := make(chan struct{})
+	running := atomic.Int64{}
+	for i := range 6 {
+		_ = pool.AddTask(NewTask(int64(i), func(ctx context.Context) error {
+			running.Add(1)
+			<-release
+			return nil
+		}))
+	}
+
+	if err := pool.Resize(6); err != nil {
+		t.Fatal(err)
+	}
+	waitForSize(t, pool, func(size PoolSize) bool {
+		return size.Workers == 6 && running.Load() == 6
+	})
+
+	if err := pool.Resize(1); err != nil {
+		t.Fatal(err)
+	}
+	if size := pool.Size(); size.Target != 1 || size.Workers != 6 {
+		t.Errorf("busy workers should retire after their task, got %+v", size)
+	}
+
+	close(release)
+	waitForSize(t, pool, func(size PoolSize) bool {
+		return size.Workers == 1 && size.Busy == 0
+	})
+
+	finished := make(chan struct{})
+	_ = pool.AddTask(NewTask(7, func(ctx context.Context) error {
+		close(finished)
+		return nil
+	}))
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("the remaining worker did not run the task")
+	}
+
+	if err := pool.Resize(0); !errors.Is(err, ErrInvalidWorkerCount) {
+		t.Errorf("expected ErrInvalidWorkerCount, got %v", err)
+	}
+}
+
+func TestAutoscale(t *testing.T) {
+	pool := New(logs)
+	if err := pool.SetAutoscale(1, 4, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	release := make(chan struct{})
+	for i := range 6 {
+		_ = pool.AddTask(NewTask(int64(i), func(ctx context.Context) error {
+			<-release
+			return nil
+		}))
+	}
+
+	size := waitForSize(t, pool, func(size PoolSize) bool {
+		return size.Busy == 4
+	})
+	if size.Workers != 4 || size.Queued != 2 {
+		t.Errorf("expected the pool to grow to its 4 workers at most, got %+v", size)
+	}
+
+	if err := pool.Resize(5); !errors.Is(err, ErrInvalidWorkerCount) {
+		t.Errorf("expected ErrInvalidWorkerCount beyond the bounds, got %v", err)
+	}
+
+	close(release)
+	waitForSize(t, pool, func(size PoolSize) bool {
+		return size.Workers == 1 && size.Queued == 0
+	})
+}
+
+func TestInvalidAutoscaleBounds(t *testing.T) {
+	pool := New(logs)
+	if err := pool.SetAutoscale(4, 2, time.Second); !errors.Is(err, ErrInvalidWorkerCount) {
+		t.Errorf("expected ErrInvalidWorkerCount, got %v", err)
+	}
+	if err := pool.SetAutoscale(0, 2, time.Second); !errors.Is(err, ErrInvalidWorkerCount) {
+		t.Errorf("expected ErrInvalidWorkerCount, got %v", err)
+	}
+}
+
+func TestReapSkipsRetiringWorkers(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		target  int
+		idle    int
+		want    int
+	}{
+		{"retiring workers are not reaped twice", 4, 2, 2, 2},
+		{"idle workers beyond the retiring ones", 4, 3, 2, 2},
+		{"never below the minimum", 3, 3, 3, 1},
+		{"busy workers are kept", 4, 4, 1, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool := New(logs)
+			if err := pool.SetAutoscale(1, 4, time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+
+			idleSince := time.Now().Add(-time.Second)
+			pool.workers = test.workers
+			pool.target = test.target
+			for id := range test.idle {
+				pool.idleSince[int64(id)] = idleSince
+			}
+
+			pool.reap()
+			if pool.target != test.want {
+				t.Errorf("expected target %v, got %v", test.want, pool.target)
+			}
+		})
+	}
+}
@@ -2,14 +2,20 @@ package main
 
 import (
 	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+	"log"
+	"os"
 	"server/internal/app"
+	docStore "server/internal/infrastructure/doc_store"
+	eventBus "server/internal/infrastructure/event_bus"
 	filemanager "server/internal/infrastructure/file_manager"
+	httpGateway "server/internal/infrastructure/http_gateway"
 	invertedIdx "server/internal/infrastructure/inverted_idx"
 	"server/internal/infrastructure/logger"
 	tcpServer "server/internal/infrastructure/tcp_server"
 	"server/internal/inteface/rest/handlers"
 	v1Router "server/internal/inteface/rest/router"
 	"server/internal/service"
+	"strconv"
 	"time"
 )
 
@@ -18,21 +24,120 @@ func main() {
 	defer loggerService.Close()
 
 	fileManager := filemanager.New(loggerService)
+	bus := eventBus.New(eventBus.DefaultBufferSize)
+	documentStore := docStore.New()
 	invIndex := invertedIdx.New(fileManager, loggerService)
+	invIndex.SetDocumentSource(invertedIdx.NewDocumentSources(fileManager, documentStore))
+	invIndex.SetEventPublisher(bus)
 
 	const resourceDir = "resources/data/"
-	invIndex.Build(resourceDir, 12)
+	// Uploads live inside the index root, so the scheduler and rebuilds pick them up too.
+	const ingestDir = resourceDir + "uploads"
+	ingestService, err := service.NewIngest(ingestDir, invIndex, service.MaxUploadSize, loggerService)
+	if err != nil {
+		log.Fatalf("could not prepare the ingest directory: %v", err)
+	}
 
-	invIdxSchedulerService := service.NewSchedulerService(invIndex, fileManager, loggerService)
-	go invIdxSchedulerService.MonitorDirAsync(resourceDir, 30*time.Second)
+	// The pool is shared by the index build and the server, which keeps it running.
+	const threadCount = 12
+	threadPool := threadpool.New(loggerService)
+	// The pool starts with threadCount workers, grows under load and gives
+	// back workers that stay idle. The count can be changed on /admin/workers.
+	const minThreads, maxThreads = 4, 32
+	if err = threadPool.SetAutoscale(minThreads, maxThreads, threadpool.DefaultIdleTimeout); err != nil {
+		log.Fatalf("could not set up pool autoscaling: %v", err)
+	}
+	// Requests beyond the queue capacity are answered with StatusTooManyRequests
+	// instead of growing the queue without limit.
+	const queueCapacity = 4096
+	if err = threadPool.SetQueueCapacity(queueCapacity, threadpool.PolicyReject); err != nil {
+		log.Fatalf("could not bound the task queue: %v", err)
+	}
+	threadPool.MustRun(threadCount)
+	invIndex.SetExecutor(threadPool)
+	invIndex.Build(resourceDir, threadCount)
+
+	// Periodic work, such as picking up new files of the index root, runs on the pool.
+	scheduler := threadpool.NewScheduler(threadPool, loggerService)
+	scheduler.MustRun()
+
+	invIdxSchedulerService := service.NewSchedulerService(invIndex, fileManager, scheduler, loggerService)
+	invIdxSchedulerService.SetEventPublisher(bus)
+	// Uploads index their documents themselves, scans leave them alone meanwhile.
+	invIdxSchedulerService.SetUploadTracker(ingestService)
+	if _, err = invIdxSchedulerService.MonitorDir(resourceDir, 30*time.Second); err != nil {
+		log.Fatalf("could not monitor the index root: %v", err)
+	}
 
 	invIndexHandlers := handlers.NewInvertedIndex(invIndex, loggerService)
-	router := v1Router.MustInitRouter(invIndexHandlers, loggerService)
+	eventsHandlers := handlers.NewEvents(bus, handlers.MaxSubscribers, loggerService)
 
-	threadPool := threadpool.New(loggerService)
-	server := tcpServer.New(8080, threadPool, router, loggerService)
+	indexJobsService := service.NewIndexJobs(invIndex, fileManager, threadPool, service.JobParallelism, loggerService)
+	indexJobsService.SetEventPublisher(bus)
+	jobsHandlers := handlers.NewIndexJobs(indexJobsService, loggerService)
+	uploadHandlers := handlers.NewUpload(ingestService, loggerService)
+	documentsService := service.NewDocuments(documentStore, invIndex, loggerService)
+	documentsHandlers := handlers.NewDocuments(documentsService, loggerService)
+
+	batchHandlers := handlers.NewBatch(threadPool, handlers.MaxBatchSize, handlers.BatchParallelism, loggerService)
+	// The admin routes resize the pool and are not authenticated, they are
+	// only served when ADMIN_ROUTES is set to true.
+	var adminHandlers v1Router.AdminHandlers
+	if enabled, _ := strconv.ParseBool(os.Getenv("ADMIN_ROUTES")); enabled {
+		adminHandlers = handlers.NewAdmin(threadPool, loggerService)
+	}
+	router := v1Router.MustInitRouter(
+		invIndexHandlers,
+		jobsHandlers,
+		uploadHandlers,
+		documentsHandlers,
+		eventsHandlers,
+		batchHandlers,
+		adminHandlers,
+		loggerService,
+	)
+
+	serverConfig := tcpServer.Config{
+		Port:                 8080,
+		ReadTimeout:          10 * time.Second,
+		AliveTimeout:         15 * time.Second,
+		MaxConnections:       1024,
+		DrainTimeout:         5 * time.Second,
+		RequestTimeout:       30 * time.Second,
+		MaxMessageSize:       16 << 20,
+		CompressionThreshold: 1024,
+		UnixSocket:           os.Getenv("UNIX_SOCKET"),
+		UnixSocketMode:       0660,
+	}
+	server := tcpServer.New(serverConfig, threadPool, router, loggerService)
+
+	// The HTTP gateway only listens when HTTP_PORT is set. Its requests are
+	// scheduled on the pool through the server, which stops the gateway
+	// before it drains the pool.
+	if httpPort := os.Getenv("HTTP_PORT"); httpPort != "" {
+		port, err := strconv.Atoi(httpPort)
+		if err != nil {
+			log.Fatalf("invalid HTTP_PORT %q: %v", httpPort, err)
+		}
+
+		gatewayConfig := httpGateway.Config{
+			Port:            port,
+			ReadTimeout:     10 * time.Second,
+			ShutdownTimeout: 5 * time.Second,
+			MaxBodySize:     16 << 20,
+		}
+		gateway := httpGateway.New(gatewayConfig, server, loggerService)
+		go func() {
+			if err := gateway.Start(); err != nil {
+				loggerService.Log("HTTP gateway stopped with error:", err)
+			}
+		}()
+		server.RegisterOnDrain(gateway.Shutdown)
+	}
+
+	server.RegisterOnShutdown(scheduler.MustTerminate)
+	server.RegisterOnShutdown(loggerService.Flush)
 
-	const threadCount = 12
 	if err := server.Start(threadCount); err != nil {
 		loggerService.Log("Server stopped with error:", err)
 	}
@@ -0,0 +1,98 @@
+package docStore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("document not found")
+	ErrExists   = errors.New("document already exists")
+)
+
+// Document is a piece of text indexed straight from a request,
+// it has no file behind it.
+type Document struct {
+	Id        string
+	Text      string
+	Metadata  map[string]string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Store keeps documents in memory, like the index built from them.
+type Store struct {
+	documents map[string]Document
+	lock      sync.RWMutex
+}
+
+func New() *Store {
+	return &Store{
+		documents: make(map[string]Document),
+	}
+}
+
+// Put stores doc. Without replace an existing document with the same id
+// is kept and ErrExists returned.
+func (store *Store) Put(doc Document, replace bool) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	now := time.Now()
+	doc.Metadata = maps.Clone(doc.Metadata)
+	doc.CreatedAt, doc.UpdatedAt = now, now
+
+	if previous, exists := store.documents[doc.Id]; exists {
+		if !replace {
+			return ErrExists
+		}
+		doc.CreatedAt = previous.CreatedAt
+	}
+
+	store.documents[doc.Id] = doc
+	return nil
+}
+
+func (store *Store) Get(id string) (Document, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	doc, ok := store.documents[id]
+	if !ok {
+		return Document{}, ErrNotFound
+	}
+
+	doc.Metadata = maps.Clone(doc.Metadata)
+	return doc, nil
+}
+
+func (store *Store) Delete(id string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if _, ok := store.documents[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(store.documents, id)
+	return nil
+}
+
+// Read returns the text of the document with id, so the store can be the
+// document source of the index. A missing document is reported as
+// fs.ErrNotExist, like a missing file.
+func (store *Store) Read(id string) ([]byte, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	doc, ok := store.documents[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %w", ErrNotFound, fs.ErrNotExist)
+	}
+
+	return []byte(doc.Text), nil
+}
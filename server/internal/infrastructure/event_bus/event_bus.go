@@ -0,0 +1,222 @@
+package eventBus
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventType string
+
+const (
+	FileAdded     EventType = "file_added"
+	FileRemoved   EventType = "file_removed"
+	BuildProgress EventType = "build_progress"
+	BuildComplete EventType = "build_complete"
+)
+
+var ErrUnknownEventType = errors.New("unknown event type")
+
+func (eventType EventType) Validate() error {
+	switch eventType {
+	case FileAdded, FileRemoved, BuildProgress, BuildComplete:
+		return nil
+	default:
+		return ErrUnknownEventType
+	}
+}
+
+type Event struct {
+	Id   int64     `json:"id"`
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+type FileEvent struct {
+	Path string `json:"path"`
+}
+
+type BuildProgressEvent struct {
+	Source string `json:"source"`
+	Done   int    `json:"done"`
+	Total  int    `json:"total"`
+}
+
+type BuildCompleteEvent struct {
+	Source   string `json:"source"`
+	Added    int    `json:"added"`
+	Failed   int    `json:"failed"`
+	Duration int64  `json:"durationMs"`
+}
+
+const DefaultBufferSize = 256
+
+// Bus fans events out to its subscribers. Publishing never blocks: a
+// subscriber that does not keep up loses events instead of stalling the
+// index, Subscription.Dropped tells how many.
+type Bus struct {
+	subscribers map[int64]*Subscription
+	lock        sync.RWMutex
+	bufferSize  int
+	eventIds    atomic.Int64
+	nextId      int64
+}
+
+func New(bufferSize int) *Bus {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	return &Bus{
+		subscribers: make(map[int64]*Subscription),
+		bufferSize:  bufferSize,
+	}
+}
+
+func (bus *Bus) Publish(eventType EventType, data any) {
+	event := Event{
+		Id:   bus.eventIds.Add(1),
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	}
+
+	bus.lock.RLock()
+	defer bus.lock.RUnlock()
+
+	for _, subscription := range bus.subscribers {
+		if !subscription.wants(eventType) {
+			continue
+		}
+
+		select {
+		case subscription.events <- event:
+		default:
+			subscription.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the given event types, or to every
+// event when none are given. It has to be closed once it is not needed.
+func (bus *Bus) Subscribe(types ...EventType) *Subscription {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	bus.nextId++
+	subscription := &Subscription{
+		id:     bus.nextId,
+		bus:    bus,
+		events: make(chan Event, bus.bufferSize),
+		types:  make(map[EventType]struct{}, len(types)),
+	}
+
+	for _, eventType := range types {
+		subscription.types[eventType] = struct{}{}
+	}
+
+	bus.subscribers[subscription.id] = subscription
+	return subscription
+}
+
+func (bus *Bus) SubscribersCount() int {
+	bus.lock.RLock()
+	defer bus.lock.RUnlock()
+	return len(bus.subscribers)
+}
+
+func (bus *Bus) unsubscribe(subscription *Subscription) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	if _, ok := bus.subscribers[subscription.id]; ok {
+		delete(bus.subscribers, subscription.id)
+		close(subscription.events)
+	}
+}
+
+type Subscription struct {
+	id      int64
+	bus     *Bus
+	events  chan Event
+	types   map[EventType]struct{}
+	dropped atomic.Int64
+}
+
+// Events is closed once the subscription is closed.
+func (subscription *Subscription) Events() <-chan Event {
+	return subscription.events
+}
+
+func (subscription *Subscription) Dropped() int64 {
+	return subscription.dropped.Load()
+}
+
+func (subscription *Subscription) Close() {
+	subscription.bus.unsubscribe(subscription)
+}
+
+func (subscription *Subscription) wants(eventType EventType) bool {
+	if len(subscription.types) == 0 {
+		return true
+	}
+
+	_, ok := subscription.types[eventType]
+	return ok
+}
+
+type Publisher interface {
+	Publish(eventType EventType, data any)
+}
+
+// progressSteps is how many BuildProgress events one build publishes at most.
+const progressSteps = 100
+
+// BuildTracker publishes the progress of adding a batch of files. It is
+// safe to report files from many goroutines at once.
+type BuildTracker struct {
+	publisher Publisher
+	source    string
+	total     int
+	step      int
+	done      atomic.Int64
+	failed    atomic.Int64
+	start     time.Time
+}
+
+func NewBuildTracker(publisher Publisher, source string, total int) *BuildTracker {
+	return &BuildTracker{
+		publisher: publisher,
+		source:    source,
+		total:     total,
+		step:      max(total/progressSteps, 1),
+		start:     time.Now(),
+	}
+}
+
+func (tracker *BuildTracker) FileDone(err error) {
+	if err != nil {
+		tracker.failed.Add(1)
+	}
+
+	done := int(tracker.done.Add(1))
+	if done%tracker.step == 0 || done == tracker.total {
+		tracker.publisher.Publish(BuildProgress, BuildProgressEvent{
+			Source: tracker.source,
+			Done:   done,
+			Total:  tracker.total,
+		})
+	}
+}
+
+func (tracker *BuildTracker) Complete() {
+	failed := int(tracker.failed.Load())
+	tracker.publisher.Publish(BuildComplete, BuildCompleteEvent{
+		Source:   tracker.source,
+		Added:    int(tracker.done.Load()) - failed,
+		Failed:   failed,
+		Duration: time.Since(tracker.start).Milliseconds(),
+	})
+}
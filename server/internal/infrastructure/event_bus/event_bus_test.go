@@ -0,0 +1,78 @@
+package eventBus
+
+import (
+	"testing"
+)
+
+func TestPublishFiltersByType(t *testing.T) {
+	bus := New(8)
+	all := bus.Subscribe()
+	defer all.Close()
+	files := bus.Subscribe(FileAdded, FileRemoved)
+	defer files.Close()
+
+	bus.Publish(FileAdded, FileEvent{Path: "a.txt"})
+	bus.Publish(BuildComplete, BuildCompleteEvent{Added: 1})
+	bus.Publish(FileRemoved, FileEvent{Path: "a.txt"})
+
+	expectTypes(t, all, FileAdded, BuildComplete, FileRemoved)
+	expectTypes(t, files, FileAdded, FileRemoved)
+}
+
+func TestSlowSubscriberDropsEvents(t *testing.T) {
+	bus := New(2)
+	subscription := bus.Subscribe()
+	defer subscription.Close()
+
+	for range 5 {
+		bus.Publish(FileAdded, nil)
+	}
+
+	if dropped := subscription.Dropped(); dropped != 3 {
+		t.Errorf("expected 3 dropped events, got %v", dropped)
+	}
+
+	expectTypes(t, subscription, FileAdded, FileAdded)
+}
+
+func TestCloseUnsubscribes(t *testing.T) {
+	bus := New(2)
+	subscription := bus.Subscribe()
+	subscription.Close()
+	subscription.Close()
+
+	bus.Publish(FileAdded, nil)
+	if _, ok := <-subscription.Events(); ok {
+		t.Error("events should be closed after Close")
+	}
+
+	if count := bus.SubscribersCount(); count != 0 {
+		t.Errorf("expected no subscribers, got %v", count)
+	}
+}
+
+func expectTypes(t *testing.T, subscription *Subscription, types ...EventType) {
+	t.Helper()
+
+	var lastId int64
+	for _, expected := range types {
+		select {
+		case event := <-subscription.Events():
+			if event.Type != expected {
+				t.Errorf("expected %v, got %v", expected, event.Type)
+			}
+			if event.Id <= lastId {
+				t.Errorf("event ids should grow, got %v after %v", event.Id, lastId)
+			}
+			lastId = event.Id
+		default:
+			t.Fatalf("expected %v, got nothing", expected)
+		}
+	}
+
+	select {
+	case event := <-subscription.Events():
+		t.Errorf("unexpected event %v", event.Type)
+	default:
+	}
+}
@@ -0,0 +1,281 @@
+package httpGateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Router interface {
+	Handle(ctx context.Context, request *tcpRouter.Request, writer tcpRouter.ResponseWriter) error
+}
+
+type Logger interface {
+	Log(...interface{})
+}
+
+const (
+	// Prefix is prepended to every router path, /index/search is served
+	// as /v1/index/search.
+	Prefix = "/v1"
+
+	ReadTimeout     = 10 * time.Second
+	ShutdownTimeout = 5 * time.Second
+	MaxBodySize     = 16 << 20
+
+	requestIdHeader = "X-Request-Id"
+
+	// adminPrefix starts the paths of the admin routes. The gateway is not
+	// authenticated, so it serves none of them, not even inside a batch.
+	adminPrefix = "/admin"
+	batchPath   = "/batch"
+)
+
+// queryAliases maps short query parameters to body fields.
+var queryAliases = map[string]string{
+	"q": "query",
+}
+
+type Config struct {
+	Port int
+	// ReadTimeout bounds reading the request headers and body.
+	ReadTimeout time.Duration
+	// ShutdownTimeout bounds how long Shutdown waits for running requests.
+	ShutdownTimeout time.Duration
+	// MaxBodySize is the largest request body accepted, in bytes.
+	MaxBodySize int64
+}
+
+func (config Config) withDefaults() Config {
+	if config.ReadTimeout <= 0 {
+		config.ReadTimeout = ReadTimeout
+	}
+
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = ShutdownTimeout
+	}
+
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = MaxBodySize
+	}
+
+	return config
+}
+
+// Gateway serves the router over plain HTTP/JSON for clients that do not
+// speak the framed TCP protocol. Requests are translated to tcpRouter
+// requests and handled by the very same handlers.
+type Gateway struct {
+	config     Config
+	router     Router
+	logger     Logger
+	server     *http.Server
+	listener   net.Listener
+	requestIds atomic.Int64
+}
+
+func New(config Config, router Router, logger Logger) *Gateway {
+	config = config.withDefaults()
+	gateway := &Gateway{
+		config: config,
+		router: router,
+		logger: logger,
+	}
+
+	gateway.server = &http.Server{
+		Handler:     gateway,
+		ReadTimeout: config.ReadTimeout,
+	}
+
+	return gateway
+}
+
+func (gateway *Gateway) Start() error {
+	if err := gateway.Listen(); err != nil {
+		return err
+	}
+
+	return gateway.Serve()
+}
+
+func (gateway *Gateway) Listen() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", gateway.config.Port))
+	if err != nil {
+		return err
+	}
+
+	gateway.listener = listener
+	msg := fmt.Sprintf("http gateway is listening on %v", listener.Addr())
+	gateway.logger.Log(msg)
+	return nil
+}
+
+func (gateway *Gateway) Addr() net.Addr {
+	return gateway.listener.Addr()
+}
+
+// Serve handles requests until Shutdown, after which it returns nil.
+func (gateway *Gateway) Serve() error {
+	err := gateway.server.Serve(gateway.listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown stops accepting requests and waits for the running ones up to
+// ShutdownTimeout. It fits tcpServer.Server.RegisterOnDrain.
+func (gateway *Gateway) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), gateway.config.ShutdownTimeout)
+	defer cancel()
+
+	if err := gateway.server.Shutdown(ctx); err != nil {
+		msg := fmt.Sprintf("http gateway shutdown: %v", err)
+		gateway.logger.Log(msg)
+	}
+}
+
+func (gateway *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request, err := gateway.translateRequest(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writer := newResponseWriter(w, r.Context().Done())
+	if err = gateway.router.Handle(r.Context(), request, writer); err != nil {
+		msg := fmt.Sprintf("http gateway request [%v] %v %v failed: %v",
+			request.Id, request.RequestMeta.Method, request.RequestMeta.Path, err)
+		gateway.logger.Log(msg)
+	}
+}
+
+type gatewayError struct {
+	status  int
+	message string
+}
+
+func (err *gatewayError) Error() string {
+	return err.message
+}
+
+var errAdminRoute = &gatewayError{http.StatusForbidden, "admin routes are not served over http"}
+
+func isAdminPath(path string) bool {
+	return path == adminPrefix || strings.HasPrefix(path, adminPrefix+"/")
+}
+
+// batchReachesAdmin reports whether a batch holds a request to an admin
+// route. A body that is not a batch is left to the batch handler to reject.
+func batchReachesAdmin(body []byte) bool {
+	var batch struct {
+		Requests []struct {
+			Path string `json:"path"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return false
+	}
+
+	for _, item := range batch.Requests {
+		if isAdminPath(item.Path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	var gwErr *gatewayError
+	if errors.As(err, &gwErr) {
+		status = gwErr.status
+	}
+
+	writeJSON(w, status, map[string]string{"message": err.Error()})
+}
+
+// translateRequest builds the router request. The body is taken as is
+// when present, otherwise query parameters become the fields of a JSON body.
+func (gateway *Gateway) translateRequest(w http.ResponseWriter, r *http.Request) (*tcpRouter.Request, error) {
+	path, ok := strings.CutPrefix(r.URL.Path, Prefix)
+	if !ok || path == "" {
+		return nil, &gatewayError{http.StatusNotFound, "route not found"}
+	}
+
+	if isAdminPath(path) {
+		return nil, errAdminRoute
+	}
+
+	method := tcpRouter.RequestMethod(r.Method)
+	if err := method.Validate(); err != nil {
+		return nil, &gatewayError{http.StatusMethodNotAllowed, err.Error()}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, gateway.config.MaxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, &gatewayError{http.StatusRequestEntityTooLarge, "request body is too large"}
+		}
+		return nil, err
+	}
+
+	if path == batchPath && batchReachesAdmin(body) {
+		return nil, errAdminRoute
+	}
+
+	query := r.URL.Query()
+	stream, _ := strconv.ParseBool(query.Get("stream"))
+	query.Del("stream")
+
+	if len(body) == 0 && len(query) > 0 {
+		if body, err = queryToBody(query); err != nil {
+			return nil, err
+		}
+	}
+
+	requestId := r.Header.Get(requestIdHeader)
+	if requestId == "" {
+		requestId = "http-" + strconv.FormatInt(gateway.requestIds.Add(1), 10)
+	}
+
+	return &tcpRouter.Request{
+		Id: requestId,
+		RequestMeta: tcpRouter.RequestMeta{
+			Path:   tcpRouter.RequestPath(path),
+			Method: method,
+		},
+		Body:   body,
+		Stream: stream,
+	}, nil
+}
+
+func queryToBody(query map[string][]string) ([]byte, error) {
+	fields := make(map[string]string, len(query))
+	for name, values := range query {
+		if alias, ok := queryAliases[name]; ok {
+			name = alias
+		}
+		fields[name] = values[0]
+	}
+
+	return json.Marshal(fields)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,164 @@
+package httpGateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"strings"
+	"testing"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/mock"
+)
+
+var logs = mock.NewLogger()
+
+type searchBody struct {
+	Query string `json:"query"`
+}
+
+func startTestGateway(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	router := tcpRouter.New(logs)
+	router.AddRoute(tcpRouter.GET, "/index/search", func(ctx *tcpRouter.RequestContext) error {
+		var body searchBody
+		if err := ctx.ShouldParseBody(&body); err != nil {
+			return ctx.Response(tcpRouter.StatusBadRequest, map[string]string{"message": err.Error()})
+		}
+
+		if !ctx.Request.Stream {
+			return ctx.Response(tcpRouter.StatusOK, map[string][]string{"files": {body.Query}})
+		}
+
+		stream := ctx.Stream(tcpRouter.StatusOK)
+		for _, word := range strings.Fields(body.Query) {
+			if err := stream.Send(word); err != nil {
+				return err
+			}
+		}
+		return stream.Close()
+	})
+	router.AddRoute(tcpRouter.POST, "/index/file", func(ctx *tcpRouter.RequestContext) error {
+		return ctx.Response(tcpRouter.StatusConflict, map[string]string{"message": "already indexed"})
+	})
+	router.AddRoute(tcpRouter.DELETE, "/index/file", func(ctx *tcpRouter.RequestContext) error {
+		return ctx.Response(tcpRouter.StatusNoContent, nil)
+	})
+	router.AddRoute(tcpRouter.GET, "/admin/stats", func(ctx *tcpRouter.RequestContext) error {
+		return ctx.Response(tcpRouter.StatusOK, map[string]int{"workers": 1})
+	})
+	router.AddRoute(tcpRouter.POST, "/batch", func(ctx *tcpRouter.RequestContext) error {
+		return ctx.Response(tcpRouter.StatusOK, map[string]string{"message": "batched"})
+	})
+
+	server := httptest.NewServer(New(Config{}, router, logs))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doRequest(t *testing.T, method, url, body string) (*http.Response, string) {
+	t.Helper()
+
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response, strings.TrimSpace(string(data))
+}
+
+func TestGatewayTranslatesRequests(t *testing.T) {
+	server := startTestGateway(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		result string
+	}{
+		{"query alias", http.MethodGet, "/v1/index/search?q=movie", "", http.StatusOK, `{"files":["movie"]}`},
+		{"query field", http.MethodGet, "/v1/index/search?query=film", "", http.StatusOK, `{"files":["film"]}`},
+		{"json body", http.MethodGet, "/v1/index/search", `{"query":"plot"}`, http.StatusOK, `{"files":["plot"]}`},
+		{"empty body", http.MethodGet, "/v1/index/search", "", http.StatusBadRequest, `{"message":"body is empty"}`},
+		{"status mapping", http.MethodPost, "/v1/index/file", `{"fileName":"a.txt"}`, http.StatusConflict,
+			`{"message":"already indexed"}`},
+		{"no content", http.MethodDelete, "/v1/index/file", `{"fileName":"a.txt"}`, http.StatusNoContent, ""},
+		{"unknown route", http.MethodGet, "/v1/nope", "", http.StatusNotFound, `"route not found"`},
+		{"wrong method", http.MethodPost, "/v1/index/search", "", http.StatusMethodNotAllowed,
+			`"method not allowed"`},
+		{"unsupported method", http.MethodPut, "/v1/index/file", "", http.StatusMethodNotAllowed,
+			`{"message":"invalid request method"}`},
+		{"missing prefix", http.MethodGet, "/index/search?q=movie", "", http.StatusNotFound,
+			`{"message":"route not found"}`},
+		{"admin route", http.MethodGet, "/v1/admin/stats", "", http.StatusForbidden,
+			`{"message":"admin routes are not served over http"}`},
+		{"admin route in batch", http.MethodPost, "/v1/batch",
+			`{"requests":[{"method":"GET","path":"/index/search"},{"method":"GET","path":"/admin/stats"}]}`,
+			http.StatusForbidden, `{"message":"admin routes are not served over http"}`},
+		{"batch", http.MethodPost, "/v1/batch", `{"requests":[{"method":"GET","path":"/index/search"}]}`,
+			http.StatusOK, `{"message":"batched"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, body := doRequest(t, test.method, server.URL+test.path, test.body)
+			if response.StatusCode != test.status {
+				t.Errorf("expected status %v, got %v", test.status, response.StatusCode)
+			}
+
+			if body != test.result {
+				t.Errorf("expected body %v, got %v", test.result, body)
+			}
+		})
+	}
+}
+
+func TestGatewayStreamsNDJSON(t *testing.T) {
+	server := startTestGateway(t)
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/index/search?q=a+b+c&stream=true", nil)
+	request.Header.Set(requestIdHeader, "req-1")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("expected application/x-ndjson, got %v", contentType)
+	}
+
+	if requestId := response.Header.Get(requestIdHeader); requestId != "req-1" {
+		t.Errorf("expected the request id to be echoed, got %v", requestId)
+	}
+
+	var parts []string
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		var part string
+		if err = json.Unmarshal(scanner.Bytes(), &part); err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part)
+	}
+
+	if strings.Join(parts, ",") != "a,b,c" {
+		t.Errorf("expected parts a,b,c, got %v", parts)
+	}
+}
@@ -0,0 +1,113 @@
+package httpGateway
+
+import (
+	"encoding/json"
+	"net/http"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"sync"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+)
+
+// httpStatuses maps router statuses to HTTP ones, indexed by ResponseStatus.
+var httpStatuses = [...]int{
+	tcpRouter.StatusOK:                  http.StatusOK,
+	tcpRouter.StatusProcessing:          http.StatusAccepted,
+	tcpRouter.StatusNotFound:            http.StatusNotFound,
+	tcpRouter.StatusBadRequest:          http.StatusBadRequest,
+	tcpRouter.StatusInternalServerError: http.StatusInternalServerError,
+	tcpRouter.StatusCreated:             http.StatusCreated,
+	tcpRouter.StatusNoContent:           http.StatusNoContent,
+	tcpRouter.StatusConflict:            http.StatusConflict,
+	tcpRouter.StatusUnauthorized:        http.StatusUnauthorized,
+	tcpRouter.StatusForbidden:           http.StatusForbidden,
+	tcpRouter.StatusMethodNotAllowed:    http.StatusMethodNotAllowed,
+	tcpRouter.StatusPayloadTooLarge:     http.StatusRequestEntityTooLarge,
+	tcpRouter.StatusTooManyRequests:     http.StatusTooManyRequests,
+	tcpRouter.StatusServiceUnavailable:  http.StatusServiceUnavailable,
+}
+
+func httpStatus(status tcpRouter.ResponseStatus) int {
+	if err := status.Validate(); err != nil {
+		return http.StatusInternalServerError
+	}
+
+	return httpStatuses[status]
+}
+
+// responseWriter translates router responses to HTTP. A message becomes
+// the whole response, a stream is sent as newline-delimited JSON with one
+// line per part. Once the status line is out, later messages, such as an
+// error after a handler already answered, can only be appended.
+type responseWriter struct {
+	w           http.ResponseWriter
+	done        <-chan struct{}
+	lock        sync.Mutex
+	wroteHeader bool
+}
+
+func newResponseWriter(w http.ResponseWriter, done <-chan struct{}) *responseWriter {
+	return &responseWriter{
+		w:    w,
+		done: done,
+	}
+}
+
+// Done is closed when the HTTP client disconnects.
+func (writer *responseWriter) Done() <-chan struct{} {
+	return writer.done
+}
+
+func (writer *responseWriter) WriteResponseFrame(
+	kind streamer.FrameKind,
+	_ streamer.ContentType,
+	response *tcpRouter.Response,
+) error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	switch kind {
+	case streamer.FrameStreamChunk:
+		writer.writeHeader("application/x-ndjson", response)
+		return writer.writeLine(response.Body)
+	case streamer.FrameStreamEnd:
+		writer.flush()
+		return nil
+	default:
+		if writer.wroteHeader {
+			return writer.writeLine(response.Body)
+		}
+
+		writer.writeHeader("application/json", response)
+		if response.Body == nil {
+			return nil
+		}
+		return json.NewEncoder(writer.w).Encode(response.Body)
+	}
+}
+
+func (writer *responseWriter) writeHeader(contentType string, response *tcpRouter.Response) {
+	if writer.wroteHeader {
+		return
+	}
+
+	writer.wroteHeader = true
+	writer.w.Header().Set("Content-Type", contentType)
+	writer.w.Header().Set(requestIdHeader, response.Id)
+	writer.w.WriteHeader(httpStatus(response.Status))
+}
+
+func (writer *responseWriter) writeLine(body any) error {
+	if err := json.NewEncoder(writer.w).Encode(body); err != nil {
+		return err
+	}
+
+	writer.flush()
+	return nil
+}
+
+func (writer *responseWriter) flush() {
+	if flusher, ok := writer.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
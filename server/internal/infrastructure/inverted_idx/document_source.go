@@ -0,0 +1,39 @@
+package invertedIdx
+
+import "strings"
+
+// DocumentScheme prefixes the paths of documents that come from the
+// document store instead of the file system, e.g. doc://review-42.
+const DocumentScheme = "doc://"
+
+// DocumentSource reads the content of an indexed document by its path.
+type DocumentSource interface {
+	Read(path string) ([]byte, error)
+}
+
+// DocumentPath returns the path a stored document is indexed under.
+func DocumentPath(id string) string {
+	return DocumentScheme + id
+}
+
+type documentSources struct {
+	files     DocumentSource
+	documents DocumentSource
+}
+
+// NewDocumentSources reads paths with DocumentScheme from documents by
+// their id and every other path from files.
+func NewDocumentSources(files DocumentSource, documents DocumentSource) DocumentSource {
+	return &documentSources{
+		files:     files,
+		documents: documents,
+	}
+}
+
+func (sources *documentSources) Read(path string) ([]byte, error) {
+	if id, ok := strings.CutPrefix(path, DocumentScheme); ok {
+		return sources.documents.Read(id)
+	}
+
+	return sources.files.Read(path)
+}
@@ -1,16 +1,27 @@
 package invertedIdx
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/ArtemLymarenko/parallel-course-work/pkg/set"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+	"io/fs"
 	"log"
 	"regexp"
+	eventBus "server/internal/infrastructure/event_bus"
 	"strings"
 	"sync"
 )
 
+var (
+	ErrAlreadyIndexed = errors.New("file has already been added to index")
+	ErrNotIndexed     = errors.New("file has not been added to index")
+	ErrNotFound       = errors.New("file not found")
+)
+
 type FileManager interface {
-	Read(filePath string) ([]byte, error)
+	DocumentSource
 	GetAllFiles(dir string) ([]string, error)
 }
 
@@ -18,13 +29,24 @@ type Logger interface {
 	Log(...interface{})
 }
 
+type EventPublisher interface {
+	Publish(eventType eventBus.EventType, data any)
+}
+
+type noopPublisher struct{}
+
+func (noopPublisher) Publish(eventBus.EventType, any) {}
+
 type InvertedIndex struct {
 	storage        *SyncHashMap
 	processedFiles *set.Set[string]
 	processedLock  sync.RWMutex
 	commonWords    *set.Set[string]
 	fileManager    FileManager
+	source         DocumentSource
+	executor       threadpool.Executor
 	logger         Logger
+	events         EventPublisher
 }
 
 func New(fileManager FileManager, logger Logger) *InvertedIndex {
@@ -47,13 +69,34 @@ func New(fileManager FileManager, logger Logger) *InvertedIndex {
 		processedFiles: set.NewSet[string](),
 		commonWords:    commonWords,
 		fileManager:    fileManager,
+		source:         fileManager,
 		processedLock:  sync.RWMutex{},
 		logger:         logger,
+		events:         noopPublisher{},
 	}
 
 	return invIndex
 }
 
+// SetEventPublisher makes the index publish file and build events to
+// events. It has to be called before the index is used.
+func (i *InvertedIndex) SetEventPublisher(events EventPublisher) {
+	i.events = events
+}
+
+// SetExecutor makes Build index files as tasks of executor, usually the
+// shared thread pool, instead of goroutines of its own.
+func (i *InvertedIndex) SetExecutor(executor threadpool.Executor) {
+	i.executor = executor
+}
+
+// SetDocumentSource makes the index read documents from source instead of
+// the file manager, e.g. to index stored documents alongside files. It has
+// to be called before the index is used.
+func (i *InvertedIndex) SetDocumentSource(source DocumentSource) {
+	i.source = source
+}
+
 func (i *InvertedIndex) parseText(content string) []string {
 	text := strings.TrimSpace(strings.ToLower(content))
 
@@ -85,8 +128,8 @@ func (i *InvertedIndex) Build(resourceDir string, threadCount int) {
 		log.Fatalf("could not read the directory: %v", err)
 	}
 
-	wg := sync.WaitGroup{}
-	wg.Add(threadCount)
+	tracker := eventBus.NewBuildTracker(i.events, "build", len(filePaths))
+	chunks := make([][]string, threadCount)
 
 	totalChunks := len(filePaths) / threadCount
 	startIdx, endIdx := 0, 0
@@ -98,21 +141,61 @@ func (i *InvertedIndex) Build(resourceDir string, threadCount int) {
 			endIdx = totalChunks * (threadIdx + 1)
 		}
 
-		filePathsChunk := filePaths[startIdx:endIdx]
-		go func() {
-			i.BuildFiles(filePathsChunk)
-			wg.Done()
-		}()
+		chunks[threadIdx] = filePaths[startIdx:endIdx]
+	}
+
+	if i.executor != nil {
+		i.buildOnExecutor(chunks, tracker)
+	} else {
+		wg := sync.WaitGroup{}
+		wg.Add(threadCount)
+		for _, filePathsChunk := range chunks {
+			go func() {
+				i.buildFiles(filePathsChunk, tracker)
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+	}
+
+	tracker.Complete()
+}
+
+// buildOnExecutor indexes every chunk as a task of the executor. Chunks
+// the executor does not accept, e.g. because the pool is not running yet
+// or its queue is full, are indexed by the caller.
+func (i *InvertedIndex) buildOnExecutor(chunks [][]string, tracker *eventBus.BuildTracker) {
+	futures := make([]*threadpool.Future[struct{}], len(chunks))
+	for idx, filePathsChunk := range chunks {
+		futures[idx] = threadpool.Submit(i.executor, func(ctx context.Context) (struct{}, error) {
+			i.buildFiles(filePathsChunk, tracker)
+			return struct{}{}, nil
+		})
 	}
 
-	wg.Wait()
+	for idx, future := range futures {
+		_, err := future.Wait()
+		if errors.Is(err, threadpool.ErrTaskNotAdded) || errors.Is(err, threadpool.ErrQueueFull) {
+			i.buildFiles(chunks[idx], tracker)
+		}
+	}
 }
 
 func (i *InvertedIndex) BuildFiles(filePaths []string) {
+	tracker := eventBus.NewBuildTracker(i.events, "build", len(filePaths))
+	i.buildFiles(filePaths, tracker)
+	tracker.Complete()
+}
+
+// buildFiles indexes files without publishing an event per file,
+// the tracker reports the progress of the whole build instead.
+func (i *InvertedIndex) buildFiles(filePaths []string, tracker *eventBus.BuildTracker) {
 	idx := 0
 	processedFile := make([]string, len(filePaths))
 	for _, filePath := range filePaths {
-		if err := i.AddFile(filePath); err != nil {
+		err := i.addFile(filePath)
+		tracker.FileDone(err)
+		if err != nil {
 			i.logger.Log(err)
 			continue
 		}
@@ -124,11 +207,20 @@ func (i *InvertedIndex) BuildFiles(filePaths []string) {
 }
 
 func (i *InvertedIndex) AddFile(filePath string) error {
+	if err := i.addFile(filePath); err != nil {
+		return err
+	}
+
+	i.events.Publish(eventBus.FileAdded, eventBus.FileEvent{Path: filePath})
+	return nil
+}
+
+func (i *InvertedIndex) addFile(filePath string) error {
 	if i.HasFileProcessed(filePath) {
-		return errors.New("file has already been added to index")
+		return ErrAlreadyIndexed
 	}
 
-	fileContent, err := i.fileManager.Read(filePath)
+	fileContent, err := i.readFile(filePath)
 	if err != nil {
 		return err
 	}
@@ -159,7 +251,7 @@ func (i *InvertedIndex) setProcessedFiles(files []string) {
 }
 
 func (i *InvertedIndex) GetFileContent(filePath string) ([]byte, error) {
-	fileContent, err := i.fileManager.Read(filePath)
+	fileContent, err := i.readFile(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -167,12 +259,21 @@ func (i *InvertedIndex) GetFileContent(filePath string) ([]byte, error) {
 	return fileContent, nil
 }
 
+func (i *InvertedIndex) readFile(filePath string) ([]byte, error) {
+	fileContent, err := i.source.Read(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, filePath)
+	}
+
+	return fileContent, err
+}
+
 func (i *InvertedIndex) RemoveFile(filePath string) error {
 	if !i.HasFileProcessed(filePath) {
-		return errors.New("nothing to remove")
+		return ErrNotIndexed
 	}
 
-	fileContent, err := i.fileManager.Read(filePath)
+	fileContent, err := i.readFile(filePath)
 	if err != nil {
 		return err
 	}
@@ -183,9 +284,10 @@ func (i *InvertedIndex) RemoveFile(filePath string) error {
 	}
 
 	i.processedLock.Lock()
-	defer i.processedLock.Unlock()
 	i.processedFiles.Remove(filePath)
+	i.processedLock.Unlock()
 
+	i.events.Publish(eventBus.FileRemoved, eventBus.FileEvent{Path: filePath})
 	return nil
 }
 
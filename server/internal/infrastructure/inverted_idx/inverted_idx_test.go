@@ -2,9 +2,13 @@ package invertedIdx
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"github.com/ArtemLymarenko/parallel-course-work/pkg/mock"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
 	"os"
+	"runtime"
+	docStore "server/internal/infrastructure/doc_store"
 	fileManager "server/internal/infrastructure/file_manager"
 	"slices"
 	"sync"
@@ -125,6 +129,31 @@ func TestInvertedIndexAddFile(t *testing.T) {
 	}
 }
 
+func TestInvertedIndexTypedErrors(t *testing.T) {
+	invIdx := New(fileManager.New(logs), logs)
+
+	const file = "test_files/0_2.txt"
+	if err := invIdx.AddFile(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := invIdx.AddFile(file); !errors.Is(err, ErrAlreadyIndexed) {
+		t.Errorf("expected ErrAlreadyIndexed, got %v", err)
+	}
+
+	if err := invIdx.RemoveFile("test_files/2_3.txt"); !errors.Is(err, ErrNotIndexed) {
+		t.Errorf("expected ErrNotIndexed, got %v", err)
+	}
+
+	if err := invIdx.AddFile("test_files/missing.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := invIdx.GetFileContent("test_files/missing.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
 func TestInvertedIndex_Build(t *testing.T) {
 	reader := fileManager.New(logs)
 	invIdx := New(reader, logs)
@@ -180,3 +209,114 @@ func Test_Build_Multi(t *testing.T) {
 		})
 	}
 }
+
+func TestIndexStoredDocuments(t *testing.T) {
+	files := fileManager.New(logs)
+	documents := docStore.New()
+	invIdx := New(files, logs)
+	invIdx.SetDocumentSource(NewDocumentSources(files, documents))
+	invIdx.Build("test_files/", 1)
+
+	if err := documents.Put(docStore.Document{Id: "virtual", Text: "zanzibar quokka"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	path := DocumentPath("virtual")
+	if err := invIdx.AddFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := invIdx.Search("quokka"); !slices.Equal(result, []string{path}) {
+		t.Errorf("expected %v, got %v", path, result)
+	}
+
+	content, err := invIdx.GetFileContent(path)
+	if err != nil || string(content) != "zanzibar quokka" {
+		t.Errorf("unexpected content %q: %v", content, err)
+	}
+
+	if err = invIdx.AddFile(DocumentPath("missing")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing document, got %v", err)
+	}
+
+	if err = invIdx.RemoveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if result := invIdx.Search("quokka"); len(result) != 0 {
+		t.Errorf("removed document should not be found, got %v", result)
+	}
+}
+
+func TestInvertedIndexBuildOnThreadPool(t *testing.T) {
+	expected := New(fileManager.New(logs), logs)
+	expected.Build("test_files/", 1)
+
+	pool := threadpool.New(logs)
+	pool.MustRun(3)
+	defer pool.MustTerminate()
+
+	invIdx := New(fileManager.New(logs), logs)
+	invIdx.SetExecutor(pool)
+	invIdx.Build("test_files/", 4)
+
+	for _, query := range []string{"always", "movie", "brilliant"} {
+		result, want := invIdx.Search(query), expected.Search(query)
+		slices.Sort(result)
+		slices.Sort(want)
+		if !slices.Equal(result, want) {
+			t.Errorf("%v: expected %v, got %v", query, want, result)
+		}
+	}
+
+	// A pool that does not run leaves the build to the caller.
+	stopped := New(fileManager.New(logs), logs)
+	stopped.SetExecutor(threadpool.New(logs))
+	stopped.Build("test_files/", 2)
+	if stopped.storage.GetSize() != expected.storage.GetSize() {
+		t.Errorf("expected %v words, got %v", expected.storage.GetSize(), stopped.storage.GetSize())
+	}
+}
+
+// pool is what the build benchmarks need from a thread pool.
+type pool interface {
+	threadpool.Executor
+	MustRun(threadCount int)
+	MustTerminate()
+}
+
+// benchmarkBuildOnPool indexes the bundled corpus with every file a task
+// of its own, so the pool and not the chunking decides how work spreads.
+func benchmarkBuildOnPool(b *testing.B, newPool func() pool) {
+	resourceDir := "../../../resources/data/"
+	filePaths, err := fileManager.New(logs).GetAllFiles(resourceDir)
+	if err != nil || len(filePaths) == 0 {
+		b.Skipf("corpus %v is not available: %v", resourceDir, err)
+	}
+
+	for range b.N {
+		b.StopTimer()
+		pool := newPool()
+		pool.MustRun(runtime.NumCPU())
+		invIdx := New(fileManager.New(logs), logs)
+		invIdx.SetExecutor(pool)
+		b.StartTimer()
+
+		invIdx.Build(resourceDir, len(filePaths))
+
+		b.StopTimer()
+		pool.MustTerminate()
+		b.StartTimer()
+	}
+}
+
+func BenchmarkBuildOnThreadPool(b *testing.B) {
+	benchmarkBuildOnPool(b, func() pool {
+		return threadpool.New(logs)
+	})
+}
+
+func BenchmarkBuildOnStealingPool(b *testing.B) {
+	benchmarkBuildOnPool(b, func() pool {
+		return threadpool.NewStealingPool(logs)
+	})
+}
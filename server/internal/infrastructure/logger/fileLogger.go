@@ -55,6 +55,10 @@ func (l *fileLogger) flush() {
 	l.logsBuffer = l.logsBuffer[:0]
 }
 
+func (l *fileLogger) Flush() {
+	l.flush()
+}
+
 func (l *fileLogger) Close() {
 	l.flush()
 	_ = l.file.Close()
@@ -10,6 +10,7 @@ import (
 
 type FileLogger interface {
 	LogUnsafe(...interface{})
+	Flush()
 	Close()
 }
 
@@ -47,6 +48,13 @@ func (l *logger) Log(v ...interface{}) {
 	}
 }
 
+// Flush writes the buffered batch to the file without waiting for it to fill up.
+func (l *logger) Flush() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.fileLogger.Flush()
+}
+
 func (l *logger) Close() {
 	l.lock.Lock()
 	defer l.lock.Unlock()
@@ -0,0 +1,89 @@
+package tcpRouter
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+)
+
+const ChunkSize = 2048
+
+// Conn is a client connection shared by every request read from it.
+// Responses may be produced concurrently by different workers, so whole
+// frames are written under a lock and never interleave on the wire.
+type Conn struct {
+	net.Conn
+	writeLock   sync.Mutex
+	compression streamer.Compression
+	ctx         context.Context
+	disconnect  context.CancelFunc
+}
+
+func NewConn(conn net.Conn) *Conn {
+	ctx, disconnect := context.WithCancel(context.Background())
+	return &Conn{
+		Conn:       conn,
+		ctx:        ctx,
+		disconnect: disconnect,
+	}
+}
+
+// Done is closed once the client is gone, long running handlers should
+// stop when it is.
+func (conn *Conn) Done() <-chan struct{} {
+	return conn.ctx.Done()
+}
+
+// Context is cancelled once the client is gone, nothing written to the
+// connection reaches it anymore.
+func (conn *Conn) Context() context.Context {
+	return conn.ctx
+}
+
+// Disconnect marks the client as gone, which cancels its requests.
+func (conn *Conn) Disconnect() {
+	conn.disconnect()
+}
+
+func (conn *Conn) WriteFrame(frame streamer.Frame) error {
+	conn.writeLock.Lock()
+	defer conn.writeLock.Unlock()
+	return streamer.WriteFrameCompressed(conn.Conn, ChunkSize, frame, conn.compression)
+}
+
+// Negotiate picks the codec for every following response from the
+// encodings the client accepts. Payloads under threshold stay uncompressed.
+func (conn *Conn) Negotiate(acceptEncoding []string, threshold int) streamer.Codec {
+	conn.writeLock.Lock()
+	defer conn.writeLock.Unlock()
+
+	conn.compression = streamer.Compression{
+		Codec:     streamer.NegotiateCodec(acceptEncoding),
+		Threshold: threshold,
+	}
+
+	return conn.compression.Codec
+}
+
+// WriteResponse writes response as a single JSON message.
+func (conn *Conn) WriteResponse(response *Response) error {
+	return conn.WriteResponseFrame(streamer.FrameMessage, streamer.ContentJSON, response)
+}
+
+// WriteResponseFrame writes response as a single frame of the given kind.
+// Every frame of a stream carries the full envelope, so frames of several
+// streams on one connection can interleave and still be told apart by id.
+func (conn *Conn) WriteResponseFrame(
+	kind streamer.FrameKind,
+	contentType streamer.ContentType,
+	response *Response,
+) error {
+	frame, err := response.Encode(kind, contentType)
+	if err != nil {
+		return err
+	}
+
+	return conn.WriteFrame(frame)
+}
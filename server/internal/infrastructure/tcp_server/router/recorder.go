@@ -0,0 +1,58 @@
+package tcpRouter
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+)
+
+var ErrRecorderStream = errors.New("recorded responses cannot be streamed")
+
+// ResponseRecorder keeps the response written to it instead of sending it,
+// so a handler can run other routes and use their results. Only the first
+// message is kept: the router answers again when a handler fails after it
+// already responded, and the client would have ignored that answer too.
+type ResponseRecorder struct {
+	done     <-chan struct{}
+	lock     sync.Mutex
+	response *Response
+}
+
+// NewResponseRecorder creates a recorder whose requests are cancelled
+// when done is closed, done may be nil.
+func NewResponseRecorder(done <-chan struct{}) *ResponseRecorder {
+	return &ResponseRecorder{
+		done: done,
+	}
+}
+
+func (recorder *ResponseRecorder) WriteResponseFrame(
+	kind streamer.FrameKind,
+	_ streamer.ContentType,
+	response *Response,
+) error {
+	if kind != streamer.FrameMessage {
+		return ErrRecorderStream
+	}
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	if recorder.response == nil {
+		recorder.response = response
+	}
+
+	return nil
+}
+
+// Response is the recorded response, nil when the handler did not answer.
+func (recorder *ResponseRecorder) Response() *Response {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	return recorder.response
+}
+
+func (recorder *ResponseRecorder) Done() <-chan struct{} {
+	return recorder.done
+}
@@ -1,8 +1,12 @@
 package tcpRouter
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/binenc"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
 )
 
 var (
@@ -11,9 +15,57 @@ var (
 )
 
 type Request struct {
+	Id              string          `json:"id,omitempty"`
 	RequestMeta     RequestMeta     `json:"meta"`
 	Body            json.RawMessage `json:"body,omitempty"`
 	ConnectionAlive bool            `json:"connectionAlive,omitempty"`
+	Stream          bool            `json:"stream,omitempty"`
+	// AcceptEncoding lists the codecs the client can read, in order of
+	// preference. Only the first request of a connection negotiates.
+	AcceptEncoding []string `json:"acceptEncoding,omitempty"`
+	// ContentType is the encoding the request arrived in, its body is raw
+	// data in the same encoding and responses are sent back in it.
+	ContentType streamer.ContentType `json:"-"`
+}
+
+const (
+	requestFlagConnectionAlive = 1 << iota
+	requestFlagStream
+)
+
+// MarshalBinary encodes the binary request envelope: id, path, method,
+// flags, accepted encodings and body.
+func (request *Request) MarshalBinary() ([]byte, error) {
+	var flags uint64
+	if request.ConnectionAlive {
+		flags |= requestFlagConnectionAlive
+	}
+	if request.Stream {
+		flags |= requestFlagStream
+	}
+
+	writer := binenc.NewWriter(len(request.Body) + 64)
+	writer.WriteString(request.Id)
+	writer.WriteString(string(request.RequestMeta.Path))
+	writer.WriteString(string(request.RequestMeta.Method))
+	writer.WriteUvarint(flags)
+	writer.WriteStrings(request.AcceptEncoding)
+	writer.WriteBytes(request.Body)
+	return writer.Data(), nil
+}
+
+func (request *Request) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	request.Id = reader.ReadString()
+	request.RequestMeta.Path = RequestPath(reader.ReadString())
+	request.RequestMeta.Method = RequestMethod(reader.ReadString())
+	flags := reader.ReadUvarint()
+	request.AcceptEncoding = reader.ReadStrings()
+	request.Body = bytes.Clone(reader.ReadBytes())
+	request.ConnectionAlive = flags&requestFlagConnectionAlive != 0
+	request.Stream = flags&requestFlagStream != 0
+	request.ContentType = streamer.ContentBinary
+	return reader.Close()
 }
 
 type RequestMeta struct {
@@ -1,25 +1,46 @@
 package tcpRouter
 
 import (
+	"context"
+	"encoding"
 	"encoding/json"
 	"errors"
-	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
-	"net"
 	"reflect"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
 )
 
 type RequestContext struct {
 	Request *Request
-	Conn    net.Conn
+	Writer  ResponseWriter
+	// Params holds the values of the {name} segments of the route path.
+	Params map[string]string
+	ctx    context.Context
+	done   <-chan struct{}
 }
 
-func NewRequestContext(request *Request, conn net.Conn) *RequestContext {
+func NewRequestContext(ctx context.Context, request *Request, writer ResponseWriter) *RequestContext {
 	return &RequestContext{
 		Request: request,
-		Conn:    conn,
+		Writer:  writer,
+		ctx:     ctx,
+		done:    ctx.Done(),
 	}
 }
 
+// Context is cancelled when the request should stop: its client is gone,
+// the task of the request is cancelled or it ran out of time. Handlers
+// check it between steps.
+func (requestCtx *RequestContext) Context() context.Context {
+	return requestCtx.ctx
+}
+
+// Param returns the value of the {name} segment of the route path,
+// empty when the route has no such segment.
+func (requestCtx *RequestContext) Param(name string) string {
+	return requestCtx.Params[name]
+}
+
 func (requestCtx *RequestContext) ShouldParseBodyJSON(body any) error {
 	err := json.Unmarshal(requestCtx.Request.Body, body)
 
@@ -30,6 +51,48 @@ func (requestCtx *RequestContext) ShouldParseBodyJSON(body any) error {
 	return err
 }
 
+// ShouldParseBody decodes the body in the encoding the request arrived in,
+// binary bodies need body to implement encoding.BinaryUnmarshaler.
+func (requestCtx *RequestContext) ShouldParseBody(body any) error {
+	if requestCtx.Request.ContentType != streamer.ContentBinary {
+		return requestCtx.ShouldParseBodyJSON(body)
+	}
+
+	err := requestCtx.decodeBody(body)
+	if errors.Is(err, ErrBinaryUnsupported) {
+		return err
+	}
+
+	if isStructEmpty(body) {
+		return errors.New("body is empty")
+	}
+
+	return err
+}
+
+// ParseOptionalBody decodes the body of routes where it may be left out,
+// body keeps its zero value when the request has none.
+func (requestCtx *RequestContext) ParseOptionalBody(body any) error {
+	if len(requestCtx.Request.Body) == 0 {
+		return nil
+	}
+
+	return requestCtx.decodeBody(body)
+}
+
+func (requestCtx *RequestContext) decodeBody(body any) error {
+	if requestCtx.Request.ContentType != streamer.ContentBinary {
+		return json.Unmarshal(requestCtx.Request.Body, body)
+	}
+
+	unmarshaler, ok := body.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return ErrBinaryUnsupported
+	}
+
+	return unmarshaler.UnmarshalBinary(requestCtx.Request.Body)
+}
+
 func isStructEmpty(s any) bool {
 	val := reflect.ValueOf(s).Elem()
 
@@ -45,16 +108,39 @@ func isStructEmpty(s any) bool {
 }
 
 func (requestCtx *RequestContext) ResponseJSON(status ResponseStatus, data any) error {
-	response := Response{
+	response := &Response{
+		Id:     requestCtx.Request.Id,
 		Status: status,
 		Body:   data,
 	}
 
-	jsonResponse, err := json.Marshal(response)
-	if err != nil {
-		return err
+	return requestCtx.Writer.WriteResponseFrame(streamer.FrameMessage, streamer.ContentJSON, response)
+}
+
+// Response answers in the encoding the request arrived in. Data without
+// a binary form is sent as JSON even to binary requests.
+func (requestCtx *RequestContext) Response(status ResponseStatus, data any) error {
+	response := &Response{
+		Id:     requestCtx.Request.Id,
+		Status: status,
+		Body:   data,
 	}
 
-	err = streamer.WriteBuff(requestCtx.Conn, 2048, jsonResponse)
-	return err
+	return requestCtx.Writer.WriteResponseFrame(streamer.FrameMessage, requestCtx.Request.ContentType, response)
+}
+
+// Stream starts a streamed response. It should only be used when the client
+// asked for one with Request.Stream, older clients expect a single frame.
+func (requestCtx *RequestContext) Stream(status ResponseStatus) *ResponseStream {
+	return &ResponseStream{
+		requestCtx: requestCtx,
+		status:     status,
+	}
+}
+
+// Done is closed once Context is done or the writer stops the request,
+// e.g. because the server stops reading the connection. Only long lived
+// handlers such as subscriptions watch it, others drain with Context.
+func (requestCtx *RequestContext) Done() <-chan struct{} {
+	return requestCtx.done
 }
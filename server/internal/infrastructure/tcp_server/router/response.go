@@ -1,41 +1,121 @@
 package tcpRouter
 
 import (
+	"encoding"
+	"encoding/json"
 	"errors"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/binenc"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
 )
 
-var ErrInvalidRequestStatus = errors.New("invalid request status")
+var (
+	ErrInvalidRequestStatus = errors.New("invalid request status")
+	ErrBinaryUnsupported    = errors.New("body has no binary encoding")
+)
 
 type Response struct {
+	Id     string         `json:"id,omitempty"`
 	Status ResponseStatus `json:"status"`
 	Body   any            `json:"body"`
 }
 
 type ResponseStatus int
 
+// Statuses are appended in wire order, existing values must never be renumbered.
 const (
 	StatusOK ResponseStatus = iota
 	StatusProcessing
 	StatusNotFound
 	StatusBadRequest
 	StatusInternalServerError
+	StatusCreated
+	StatusNoContent
+	StatusConflict
+	StatusUnauthorized
+	StatusForbidden
+	StatusMethodNotAllowed
+	StatusPayloadTooLarge
+	StatusTooManyRequests
+	StatusServiceUnavailable
 )
 
+var responseStatusNames = [...]string{
+	"OK",
+	"Processing",
+	"Not Found",
+	"Bad Request",
+	"Internal Server Error",
+	"Created",
+	"No Content",
+	"Conflict",
+	"Unauthorized",
+	"Forbidden",
+	"Method Not Allowed",
+	"Payload Too Large",
+	"Too Many Requests",
+	"Service Unavailable",
+}
+
 func (responseStatus ResponseStatus) Validate() error {
-	switch responseStatus {
-	case StatusOK, StatusNotFound, StatusProcessing, StatusInternalServerError, StatusBadRequest:
-		return nil
-	default:
+	if responseStatus < 0 || int(responseStatus) >= len(responseStatusNames) {
 		return ErrInvalidRequestStatus
 	}
+
+	return nil
 }
 
 func (responseStatus ResponseStatus) String() string {
-	return [...]string{
-		"OK",
-		"Processing",
-		"Not Found",
-		"Bad Request",
-		"Internal Server Error",
-	}[responseStatus]
+	if err := responseStatus.Validate(); err != nil {
+		return "Unknown Status"
+	}
+
+	return responseStatusNames[responseStatus]
+}
+
+// MarshalBinary encodes the binary response envelope: id, status and body.
+// The body has to be nil, raw bytes or an encoding.BinaryMarshaler.
+func (response *Response) MarshalBinary() ([]byte, error) {
+	var body []byte
+	switch data := response.Body.(type) {
+	case nil:
+	case []byte:
+		body = data
+	case encoding.BinaryMarshaler:
+		var err error
+		if body, err = data.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrBinaryUnsupported
+	}
+
+	writer := binenc.NewWriter(len(response.Id) + len(body) + 8)
+	writer.WriteString(response.Id)
+	writer.WriteUvarint(uint64(response.Status))
+	writer.WriteBytes(body)
+	return writer.Data(), nil
+}
+
+// Encode builds a frame of the given kind holding the response in
+// contentType. Bodies without a binary form are sent as JSON instead,
+// the frame tells the client which one it got.
+func (response *Response) Encode(kind streamer.FrameKind, contentType streamer.ContentType) (streamer.Frame, error) {
+	if contentType == streamer.ContentBinary {
+		payload, err := response.MarshalBinary()
+		if err == nil {
+			return streamer.Frame{Kind: kind, ContentType: contentType, Payload: payload}, nil
+		}
+
+		if !errors.Is(err, ErrBinaryUnsupported) {
+			return streamer.Frame{}, err
+		}
+	}
+
+	payload, err := json.Marshal(response)
+	if err != nil {
+		return streamer.Frame{}, err
+	}
+
+	return streamer.Frame{Kind: kind, ContentType: streamer.ContentJSON, Payload: payload}, nil
 }
@@ -0,0 +1,10 @@
+package tcpRouter
+
+import "github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+
+// ResponseWriter receives the responses of a handler. A client Conn writes
+// them to the wire, other transports translate them, so one set of handlers
+// serves all of them.
+type ResponseWriter interface {
+	WriteResponseFrame(kind streamer.FrameKind, contentType streamer.ContentType, response *Response) error
+}
@@ -1,14 +1,22 @@
 package tcpRouter
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"net"
+	"strings"
+	"sync"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
 )
 
-var ErrRouteNotFound = errors.New("route not found")
+var (
+	ErrRouteNotFound    = errors.New("route not found")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+)
 
 type HandlerFunc func(ctx *RequestContext) error
 
@@ -17,19 +25,89 @@ type Logger interface {
 }
 
 type Router struct {
-	routes map[RequestMeta]HandlerFunc
-	logger Logger
+	routes   map[RequestMeta]route
+	patterns []patternRoute
+	logger   Logger
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// route is a handler with the priority class its requests are
+// scheduled in, name is its method and path as registered.
+type route struct {
+	handler  HandlerFunc
+	priority threadpool.Priority
+	name     string
+}
+
+// patternRoute is a route with {name} segments, e.g. /index/jobs/{id},
+// which match any single non-empty path segment.
+type patternRoute struct {
+	route
+	method   RequestMethod
+	segments []string
+}
+
+// match reports whether path fits the route and returns the values of its
+// {name} segments.
+func (pattern *patternRoute) match(path RequestPath) (map[string]string, bool) {
+	segments := strings.Split(string(path), "/")
+	if len(segments) != len(pattern.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, segment := range pattern.segments {
+		name, isParam := paramName(segment)
+		switch {
+		case isParam && segments[i] != "":
+			params[name] = segments[i]
+		case segment != segments[i]:
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func paramName(segment string) (string, bool) {
+	if len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+
+	return "", false
 }
 
 func New(logger Logger) *Router {
-	routes := make(map[RequestMeta]HandlerFunc)
+	routes := make(map[RequestMeta]route)
 	return &Router{
-		routes: routes,
-		logger: logger,
+		routes:  routes,
+		logger:  logger,
+		stopped: make(chan struct{}),
 	}
 }
 
+// Stop closes Done of every running and later request, so subscriptions
+// end, e.g. on shutdown. Their Context is left alone, requests that check
+// it still finish.
+func (router *Router) Stop() {
+	router.stopOnce.Do(func() {
+		close(router.stopped)
+	})
+}
+
 func (router *Router) AddRoute(method RequestMethod, path RequestPath, handlerFunc HandlerFunc) {
+	router.AddRouteWithPriority(method, path, threadpool.PriorityNormal, handlerFunc)
+}
+
+// AddRouteWithPriority adds a route whose requests are scheduled in the
+// priority class, AddRoute uses threadpool.PriorityNormal.
+func (router *Router) AddRouteWithPriority(
+	method RequestMethod,
+	path RequestPath,
+	priority threadpool.Priority,
+	handlerFunc HandlerFunc,
+) {
 	if err := path.Validate(); err != nil {
 		log.Fatal(err)
 	}
@@ -38,22 +116,91 @@ func (router *Router) AddRoute(method RequestMethod, path RequestPath, handlerFu
 		log.Fatal(err)
 	}
 
+	if err := priority.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
 	rm := RequestMeta{path, method}
-	router.routes[rm] = handlerFunc
+	newRoute := route{
+		handler:  handlerFunc,
+		priority: priority,
+		name:     fmt.Sprintf("%v %v", method, path),
+	}
+	if strings.Contains(string(path), "{") {
+		router.patterns = append(router.patterns, patternRoute{
+			route:    newRoute,
+			method:   method,
+			segments: strings.Split(string(path), "/"),
+		})
+	} else {
+		router.routes[rm] = newRoute
+	}
 
-	msg := fmt.Sprintf("Registered route - Method: %v, Path: %v", rm.Method, rm.Path)
+	msg := fmt.Sprintf("Registered route - Method: %v, Path: %v, Priority: %v", rm.Method, rm.Path, priority)
 	router.logger.Log(msg)
 }
 
-func (router *Router) Handle(request *Request, conn net.Conn) error {
-	requestCtx := NewRequestContext(request, conn)
-	handler, err := router.getHandler(requestCtx.Request.RequestMeta)
+// Priority is the priority class of the route meta leads to. Requests
+// without a route are answered right away, they get PriorityNormal.
+func (router *Router) Priority(meta RequestMeta) threadpool.Priority {
+	found, _, err := router.getRoute(meta)
 	if err != nil {
-		_ = requestCtx.ResponseJSON(StatusInternalServerError, err.Error())
+		return threadpool.PriorityNormal
+	}
+
+	return found.priority
+}
+
+// RouteName is the method and path of the route meta leads to as it was
+// registered, e.g. "GET /index/jobs/{id}", empty without a route.
+func (router *Router) RouteName(meta RequestMeta) string {
+	found, _, err := router.getRoute(meta)
+	if err != nil {
+		return ""
+	}
+
+	return found.name
+}
+
+// Handle runs the handler of the request with ctx as its Context. Done
+// of the request is closed along with ctx, once the router is stopped and,
+// when the writer tells, once the request should stop.
+func (router *Router) Handle(ctx context.Context, request *Request, writer ResponseWriter) error {
+	stop, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var writerDone <-chan struct{}
+	if canceler, ok := writer.(interface{ Done() <-chan struct{} }); ok {
+		writerDone = canceler.Done()
+	}
+	go func() {
+		select {
+		case <-writerDone:
+			cancel()
+		case <-router.stopped:
+			cancel()
+		case <-stop.Done():
+		}
+	}()
+
+	requestCtx := NewRequestContext(ctx, request, writer)
+	requestCtx.done = stop.Done()
+	found, params, err := router.getRoute(requestCtx.Request.RequestMeta)
+	requestCtx.Params = params
+	if err != nil {
+		_ = requestCtx.ResponseJSON(routeErrorStatus(err), err.Error())
 		return err
 	}
 
-	err = handler(requestCtx)
+	err = found.handler(requestCtx)
+	if err != nil && ctx.Err() != nil {
+		message := "request was cancelled"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			message = "request timed out"
+		}
+		_ = requestCtx.ResponseJSON(StatusServiceUnavailable, message)
+		return err
+	}
 	if err != nil {
 		_ = requestCtx.ResponseJSON(StatusInternalServerError, err.Error())
 		return err
@@ -61,18 +208,63 @@ func (router *Router) Handle(request *Request, conn net.Conn) error {
 	return nil
 }
 
-func (router *Router) getHandler(meta RequestMeta) (HandlerFunc, error) {
-	handler, ok := router.routes[meta]
-	if !ok {
-		return nil, ErrRouteNotFound
+// getRoute looks the route up, static paths win over patterns.
+func (router *Router) getRoute(meta RequestMeta) (route, map[string]string, error) {
+	found, ok := router.routes[meta]
+	if ok {
+		return found, nil, nil
+	}
+
+	pathFound := false
+	for rm := range router.routes {
+		if rm.Path == meta.Path {
+			pathFound = true
+			break
+		}
 	}
 
-	return handler, nil
+	if !pathFound {
+		for i := range router.patterns {
+			pattern := &router.patterns[i]
+			params, ok := pattern.match(meta.Path)
+			if !ok {
+				continue
+			}
+
+			if pattern.method == meta.Method {
+				return pattern.route, params, nil
+			}
+			pathFound = true
+		}
+	}
+
+	if pathFound {
+		return route{}, nil, ErrMethodNotAllowed
+	}
+
+	return route{}, nil, ErrRouteNotFound
+}
+
+func routeErrorStatus(err error) ResponseStatus {
+	switch {
+	case errors.Is(err, ErrMethodNotAllowed):
+		return StatusMethodNotAllowed
+	case errors.Is(err, ErrRouteNotFound):
+		return StatusNotFound
+	default:
+		return StatusInternalServerError
+	}
 }
 
-func (router *Router) ParseRawRequest(raw []byte) (*Request, error) {
+func (router *Router) ParseRawRequest(raw []byte, contentType streamer.ContentType) (*Request, error) {
 	request := &Request{}
-	err := json.Unmarshal(raw, request)
+
+	var err error
+	if contentType == streamer.ContentBinary {
+		err = request.UnmarshalBinary(raw)
+	} else {
+		err = json.Unmarshal(raw, request)
+	}
 
 	if err != nil {
 		return nil, err
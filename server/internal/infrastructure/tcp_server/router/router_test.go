@@ -0,0 +1,167 @@
+package tcpRouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/mock"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+)
+
+var logs = mock.NewLogger()
+
+func TestRouterPathParams(t *testing.T) {
+	router := New(logs)
+	router.AddRoute(GET, "/index/jobs", func(ctx *RequestContext) error {
+		return ctx.Response(StatusOK, "list")
+	})
+	router.AddRoute(GET, "/index/jobs/{id}", func(ctx *RequestContext) error {
+		return ctx.Response(StatusOK, "get "+ctx.Param("id"))
+	})
+	router.AddRoute(DELETE, "/index/jobs/{id}", func(ctx *RequestContext) error {
+		return ctx.Response(StatusNoContent, "cancel "+ctx.Param("id"))
+	})
+
+	tests := []struct {
+		method RequestMethod
+		path   RequestPath
+		status ResponseStatus
+		body   any
+	}{
+		{GET, "/index/jobs", StatusOK, "list"},
+		{GET, "/index/jobs/7", StatusOK, "get 7"},
+		{DELETE, "/index/jobs/7", StatusNoContent, "cancel 7"},
+		{POST, "/index/jobs/7", StatusMethodNotAllowed, ErrMethodNotAllowed.Error()},
+		{GET, "/index/jobs/", StatusNotFound, ErrRouteNotFound.Error()},
+		{GET, "/index/jobs/7/files", StatusNotFound, ErrRouteNotFound.Error()},
+	}
+
+	for _, test := range tests {
+		recorder := NewResponseRecorder(nil)
+		request := &Request{RequestMeta: RequestMeta{Path: test.path, Method: test.method}}
+		_ = router.Handle(context.Background(), request, recorder)
+
+		response := recorder.Response()
+		if response.Status != test.status || response.Body != test.body {
+			t.Errorf("%v %v: expected %v %v, got %v %v",
+				test.method, test.path, test.status, test.body, response.Status, response.Body)
+		}
+	}
+}
+
+func TestHandleCancelsRunningHandler(t *testing.T) {
+	router := New(logs)
+	router.AddRoute(GET, "/wait", func(ctx *RequestContext) error {
+		<-ctx.Context().Done()
+		return ctx.Context().Err()
+	})
+
+	request := &Request{RequestMeta: RequestMeta{Path: "/wait", Method: GET}}
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, context.CancelFunc)
+		err     error
+		message string
+	}{
+		{"cancelled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(10*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled, "request was cancelled"},
+		{"timed out", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 10*time.Millisecond)
+		}, context.DeadlineExceeded, "request timed out"},
+	}
+
+	for _, test := range tests {
+		ctx, cancel := test.ctx()
+		recorder := NewResponseRecorder(nil)
+		if err := router.Handle(ctx, request, recorder); !errors.Is(err, test.err) {
+			t.Errorf("%v: expected %v, got %v", test.name, test.err, err)
+		}
+
+		response := recorder.Response()
+		if response == nil || response.Status != StatusServiceUnavailable || response.Body != test.message {
+			t.Errorf("%v: expected the request to be answered unavailable, got %+v", test.name, response)
+		}
+		cancel()
+	}
+}
+
+func TestWriterStopOnlyClosesDone(t *testing.T) {
+	router := New(logs)
+	router.AddRoute(GET, "/subscribe", func(ctx *RequestContext) error {
+		<-ctx.Done()
+		if err := ctx.Context().Err(); err != nil {
+			return err
+		}
+		return ctx.Response(StatusOK, "stopped")
+	})
+
+	stop := make(chan struct{})
+	recorder := NewResponseRecorder(stop)
+	time.AfterFunc(10*time.Millisecond, func() { close(stop) })
+
+	request := &Request{RequestMeta: RequestMeta{Path: "/subscribe", Method: GET}}
+	if err := router.Handle(context.Background(), request, recorder); err != nil {
+		t.Fatalf("a stopped writer should not cancel the request context, got %v", err)
+	}
+	if response := recorder.Response(); response == nil || response.Status != StatusOK {
+		t.Errorf("expected the handler to answer once Done is closed, got %+v", response)
+	}
+}
+
+func TestRoutePriorities(t *testing.T) {
+	handler := func(ctx *RequestContext) error {
+		return ctx.Response(StatusOK, nil)
+	}
+
+	router := New(logs)
+	router.AddRouteWithPriority(GET, "/health", threadpool.PriorityHigh, handler)
+	router.AddRoute(GET, "/index/search", handler)
+	router.AddRouteWithPriority(GET, "/index/jobs/{id}", threadpool.PriorityLow, handler)
+
+	tests := []struct {
+		meta     RequestMeta
+		priority threadpool.Priority
+	}{
+		{RequestMeta{Path: "/health", Method: GET}, threadpool.PriorityHigh},
+		{RequestMeta{Path: "/index/search", Method: GET}, threadpool.PriorityNormal},
+		{RequestMeta{Path: "/index/jobs/7", Method: GET}, threadpool.PriorityLow},
+		{RequestMeta{Path: "/missing", Method: GET}, threadpool.PriorityNormal},
+	}
+
+	for _, test := range tests {
+		if priority := router.Priority(test.meta); priority != test.priority {
+			t.Errorf("%v %v: expected priority %v, got %v", test.meta.Method, test.meta.Path, test.priority, priority)
+		}
+	}
+}
+
+func TestRouteNames(t *testing.T) {
+	handler := func(ctx *RequestContext) error {
+		return ctx.Response(StatusOK, nil)
+	}
+
+	router := New(logs)
+	router.AddRoute(GET, "/index/search", handler)
+	router.AddRoute(DELETE, "/index/jobs/{id}", handler)
+
+	tests := []struct {
+		meta RequestMeta
+		name string
+	}{
+		{RequestMeta{Path: "/index/search", Method: GET}, "GET /index/search"},
+		{RequestMeta{Path: "/index/jobs/7", Method: DELETE}, "DELETE /index/jobs/{id}"},
+		{RequestMeta{Path: "/index/jobs/7", Method: GET}, ""},
+		{RequestMeta{Path: "/missing", Method: GET}, ""},
+	}
+
+	for _, test := range tests {
+		if name := router.RouteName(test.meta); name != test.name {
+			t.Errorf("%v %v: expected name %q, got %q", test.meta.Method, test.meta.Path, test.name, name)
+		}
+	}
+}
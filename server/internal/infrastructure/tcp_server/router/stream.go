@@ -0,0 +1,51 @@
+package tcpRouter
+
+import (
+	"errors"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+)
+
+var ErrStreamClosed = errors.New("response stream is closed")
+
+// ResponseStream writes one response as a sequence of stream frames, so the
+// handler never has to hold the whole result in memory. Every Send is a
+// separate part with the same id and status, Close sends the end marker.
+type ResponseStream struct {
+	requestCtx *RequestContext
+	status     ResponseStatus
+	closed     bool
+}
+
+func (stream *ResponseStream) Send(data any) error {
+	if stream.closed {
+		return ErrStreamClosed
+	}
+
+	return stream.requestCtx.Writer.WriteResponseFrame(
+		streamer.FrameStreamChunk,
+		stream.requestCtx.Request.ContentType,
+		stream.response(data),
+	)
+}
+
+func (stream *ResponseStream) Close() error {
+	if stream.closed {
+		return ErrStreamClosed
+	}
+
+	stream.closed = true
+	return stream.requestCtx.Writer.WriteResponseFrame(
+		streamer.FrameStreamEnd,
+		stream.requestCtx.Request.ContentType,
+		stream.response(nil),
+	)
+}
+
+func (stream *ResponseStream) response(data any) *Response {
+	return &Response{
+		Id:     stream.requestCtx.Request.Id,
+		Status: stream.status,
+		Body:   data,
+	}
+}
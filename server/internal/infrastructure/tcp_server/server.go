@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -20,217 +21,787 @@ import (
 )
 
 type ThreadPool interface {
+	IsWorking() bool
 	MustRun(threadCount int)
 	MustTerminate()
+	Drain(ctx context.Context) error
 	AddTask(task *threadpool.Task) error
 }
 
 type Router interface {
-	Handle(req *tcpRouter.Request, conn net.Conn) error
-	ParseRawRequest(raw []byte) (*tcpRouter.Request, error)
+	Handle(ctx context.Context, req *tcpRouter.Request, writer tcpRouter.ResponseWriter) error
+	Priority(meta tcpRouter.RequestMeta) threadpool.Priority
+	RouteName(meta tcpRouter.RequestMeta) string
+	ParseRawRequest(raw []byte, contentType streamer.ContentType) (*tcpRouter.Request, error)
+	// Stop ends long lived requests such as subscriptions, see tcpRouter.Router.Stop.
+	Stop()
 }
 
-const AliveTimeout = 15 * time.Second
+var (
+	ErrServerClosed = errors.New("server closed")
+	ErrNoListeners  = errors.New("neither TCP nor a unix socket is enabled")
+)
+
+const (
+	AliveTimeout          = 15 * time.Second
+	ReadTimeout           = 10 * time.Second
+	DrainTimeout          = 5 * time.Second
+	RequestTimeout        = 30 * time.Second
+	MaxConnections        = 1024
+	UnixSocketMode        = 0660
+	rejectWriteTimeout    = time.Second
+	acceptErrorBackoffMax = time.Second
+)
+
+type Config struct {
+	Port int
+	// DisableTCP leaves only the unix socket listening.
+	DisableTCP bool
+	// UnixSocket is the path of a unix domain socket to listen on as well,
+	// empty for none. A stale socket left at the path is replaced.
+	UnixSocket string
+	// UnixSocketMode are the permissions of the socket file.
+	UnixSocketMode os.FileMode
+	// ReadTimeout bounds reading the first request of a connection.
+	ReadTimeout time.Duration
+	// AliveTimeout bounds the idle time between requests of a keep-alive connection.
+	AliveTimeout time.Duration
+	// MaxConnections is the number of connections served at once,
+	// the excess ones are answered with StatusServiceUnavailable.
+	MaxConnections int
+	// DrainTimeout bounds how long Shutdown waits for queued and running
+	// requests, the ones still queued after it are answered with StatusServiceUnavailable.
+	DrainTimeout time.Duration
+	// RequestTimeout bounds how long a request runs before its context is
+	// cancelled. Streamed requests, such as subscriptions, run until their
+	// client leaves.
+	RequestTimeout time.Duration
+	// MaxMessageSize is the largest request frame accepted, in bytes.
+	MaxMessageSize int
+	// CompressionThreshold is the smallest response payload compressed
+	// for clients that negotiated a codec, in bytes.
+	CompressionThreshold int
+}
+
+func (config Config) withDefaults() Config {
+	if config.ReadTimeout <= 0 {
+		config.ReadTimeout = ReadTimeout
+	}
+
+	if config.AliveTimeout <= 0 {
+		config.AliveTimeout = AliveTimeout
+	}
+
+	if config.MaxConnections <= 0 {
+		config.MaxConnections = MaxConnections
+	}
+
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = DrainTimeout
+	}
+
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = RequestTimeout
+	}
+
+	if config.MaxMessageSize <= 0 {
+		config.MaxMessageSize = streamer.DefaultMaxMessageSize
+	}
+
+	if config.CompressionThreshold <= 0 {
+		config.CompressionThreshold = streamer.DefaultCompressionThreshold
+	}
+
+	if config.UnixSocketMode == 0 {
+		config.UnixSocketMode = UnixSocketMode
+	}
+
+	return config
+}
 
 type Logger interface {
 	Log(...interface{})
 }
 
+var errReadTimeout = errors.New("read timed out")
+
+// requestTracker counts the requests of one connection that are not
+// answered yet, so the connection is closed only after the last of them.
+type requestTracker struct {
+	wg     sync.WaitGroup
+	active atomic.Int64
+}
+
+func (tracker *requestTracker) Add() {
+	tracker.active.Add(1)
+	tracker.wg.Add(1)
+}
+
+func (tracker *requestTracker) Done() {
+	tracker.active.Add(-1)
+	tracker.wg.Done()
+}
+
+func (tracker *requestTracker) Wait() {
+	tracker.wg.Wait()
+}
+
+func (tracker *requestTracker) Active() bool {
+	return tracker.active.Load() > 0
+}
+
+// scheduledRequest is a request waiting in the thread pool. Whoever claims
+// it first, the worker or the shutdown drain, is the one that answers it.
+type scheduledRequest struct {
+	request  *tcpRouter.Request
+	writer   tcpRouter.ResponseWriter
+	inFlight *requestTracker
+	claimed  atomic.Bool
+}
+
+func (scheduled *scheduledRequest) claim() bool {
+	return scheduled.claimed.CompareAndSwap(false, true)
+}
+
 type Server struct {
-	port           int
-	conn           net.Listener
+	config         Config
+	listeners      []net.Listener
 	threadPool     ThreadPool
 	router         Router
+	connSlots      chan struct{}
+	connWg         sync.WaitGroup
+	conns          map[*tcpRouter.Conn]struct{}
+	scheduled      map[int64]*scheduledRequest
+	lock           sync.Mutex
+	isServing      bool
+	isShuttingDown bool
 	shutdownSignal chan struct{}
+	shutdownOnce   sync.Once
+	shutdownHooks  []func()
+	drainHooks     []func()
+	acceptDone     chan struct{}
+	stopped        chan struct{}
 	taskIds        atomic.Int64
+	requestIds     atomic.Int64
+	connIds        atomic.Int64
 	logger         Logger
 }
 
-func New(port int, threadPool ThreadPool, router Router, logger Logger) *Server {
+func New(config Config, threadPool ThreadPool, router Router, logger Logger) *Server {
+	config = config.withDefaults()
 	return &Server{
-		port:           port,
+		config:         config,
 		threadPool:     threadPool,
 		router:         router,
+		connSlots:      make(chan struct{}, config.MaxConnections),
+		conns:          make(map[*tcpRouter.Conn]struct{}),
+		scheduled:      make(map[int64]*scheduledRequest),
 		shutdownSignal: make(chan struct{}),
+		acceptDone:     make(chan struct{}),
+		stopped:        make(chan struct{}),
 		taskIds:        atomic.Int64{},
+		requestIds:     atomic.Int64{},
+		connIds:        atomic.Int64{},
 		logger:         logger,
 	}
 }
 
 func (server *Server) getAddr() string {
-	return fmt.Sprintf("0.0.0.0:%d", server.port)
+	return fmt.Sprintf("0.0.0.0:%d", server.config.Port)
 }
 
+// Start listens, serves connections and blocks until the process
+// receives SIGINT or SIGTERM and the server is shut down.
 func (server *Server) Start(threadsCount int) error {
-	conn, err := net.Listen("tcp", server.getAddr())
-	if err != nil {
+	if err := server.Listen(); err != nil {
 		return err
 	}
-	defer conn.Close()
 
-	server.conn = conn
+	go server.shutdownOnSignal()
 
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	go server.gracefulShutDown(&wg)
+	if err := server.Serve(threadsCount); err != nil {
+		return err
+	}
+
+	<-server.stopped
+	return nil
+}
+
+// Listen opens the TCP listener and the unix socket, whichever are enabled.
+func (server *Server) Listen() error {
+	if server.config.DisableTCP && server.config.UnixSocket == "" {
+		return ErrNoListeners
+	}
+
+	if !server.config.DisableTCP {
+		listener, err := net.Listen("tcp", server.getAddr())
+		if err != nil {
+			return err
+		}
+		server.listeners = append(server.listeners, listener)
+	}
+
+	if server.config.UnixSocket != "" {
+		listener, err := listenUnix(server.config.UnixSocket, server.config.UnixSocketMode)
+		if err != nil {
+			server.closeListeners()
+			return err
+		}
+		server.listeners = append(server.listeners, listener)
+	}
+
+	return nil
+}
+
+// Addr is the address of the first listener, the TCP one when it is enabled.
+func (server *Server) Addr() net.Addr {
+	return server.listeners[0].Addr()
+}
+
+func (server *Server) Addrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(server.listeners))
+	for _, listener := range server.listeners {
+		addrs = append(addrs, listener.Addr())
+	}
+
+	return addrs
+}
+
+func (server *Server) closeListeners() {
+	for _, listener := range server.listeners {
+		if err := listener.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+}
 
-	server.threadPool.MustRun(threadsCount)
-	server.logger.Log("Server started on port:", server.port)
+// RegisterOnShutdown registers a function called once the server has
+// answered every request and closed every connection, e.g. to flush logs.
+func (server *Server) RegisterOnShutdown(hook func()) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.shutdownHooks = append(server.shutdownHooks, hook)
+}
+
+// RegisterOnDrain registers a function called once the server stops
+// accepting requests, before it drains the thread pool, e.g. to stop
+// another transport that schedules requests through Handle.
+func (server *Server) RegisterOnDrain(hook func()) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.drainHooks = append(server.drainHooks, hook)
+}
+
+// Serve runs the thread pool, unless it is running already, and accepts
+// connections until Shutdown is called.
+func (server *Server) Serve(threadsCount int) error {
+	if len(server.listeners) == 0 {
+		return errors.New("server is not listening")
+	}
+
+	server.lock.Lock()
+	if server.isShuttingDown {
+		server.lock.Unlock()
+		return ErrServerClosed
+	}
+	if !server.threadPool.IsWorking() {
+		server.threadPool.MustRun(threadsCount)
+	}
+	server.isServing = true
+	server.lock.Unlock()
+
+	server.logger.Log("Server started on:", server.Addrs())
+
+	defer close(server.acceptDone)
+	wg := sync.WaitGroup{}
+	for _, listener := range server.listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server.acceptConnections(listener)
+		}()
+	}
 
-	server.acceptConnections()
 	wg.Wait()
 	return nil
 }
 
-func (server *Server) acceptConnections() {
-	idx := atomic.Int64{}
-	idx.Store(0)
+// acceptConnections only accepts: every connection is read in its own
+// goroutine, so a slow or silent client never delays accepting the others.
+// Connections from every listener share the MaxConnections slots.
+func (server *Server) acceptConnections(listener net.Listener) {
+	backoff := 5 * time.Millisecond
 
 	for {
-		select {
-		case <-server.shutdownSignal:
-			server.logger.Log("Shutting down acceptConnections...")
-			return
-		default:
-			conn, err := server.conn.Accept()
-			if err != nil {
-				continue
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-server.shutdownSignal:
+				server.logger.Log("Shutting down acceptConnections...")
+				return
+			default:
 			}
 
-			connIdx := idx.Add(1)
+			msg := fmt.Sprintf("failed to accept connection: %v", err)
+			server.logger.Log(msg)
+			time.Sleep(backoff)
+			backoff = min(2*backoff, acceptErrorBackoffMax)
+			continue
+		}
+		backoff = 5 * time.Millisecond
+
+		connIdx := server.connIds.Add(1)
+		select {
+		case server.connSlots <- struct{}{}:
 			msg := fmt.Sprintf("client connection [%v] opened", connIdx)
 			server.logger.Log(msg)
-			if err := server.handleConnections(conn, connIdx); err != nil {
-				msg := fmt.Sprintf("error happened %v", err)
-				server.logger.Log(msg)
-			}
+			server.connWg.Add(1)
+			go server.serveConnection(conn, connIdx)
+		default:
+			server.rejectConnection(conn, connIdx)
 		}
 	}
 }
 
-func (server *Server) scheduleTask(request *tcpRouter.Request, clientConn net.Conn, connIdx int64) (err error) {
-	task := threadpool.NewTask(server.taskIds.Add(1), func() error {
-		defer func() {
-			if !request.ConnectionAlive {
-				if err = clientConn.Close(); err != nil {
-					msg := fmt.Sprintf("error occurred: %v", err)
-					server.logger.Log(msg)
-				}
+func (server *Server) rejectConnection(netConn net.Conn, connIdx int64) {
+	defer netConn.Close()
 
-				msg := fmt.Sprintf("client [%v] disconnected", connIdx)
-				server.logger.Log(msg)
-			}
-		}()
+	msg := fmt.Sprintf("client connection [%v] rejected: too many connections", connIdx)
+	server.logger.Log(msg)
 
-		return server.router.Handle(request, clientConn)
+	_ = netConn.SetWriteDeadline(time.Now().Add(rejectWriteTimeout))
+	_ = tcpRouter.NewConn(netConn).WriteResponse(&tcpRouter.Response{
+		Status: tcpRouter.StatusServiceUnavailable,
+		Body:   "too many connections",
 	})
+}
 
-	msg := fmt.Sprintf("Request: method: %v - path: %v", request.RequestMeta.Method, request.RequestMeta.Path)
-	server.logger.Log(msg)
+// serveConnection reads requests until the client stops keeping the
+// connection alive, then closes it once every scheduled request is answered.
+// A keep-alive connection with requests still running, such as an event
+// subscription, outlives AliveTimeout and is only closed when the client
+// leaves, which also cancels those requests.
+func (server *Server) serveConnection(netConn net.Conn, connIdx int64) {
+	clientConn := tcpRouter.NewConn(netConn)
+	inFlight := &requestTracker{}
+
+	defer func() {
+		inFlight.Wait()
+		server.untrackConn(clientConn)
+		if err := clientConn.Close(); err != nil {
+			msg := fmt.Sprintf("error occurred: %v", err)
+			server.logger.Log(msg)
+		}
 
-	err = server.threadPool.AddTask(task)
-	if err != nil {
-		return err
+		<-server.connSlots
+		server.connWg.Done()
+		msg := fmt.Sprintf("client [%v] disconnected", connIdx)
+		server.logger.Log(msg)
+	}()
+
+	if !server.trackConn(clientConn) {
+		_ = clientConn.WriteResponse(&tcpRouter.Response{
+			Status: tcpRouter.StatusServiceUnavailable,
+			Body:   "server is shutting down",
+		})
+		return
 	}
 
-	return nil
+	timeout := server.config.ReadTimeout
+	for first := true; ; first = false {
+		request, err := server.readRequest(clientConn, timeout)
+		if errors.Is(err, errReadTimeout) && inFlight.Active() && !server.shuttingDown() {
+			continue
+		}
+
+		if err != nil {
+			// Reads interrupted by a shutdown leave the client connected,
+			// its queued and running requests are still answered.
+			if !server.shuttingDown() {
+				clientConn.Disconnect()
+			}
+
+			switch {
+			case errors.Is(err, errReadTimeout):
+				msg := fmt.Sprintf("client [%v] timed out", connIdx)
+				server.logger.Log(msg)
+			case !errors.Is(err, io.EOF):
+				msg := fmt.Sprintf("client [%v] error happened %v", connIdx, err)
+				server.logger.Log(msg)
+			}
+			return
+		}
+
+		if first && len(request.AcceptEncoding) > 0 {
+			codec := clientConn.Negotiate(request.AcceptEncoding, server.config.CompressionThreshold)
+			msg := fmt.Sprintf("client [%v] negotiated %v encoding", connIdx, codec)
+			server.logger.Log(msg)
+		}
+
+		inFlight.Add()
+		if err = server.scheduleTask(clientConn.Context(), request, clientConn, inFlight); err != nil {
+			inFlight.Done()
+			msg := fmt.Sprintf("request [%v] was not scheduled: %v", request.Id, err)
+			server.logger.Log(msg)
+
+			// A full queue only turns this request away, the client may retry
+			// on the same connection.
+			if errors.Is(err, threadpool.ErrQueueFull) {
+				_ = clientConn.WriteResponse(&tcpRouter.Response{
+					Id:     request.Id,
+					Status: tcpRouter.StatusTooManyRequests,
+					Body:   "server is busy, retry later",
+				})
+				if !request.ConnectionAlive {
+					return
+				}
+				timeout = server.config.AliveTimeout
+				continue
+			}
+
+			_ = clientConn.WriteResponse(&tcpRouter.Response{
+				Id:     request.Id,
+				Status: tcpRouter.StatusServiceUnavailable,
+				Body:   "server is not accepting requests",
+			})
+			return
+		}
+
+		if !request.ConnectionAlive {
+			return
+		}
+		timeout = server.config.AliveTimeout
+	}
 }
 
-func (server *Server) handleConnections(clientConn net.Conn, connIdx int64) error {
-	rawRequest, err := streamer.ReadBuff(clientConn)
+func (server *Server) readRequest(clientConn *tcpRouter.Conn, timeout time.Duration) (*tcpRouter.Request, error) {
+	if err := clientConn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	// Shutdown interrupts reads by moving the deadline to now, the check
+	// after setting our own deadline makes sure that interruption is not lost.
+	if server.shuttingDown() {
+		return nil, io.EOF
+	}
+
+	frame, err := streamer.ReadFrame(clientConn, server.config.MaxMessageSize)
+	if err == nil && frame.Kind != streamer.FrameMessage {
+		err = streamer.ErrUnexpectedFrame
+	}
 	if err != nil {
-		return err
+		if server.shuttingDown() {
+			return nil, io.EOF
+		}
+
+		if status, ok := frameErrorStatus(err); ok {
+			_ = clientConn.WriteResponse(&tcpRouter.Response{
+				Status: status,
+				Body:   err.Error(),
+			})
+			return nil, err
+		}
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, errReadTimeout
+		}
+
+		return nil, err
 	}
 
-	request, err := server.router.ParseRawRequest(rawRequest)
+	request, err := server.parseRequest(frame)
 	if err != nil {
-		return err
+		_ = clientConn.WriteResponse(&tcpRouter.Response{
+			Status: tcpRouter.StatusBadRequest,
+			Body:   "could not parse request",
+		})
+		return nil, err
 	}
 
-	err = server.scheduleTask(request, clientConn, connIdx)
+	return request, nil
+}
+
+// frameErrorStatus reports how to answer a frame the server refuses to read.
+// The rest of the stream cannot be trusted after it, so the connection is closed.
+func frameErrorStatus(err error) (tcpRouter.ResponseStatus, bool) {
+	switch {
+	case errors.Is(err, streamer.ErrMessageTooLarge):
+		return tcpRouter.StatusPayloadTooLarge, true
+	case errors.Is(err, streamer.ErrInvalidMagic),
+		errors.Is(err, streamer.ErrUnsupportedVersion),
+		errors.Is(err, streamer.ErrInvalidHeader),
+		errors.Is(err, streamer.ErrCorruptPayload),
+		errors.Is(err, streamer.ErrUnexpectedFrame):
+		return tcpRouter.StatusBadRequest, true
+	default:
+		return 0, false
+	}
+}
+
+// parseRequest decodes a frame and assigns a server-side id to requests
+// that came without one, so every response can be matched to its request.
+func (server *Server) parseRequest(frame streamer.Frame) (*tcpRouter.Request, error) {
+	request, err := server.router.ParseRawRequest(frame.Payload, frame.ContentType)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if request.ConnectionAlive {
-		go func() {
-			err = server.handleConnectionAlive(clientConn, connIdx, AliveTimeout)
-			if err != nil {
-				msg := fmt.Sprintf("error occurred: %v", err)
-				server.logger.Log(msg)
-			}
-		}()
+	if request.Id == "" {
+		request.Id = strconv.FormatInt(server.requestIds.Add(1), 10)
 	}
 
-	return nil
+	return request, nil
 }
 
-func (server *Server) handleSingleRequestAlive(clientConn net.Conn, connIdx int64, timeout time.Duration) error {
-	if err := clientConn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
-		msg := fmt.Sprintf("failed to set read deadline: %v", err)
-		server.logger.Log(msg)
+// Handle schedules a request of another transport, such as the HTTP
+// gateway, on the thread pool like the requests read from connections,
+// so it shares their priorities, queue limit and stats, and waits until
+// it is answered. ctx is cancelled once the client is gone.
+func (server *Server) Handle(ctx context.Context, request *tcpRouter.Request, writer tcpRouter.ResponseWriter) error {
+	if server.shuttingDown() {
+		_ = writeResponse(writer, &tcpRouter.Response{
+			Id:     request.Id,
+			Status: tcpRouter.StatusServiceUnavailable,
+			Body:   "server is shutting down",
+		})
+		return ErrServerClosed
+	}
+
+	inFlight := &requestTracker{}
+	inFlight.Add()
+	if err := server.scheduleTask(ctx, request, writer, inFlight); err != nil {
+		inFlight.Done()
+		response := &tcpRouter.Response{
+			Id:     request.Id,
+			Status: tcpRouter.StatusServiceUnavailable,
+			Body:   "server is not accepting requests",
+		}
+		if errors.Is(err, threadpool.ErrQueueFull) {
+			response.Status = tcpRouter.StatusTooManyRequests
+			response.Body = "server is busy, retry later"
+		}
+		_ = writeResponse(writer, response)
 		return err
 	}
 
-	rawRequest, err := streamer.ReadBuff(clientConn)
-	if err != nil {
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			msg := fmt.Sprintf("client [%v] timed out", connIdx)
-			server.logger.Log(msg)
+	inFlight.Wait()
+	return nil
+}
 
-			_ = clientConn.SetReadDeadline(time.Time{})
-			return io.EOF
+// scheduleTask queues the request on the thread pool, the request is
+// dropped once ctx is done before a worker picks it up.
+func (server *Server) scheduleTask(
+	ctx context.Context,
+	request *tcpRouter.Request,
+	writer tcpRouter.ResponseWriter,
+	inFlight *requestTracker,
+) error {
+	taskId := server.taskIds.Add(1)
+	scheduled := &scheduledRequest{
+		request:  request,
+		writer:   writer,
+		inFlight: inFlight,
+	}
+
+	task := threadpool.NewTask(taskId, func(ctx context.Context) error {
+		if !scheduled.claim() {
+			return nil
+		}
+		defer inFlight.Done()
+		server.forgetScheduled(taskId)
+
+		// The client of a panicking handler still gets an answer, the error
+		// goes on to the pool, which logs and counts it.
+		err := threadpool.CatchPanic(func() error {
+			return server.router.Handle(ctx, request, writer)
+		})
+		if errors.Is(err, threadpool.ErrTaskPanicked) {
+			_ = writeResponse(writer, &tcpRouter.Response{
+				Id:     request.Id,
+				Status: tcpRouter.StatusInternalServerError,
+				Body:   "internal server error",
+			})
 		}
 
 		return err
+	})
+
+	// A client that leaves takes its queued requests with it, nobody
+	// would read their responses. Requests dropped to make room in a full
+	// queue are answered, their client is still there.
+	task.SetContext(ctx)
+	if !request.Stream {
+		task.SetTimeout(server.config.RequestTimeout)
 	}
+	_ = task.SetPriority(server.router.Priority(request.RequestMeta))
+	task.SetType(server.router.RouteName(request.RequestMeta))
+	task.SetOnSkip(func(err error) {
+		if !scheduled.claim() {
+			return
+		}
+		server.forgetScheduled(taskId)
+		defer inFlight.Done()
 
-	request, err := server.router.ParseRawRequest(rawRequest)
-	if err != nil {
+		msg := fmt.Sprintf("request [%v] was dropped: %v", request.Id, err)
+		server.logger.Log(msg)
+
+		if errors.Is(err, threadpool.ErrQueueFull) {
+			_ = writeResponse(writer, &tcpRouter.Response{
+				Id:     request.Id,
+				Status: tcpRouter.StatusTooManyRequests,
+				Body:   "server is busy, retry later",
+			})
+		}
+	})
+
+	msg := fmt.Sprintf("Request [%v]: method: %v - path: %v", request.Id, request.RequestMeta.Method, request.RequestMeta.Path)
+	server.logger.Log(msg)
+
+	server.lock.Lock()
+	server.scheduled[taskId] = scheduled
+	server.lock.Unlock()
+
+	// AddTask may wait for room in a full queue, a shutdown can answer
+	// the request meanwhile.
+	if err := server.threadPool.AddTask(task); err != nil && scheduled.claim() {
+		server.forgetScheduled(taskId)
 		return err
 	}
 
-	return server.scheduleTask(request, clientConn, connIdx)
+	return nil
 }
 
-func (server *Server) handleConnectionAlive(
-	clientConn net.Conn,
-	connIdx int64,
-	timeout time.Duration,
-) error {
-	defer clientConn.Close()
+// writeResponse writes response as a single JSON message to any writer,
+// like Conn.WriteResponse does.
+func writeResponse(writer tcpRouter.ResponseWriter, response *tcpRouter.Response) error {
+	return writer.WriteResponseFrame(streamer.FrameMessage, streamer.ContentJSON, response)
+}
 
-	for {
-		err := server.handleSingleRequestAlive(clientConn, connIdx, timeout)
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				msg := fmt.Sprintf("client [%v] disconnected", connIdx)
-				server.logger.Log(msg)
-				break
-			}
+func (server *Server) forgetScheduled(taskId int64) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	delete(server.scheduled, taskId)
+}
 
-			return err
+// rejectScheduled answers every request still waiting in the thread pool
+// with StatusServiceUnavailable, workers skip the ones claimed here.
+func (server *Server) rejectScheduled() {
+	server.lock.Lock()
+	scheduled := server.scheduled
+	server.scheduled = make(map[int64]*scheduledRequest)
+	server.lock.Unlock()
+
+	for _, pending := range scheduled {
+		if !pending.claim() {
+			continue
 		}
+
+		_ = writeResponse(pending.writer, &tcpRouter.Response{
+			Id:     pending.request.Id,
+			Status: tcpRouter.StatusServiceUnavailable,
+			Body:   "server is shutting down",
+		})
+		pending.inFlight.Done()
 	}
 
-	return nil
+	msg := fmt.Sprintf("rejected %v queued requests on shutdown", len(scheduled))
+	server.logger.Log(msg)
+}
+
+func (server *Server) trackConn(conn *tcpRouter.Conn) bool {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	if server.isShuttingDown {
+		return false
+	}
+
+	server.conns[conn] = struct{}{}
+	return true
+}
+
+func (server *Server) untrackConn(conn *tcpRouter.Conn) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	delete(server.conns, conn)
 }
 
-func (server *Server) gracefulShutDown(wg *sync.WaitGroup) {
+func (server *Server) shuttingDown() bool {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return server.isShuttingDown
+}
+
+// interruptReads wakes up every connection blocked on reading the next
+// request, so keep-alive connections stop waiting for new requests. Their
+// queued and running requests are left to the drain.
+func (server *Server) interruptReads() {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	for conn := range server.conns {
+		_ = conn.SetReadDeadline(time.Now())
+	}
+}
+
+func (server *Server) shutdownOnSignal() {
 	sigint := make(chan os.Signal, 1)
 	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 	<-sigint
 
-	close(server.shutdownSignal)
-	_, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	server.Shutdown()
+}
+
+// Shutdown stops accepting connections and requests, lets queued and
+// running requests finish within DrainTimeout, answers the rest with
+// StatusServiceUnavailable and closes every connection once it is answered.
+// It is safe to call more than once.
+func (server *Server) Shutdown() {
+	server.shutdownOnce.Do(func() {
+		server.lock.Lock()
+		server.isShuttingDown = true
+		wasServing := server.isServing
+		server.lock.Unlock()
+
+		close(server.shutdownSignal)
+		server.closeListeners()
+
+		server.lock.Lock()
+		drainHooks := server.drainHooks
+		hooks := server.shutdownHooks
+		server.lock.Unlock()
+
+		if wasServing {
+			<-server.acceptDone
+			server.interruptReads()
+			// Subscriptions never finish on their own, they would hold the
+			// drain up until DrainTimeout.
+			server.router.Stop()
+			for _, hook := range drainHooks {
+				hook()
+			}
+			server.drain()
+			server.connWg.Wait()
+		}
+
+		server.logger.Log("server stopped")
+		for _, hook := range hooks {
+			hook()
+		}
+		close(server.stopped)
+	})
+}
+
+func (server *Server) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.config.DrainTimeout)
 	defer cancel()
 
-	if err := server.conn.Close(); err != nil {
-		log.Println(err)
+	if err := server.threadPool.Drain(ctx); err != nil {
+		msg := fmt.Sprintf("thread pool was not drained in %v: %v", server.config.DrainTimeout, err)
+		server.logger.Log(msg)
+		server.rejectScheduled()
 	}
 
 	server.threadPool.MustTerminate()
-
-	server.logger.Log("server stopped")
-	wg.Done()
 }
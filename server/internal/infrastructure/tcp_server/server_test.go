@@ -0,0 +1,748 @@
+package tcpServer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/mock"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+	"io"
+	"net"
+	"path/filepath"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var logs = mock.NewLogger()
+
+// waitCancelled receives the id of every /wait request cancelled by its client.
+var waitCancelled = make(chan string, 8)
+
+// countedRuns counts the /counted requests that were handled.
+var countedRuns atomic.Int64
+
+func startTestServer(t *testing.T, config Config) *Server {
+	t.Helper()
+	return startTestServerWithPool(t, config, threadpool.New(logs))
+}
+
+func startTestServerWithPool(t *testing.T, config Config, pool *threadpool.ThreadPool) *Server {
+	t.Helper()
+
+	router := tcpRouter.New(logs)
+	router.AddRoute(tcpRouter.GET, "/health", func(ctx *tcpRouter.RequestContext) error {
+		return ctx.Response(tcpRouter.StatusOK, nil)
+	})
+	router.AddRoute(tcpRouter.GET, "/slow", func(ctx *tcpRouter.RequestContext) error {
+		time.Sleep(200 * time.Millisecond)
+		return ctx.ResponseJSON(tcpRouter.StatusOK, nil)
+	})
+	// /careful is /slow for a handler that stops once its context is done.
+	router.AddRoute(tcpRouter.GET, "/careful", func(ctx *tcpRouter.RequestContext) error {
+		select {
+		case <-time.After(200 * time.Millisecond):
+			return ctx.ResponseJSON(tcpRouter.StatusOK, nil)
+		case <-ctx.Context().Done():
+			return ctx.Context().Err()
+		}
+	})
+	router.AddRoute(tcpRouter.GET, "/large", func(ctx *tcpRouter.RequestContext) error {
+		return ctx.ResponseJSON(tcpRouter.StatusOK, strings.Repeat("compressible ", 1000))
+	})
+	router.AddRoute(tcpRouter.GET, "/count", func(ctx *tcpRouter.RequestContext) error {
+		stream := ctx.Stream(tcpRouter.StatusOK)
+		for i := range 3 {
+			if err := stream.Send(i); err != nil {
+				return err
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return stream.Close()
+	})
+
+	router.AddRoute(tcpRouter.GET, "/wait", func(ctx *tcpRouter.RequestContext) error {
+		stream := ctx.Stream(tcpRouter.StatusOK)
+		if err := stream.Send("started"); err != nil {
+			return err
+		}
+
+		<-ctx.Done()
+		waitCancelled <- ctx.Request.Id
+		return nil
+	})
+
+	router.AddRoute(tcpRouter.GET, "/panic", func(ctx *tcpRouter.RequestContext) error {
+		panic("handler bug")
+	})
+
+	router.AddRoute(tcpRouter.GET, "/counted", func(ctx *tcpRouter.RequestContext) error {
+		countedRuns.Add(1)
+		return ctx.Response(tcpRouter.StatusOK, nil)
+	})
+
+	server := New(config, pool, router, logs)
+	if err := server.Listen(); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = server.Serve(2)
+	}()
+	t.Cleanup(server.Shutdown)
+
+	return server
+}
+
+func dialTestServer(t *testing.T, server *Server) net.Conn {
+	t.Helper()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", server.Addr().(*net.TCPAddr).Port)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func fetch(t *testing.T, conn net.Conn, request tcpRouter.Request) tcpRouter.Response {
+	t.Helper()
+	send(t, conn, request)
+	return readResponse(t, conn)
+}
+
+func send(t *testing.T, conn net.Conn, request tcpRouter.Request) {
+	t.Helper()
+
+	requestBin, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = streamer.WriteBuff(conn, 2048, requestBin); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readResponse(t *testing.T, conn net.Conn) tcpRouter.Response {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	raw, err := streamer.ReadBuff(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response tcpRouter.Response
+	if err = json.Unmarshal(raw, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+var healthRequest = tcpRouter.Request{
+	RequestMeta: tcpRouter.RequestMeta{Path: "/health", Method: tcpRouter.GET},
+}
+
+func TestStuckClientDoesNotBlockOthers(t *testing.T) {
+	server := startTestServer(t, Config{ReadTimeout: 500 * time.Millisecond})
+
+	stuck := dialTestServer(t, server)
+	if _, err := stuck.Write([]byte{0, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for range 5 {
+		response := fetch(t, dialTestServer(t, server), healthRequest)
+		if response.Status != tcpRouter.StatusOK {
+			t.Errorf("expected status OK, got %v", response.Status)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("healthy clients waited for the stuck one: %v", elapsed)
+	}
+
+	_ = stuck.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := stuck.Read(make([]byte, 1)); err == nil {
+		t.Error("stuck connection should be closed after the read timeout")
+	}
+}
+
+func TestKeepAliveConnectionServesManyRequests(t *testing.T) {
+	server := startTestServer(t, Config{})
+	conn := dialTestServer(t, server)
+
+	for i := range 3 {
+		request := healthRequest
+		request.Id = fmt.Sprintf("req-%v", i)
+		request.ConnectionAlive = true
+
+		response := fetch(t, conn, request)
+		if response.Id != request.Id {
+			t.Errorf("expected response id %v, got %v", request.Id, response.Id)
+		}
+	}
+}
+
+func TestExcessConnectionsAreRejected(t *testing.T) {
+	server := startTestServer(t, Config{MaxConnections: 1, ReadTimeout: 2 * time.Second})
+
+	_ = dialTestServer(t, server)
+	time.Sleep(50 * time.Millisecond)
+
+	response := readResponse(t, dialTestServer(t, server))
+	if response.Status != tcpRouter.StatusServiceUnavailable {
+		t.Errorf("expected status Service Unavailable, got %v", response.Status)
+	}
+}
+
+func sendSlowRequests(t *testing.T, conn net.Conn, count int) {
+	t.Helper()
+
+	for i := range count {
+		send(t, conn, tcpRouter.Request{
+			Id:              fmt.Sprintf("slow-%v", i),
+			RequestMeta:     tcpRouter.RequestMeta{Path: "/slow", Method: tcpRouter.GET},
+			ConnectionAlive: true,
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+}
+
+func readAllResponses(t *testing.T, conn net.Conn) map[tcpRouter.ResponseStatus]int {
+	t.Helper()
+
+	statuses := make(map[tcpRouter.ResponseStatus]int)
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		raw, err := streamer.ReadBuff(conn)
+		if err != nil {
+			return statuses
+		}
+
+		var response tcpRouter.Response
+		if err = json.Unmarshal(raw, &response); err != nil {
+			t.Fatal(err)
+		}
+		statuses[response.Status]++
+	}
+}
+
+func TestShutdownDrainsQueuedRequests(t *testing.T) {
+	server := startTestServer(t, Config{DrainTimeout: 3 * time.Second})
+	conn := dialTestServer(t, server)
+	sendSlowRequests(t, conn, 4)
+
+	hookCalled := false
+	server.RegisterOnShutdown(func() { hookCalled = true })
+	server.Shutdown()
+
+	statuses := readAllResponses(t, conn)
+	if statuses[tcpRouter.StatusOK] != 4 {
+		t.Errorf("expected every queued request to be served, got %v", statuses)
+	}
+
+	if !hookCalled {
+		t.Error("shutdown hook was not called")
+	}
+}
+
+func TestShutdownDrainsContextAwareRequests(t *testing.T) {
+	server := startTestServer(t, Config{DrainTimeout: 3 * time.Second})
+	conn := dialTestServer(t, server)
+	for i := range 4 {
+		send(t, conn, tcpRouter.Request{
+			Id:              fmt.Sprintf("careful-%v", i),
+			RequestMeta:     tcpRouter.RequestMeta{Path: "/careful", Method: tcpRouter.GET},
+			ConnectionAlive: true,
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	server.Shutdown()
+
+	statuses := readAllResponses(t, conn)
+	if statuses[tcpRouter.StatusOK] != 4 {
+		t.Errorf("expected every queued request to be served, got %v", statuses)
+	}
+}
+
+func TestRequestTimeoutCancelsHandler(t *testing.T) {
+	server := startTestServer(t, Config{RequestTimeout: 50 * time.Millisecond})
+	conn := dialTestServer(t, server)
+
+	request := tcpRouter.Request{
+		Id:              "careful-1",
+		RequestMeta:     tcpRouter.RequestMeta{Path: "/careful", Method: tcpRouter.GET},
+		ConnectionAlive: true,
+	}
+	if response := fetch(t, conn, request); response.Status != tcpRouter.StatusServiceUnavailable {
+		t.Errorf("expected status Service Unavailable once the request timed out, got %v", response.Status)
+	}
+
+	request = healthRequest
+	request.ConnectionAlive = true
+	if response := fetch(t, conn, request); response.Status != tcpRouter.StatusOK {
+		t.Errorf("the server should keep serving after a timeout, got %v", response.Status)
+	}
+}
+
+func TestShutdownRejectsLeftoverRequests(t *testing.T) {
+	server := startTestServer(t, Config{DrainTimeout: 100 * time.Millisecond})
+	conn := dialTestServer(t, server)
+	sendSlowRequests(t, conn, 6)
+
+	server.Shutdown()
+
+	statuses := readAllResponses(t, conn)
+	if statuses[tcpRouter.StatusOK]+statuses[tcpRouter.StatusServiceUnavailable] != 6 {
+		t.Errorf("expected every request to be answered, got %v", statuses)
+	}
+
+	if statuses[tcpRouter.StatusServiceUnavailable] == 0 {
+		t.Errorf("expected requests left in the queue to be rejected, got %v", statuses)
+	}
+}
+
+// waitPoolWorking waits for Serve, which runs in its own goroutine, to
+// start the pool.
+func waitPoolWorking(t *testing.T, pool *threadpool.ThreadPool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !pool.IsWorking() {
+		if time.Now().After(deadline) {
+			t.Fatal("pool did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandleRunsRequestOnPool(t *testing.T) {
+	pool := threadpool.New(logs)
+	server := startTestServerWithPool(t, Config{}, pool)
+	waitPoolWorking(t, pool)
+
+	request := healthRequest
+	request.Id = "handled-1"
+	recorder := tcpRouter.NewResponseRecorder(nil)
+	if err := server.Handle(context.Background(), &request, recorder); err != nil {
+		t.Fatal(err)
+	}
+
+	response := recorder.Response()
+	if response == nil || response.Status != tcpRouter.StatusOK || response.Id != request.Id {
+		t.Fatalf("expected status OK for %v, got %v", request.Id, response)
+	}
+
+	// The pool counts the task once it returns, after the response.
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Completed == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the request to run as a pool task")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDrainHooksRunBeforePoolStops(t *testing.T) {
+	pool := threadpool.New(logs)
+	server := startTestServerWithPool(t, Config{DrainTimeout: time.Second}, pool)
+	waitPoolWorking(t, pool)
+
+	poolWorking := false
+	server.RegisterOnDrain(func() { poolWorking = pool.IsWorking() })
+	server.Shutdown()
+
+	if !poolWorking {
+		t.Error("expected drain hooks to run while the pool is still working")
+	}
+
+	request := healthRequest
+	recorder := tcpRouter.NewResponseRecorder(nil)
+	if err := server.Handle(context.Background(), &request, recorder); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("expected %v after shutdown, got %v", ErrServerClosed, err)
+	}
+	if response := recorder.Response(); response == nil || response.Status != tcpRouter.StatusServiceUnavailable {
+		t.Errorf("expected status Service Unavailable after shutdown, got %v", response)
+	}
+}
+
+func TestOversizedFrameIsRejected(t *testing.T) {
+	server := startTestServer(t, Config{MaxMessageSize: 64})
+	conn := dialTestServer(t, server)
+
+	request := healthRequest
+	request.Body = []byte(`"` + strings.Repeat("x", 128) + `"`)
+	response := fetch(t, conn, request)
+	if response.Status != tcpRouter.StatusPayloadTooLarge {
+		t.Errorf("expected status Payload Too Large, got %v", response.Status)
+	}
+}
+
+func TestInvalidFrameIsRejected(t *testing.T) {
+	server := startTestServer(t, Config{})
+	conn := dialTestServer(t, server)
+
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	response := readResponse(t, conn)
+	if response.Status != tcpRouter.StatusBadRequest {
+		t.Errorf("expected status Bad Request, got %v", response.Status)
+	}
+}
+
+func TestStreamedResponsesInterleaveById(t *testing.T) {
+	server := startTestServer(t, Config{})
+	conn := dialTestServer(t, server)
+
+	ids := []string{"a", "b"}
+	for _, id := range ids {
+		send(t, conn, tcpRouter.Request{
+			Id:              id,
+			RequestMeta:     tcpRouter.RequestMeta{Path: "/count", Method: tcpRouter.GET},
+			ConnectionAlive: true,
+			Stream:          true,
+		})
+	}
+
+	parts := make(map[string][]float64)
+	ended := make(map[string]bool)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(ended) < len(ids) {
+		frame, err := streamer.ReadFrame(conn, streamer.DefaultMaxMessageSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var response tcpRouter.Response
+		if err = json.Unmarshal(frame.Payload, &response); err != nil {
+			t.Fatal(err)
+		}
+
+		switch frame.Kind {
+		case streamer.FrameStreamChunk:
+			parts[response.Id] = append(parts[response.Id], response.Body.(float64))
+		case streamer.FrameStreamEnd:
+			ended[response.Id] = true
+		default:
+			t.Fatalf("unexpected frame kind %v", frame.Kind)
+		}
+	}
+
+	for _, id := range ids {
+		if fmt.Sprint(parts[id]) != "[0 1 2]" {
+			t.Errorf("stream %v: expected parts [0 1 2], got %v", id, parts[id])
+		}
+	}
+}
+
+func TestRunningRequestKeepsConnectionAlive(t *testing.T) {
+	server := startTestServer(t, Config{AliveTimeout: 100 * time.Millisecond})
+	conn := dialTestServer(t, server)
+
+	send(t, conn, tcpRouter.Request{
+		Id:              "wait",
+		RequestMeta:     tcpRouter.RequestMeta{Path: "/wait", Method: tcpRouter.GET},
+		ConnectionAlive: true,
+		Stream:          true,
+	})
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frame, err := streamer.ReadFrame(conn, streamer.DefaultMaxMessageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Kind != streamer.FrameStreamChunk {
+		t.Fatalf("expected a stream part, got frame kind %v", frame.Kind)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	aliveRequest := healthRequest
+	aliveRequest.ConnectionAlive = true
+	if response := fetch(t, conn, aliveRequest); response.Status != tcpRouter.StatusOK {
+		t.Errorf("expected status OK after AliveTimeout, got %v", response.Status)
+	}
+
+	_ = conn.Close()
+	select {
+	case id := <-waitCancelled:
+		if id != "wait" {
+			t.Errorf("expected request wait to be cancelled, got %v", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("request was not cancelled after the client disconnected")
+	}
+}
+
+func TestShutdownStopsSubscriptions(t *testing.T) {
+	server := startTestServer(t, Config{DrainTimeout: 3 * time.Second})
+	conn := dialTestServer(t, server)
+
+	send(t, conn, tcpRouter.Request{
+		Id:              "subscribed",
+		RequestMeta:     tcpRouter.RequestMeta{Path: "/wait", Method: tcpRouter.GET},
+		ConnectionAlive: true,
+		Stream:          true,
+	})
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := streamer.ReadFrame(conn, streamer.DefaultMaxMessageSize); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	server.Shutdown()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("shutdown waited %v for the subscription instead of stopping it", elapsed)
+	}
+
+	select {
+	case id := <-waitCancelled:
+		if id != "subscribed" {
+			t.Errorf("expected request subscribed to be stopped, got %v", id)
+		}
+	case <-time.After(time.Second):
+		t.Error("subscription was not stopped on shutdown")
+	}
+}
+
+func readResponseCodec(t *testing.T, conn net.Conn) (streamer.Codec, tcpRouter.Response) {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	header := make([]byte, streamer.HeaderSize)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := struct {
+		io.Reader
+		io.Writer
+	}{io.MultiReader(bytes.NewReader(header), conn), io.Discard}
+
+	raw, err := streamer.ReadBuff(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response tcpRouter.Response
+	if err = json.Unmarshal(raw, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	return streamer.Codec(header[3]), response
+}
+
+func TestCompressionIsNegotiatedByFirstRequest(t *testing.T) {
+	server := startTestServer(t, Config{})
+	largeRequest := tcpRouter.Request{
+		RequestMeta:     tcpRouter.RequestMeta{Path: "/large", Method: tcpRouter.GET},
+		ConnectionAlive: true,
+	}
+
+	plain := dialTestServer(t, server)
+	send(t, plain, largeRequest)
+	if codec, _ := readResponseCodec(t, plain); codec != streamer.CodecNone {
+		t.Errorf("expected an uncompressed response without negotiation, got %v", codec)
+	}
+
+	conn := dialTestServer(t, server)
+	negotiating := largeRequest
+	negotiating.AcceptEncoding = []string{"zstd", "deflate"}
+	send(t, conn, negotiating)
+	codec, response := readResponseCodec(t, conn)
+	if codec != streamer.CodecDeflate {
+		t.Errorf("expected deflate, got %v", codec)
+	}
+
+	if body, _ := response.Body.(string); body != strings.Repeat("compressible ", 1000) {
+		t.Error("compressed response body changed")
+	}
+
+	send(t, conn, healthRequest)
+	if codec, _ = readResponseCodec(t, conn); codec != streamer.CodecNone {
+		t.Errorf("expected a small response to stay uncompressed, got %v", codec)
+	}
+}
+
+func TestBinaryRequestsAreAnsweredInKind(t *testing.T) {
+	server := startTestServer(t, Config{})
+	conn := dialTestServer(t, server)
+
+	for _, path := range []tcpRouter.RequestPath{"/health", "/large"} {
+		request := tcpRouter.Request{
+			Id:              string(path),
+			RequestMeta:     tcpRouter.RequestMeta{Path: path, Method: tcpRouter.GET},
+			ConnectionAlive: true,
+		}
+
+		requestBin, err := request.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frame := streamer.Frame{Kind: streamer.FrameMessage, ContentType: streamer.ContentBinary, Payload: requestBin}
+		if err = streamer.WriteFrameCompressed(conn, 2048, frame, streamer.Compression{}); err != nil {
+			t.Fatal(err)
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if frame, err = streamer.ReadFrame(conn, streamer.DefaultMaxMessageSize); err != nil {
+			t.Fatal(err)
+		}
+
+		// /large answers with a plain string, which has no binary form.
+		expected := streamer.ContentBinary
+		if path == "/large" {
+			expected = streamer.ContentJSON
+		}
+
+		if frame.ContentType != expected {
+			t.Errorf("%v: expected a %v response, got %v", path, expected, frame.ContentType)
+		}
+	}
+}
+
+func TestUnixSocketAlongsideTCP(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "server.sock")
+
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	server := startTestServer(t, Config{UnixSocket: socket})
+	if len(server.Addrs()) != 2 {
+		t.Fatalf("expected TCP and unix listeners, got %v", server.Addrs())
+	}
+
+	unixConn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = unixConn.Close() })
+
+	for _, conn := range []net.Conn{unixConn, dialTestServer(t, server)} {
+		if response := fetch(t, conn, healthRequest); response.Status != tcpRouter.StatusOK {
+			t.Errorf("%v: expected status OK, got %v", conn.RemoteAddr().Network(), response.Status)
+		}
+	}
+
+	second := New(Config{DisableTCP: true, UnixSocket: socket}, threadpool.New(logs), nil, logs)
+	if err = second.Listen(); err == nil {
+		t.Error("a socket in use should not be replaced")
+	}
+}
+
+func TestDisconnectedClientRequestsAreDropped(t *testing.T) {
+	server := startTestServer(t, Config{})
+
+	// Both workers are taken until the subscriber leaves.
+	subscriber := dialTestServer(t, server)
+	for _, id := range []string{"wait-1", "wait-2"} {
+		send(t, subscriber, tcpRouter.Request{
+			Id:              id,
+			RequestMeta:     tcpRouter.RequestMeta{Path: "/wait", Method: tcpRouter.GET},
+			ConnectionAlive: true,
+			Stream:          true,
+		})
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	before := countedRuns.Load()
+	conn := dialTestServer(t, server)
+	for i := range 3 {
+		send(t, conn, tcpRouter.Request{
+			Id:              fmt.Sprintf("counted-%v", i),
+			RequestMeta:     tcpRouter.RequestMeta{Path: "/counted", Method: tcpRouter.GET},
+			ConnectionAlive: true,
+		})
+	}
+	_ = conn.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	_ = subscriber.Close()
+	for range 2 {
+		select {
+		case <-waitCancelled:
+		case <-time.After(2 * time.Second):
+			t.Fatal("subscriptions were not cancelled")
+		}
+	}
+
+	if response := fetch(t, dialTestServer(t, server), healthRequest); response.Status != tcpRouter.StatusOK {
+		t.Fatalf("expected status OK, got %v", response.Status)
+	}
+	if runs := countedRuns.Load() - before; runs != 0 {
+		t.Errorf("requests of a disconnected client should be dropped, %v ran", runs)
+	}
+}
+
+func TestFullQueueRejectsRequests(t *testing.T) {
+	pool := threadpool.New(logs)
+	if err := pool.SetQueueCapacity(1, threadpool.PolicyReject); err != nil {
+		t.Fatal(err)
+	}
+	server := startTestServerWithPool(t, Config{}, pool)
+
+	conn := dialTestServer(t, server)
+	sendSlowRequests(t, conn, 6)
+
+	statuses := readAllResponses(t, conn)
+	if statuses[tcpRouter.StatusOK]+statuses[tcpRouter.StatusTooManyRequests] != 6 {
+		t.Errorf("expected every request to be answered, got %v", statuses)
+	}
+	if statuses[tcpRouter.StatusTooManyRequests] == 0 {
+		t.Errorf("expected requests beyond the queue capacity to be rejected, got %v", statuses)
+	}
+
+	request := healthRequest
+	request.ConnectionAlive = true
+	if response := fetch(t, conn, request); response.Status != tcpRouter.StatusOK {
+		t.Errorf("a rejection should leave the connection open, got %v", response.Status)
+	}
+}
+
+func TestPanickingHandlerAnswersInternalError(t *testing.T) {
+	pool := threadpool.New(logs)
+	server := startTestServerWithPool(t, Config{}, pool)
+	conn := dialTestServer(t, server)
+
+	request := tcpRouter.Request{
+		Id:              "panic-1",
+		RequestMeta:     tcpRouter.RequestMeta{Path: "/panic", Method: tcpRouter.GET},
+		ConnectionAlive: true,
+	}
+	response := fetch(t, conn, request)
+	if response.Status != tcpRouter.StatusInternalServerError || response.Id != request.Id {
+		t.Errorf("expected status Internal Server Error for %v, got %v for %v", request.Id, response.Status, response.Id)
+	}
+
+	if response = fetch(t, conn, healthRequest); response.Status != tcpRouter.StatusOK {
+		t.Errorf("the server should keep serving after a panic, got %v", response.Status)
+	}
+
+	// The pool counts the panic once the task returns, after the response.
+	deadline := time.Now().Add(time.Second)
+	for pool.Panics()["GET /panic"] != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the panic to be counted for its route, got %v", pool.Panics())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
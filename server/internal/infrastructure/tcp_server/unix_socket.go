@@ -0,0 +1,53 @@
+package tcpServer
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+const staleSocketDialTimeout = 100 * time.Millisecond
+
+// listenUnix listens on a unix domain socket at path with the given file
+// permissions. A socket file left by a server that did not shut down
+// cleanly is removed first, one that still accepts connections is not.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.Chmod(path, mode); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%v exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, staleSocketDialTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%v is in use by another server", path)
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,42 @@
+package dto
+
+// ResizeWorkersRequest sets the number of thread pool workers.
+type ResizeWorkersRequest struct {
+	Workers int `json:"workers"`
+}
+
+// WorkersResponse describes the thread pool workers. Workers may exceed
+// Target for a while after a resize, busy workers stop once their request
+// is answered. MinWorkers and MaxWorkers are zero without autoscaling.
+type WorkersResponse struct {
+	Workers    int `json:"workers"`
+	Target     int `json:"target"`
+	Busy       int `json:"busy"`
+	Queued     int `json:"queued"`
+	MinWorkers int `json:"minWorkers,omitempty"`
+	MaxWorkers int `json:"maxWorkers,omitempty"`
+}
+
+// PercentilesResponse summarizes durations, in microseconds.
+type PercentilesResponse struct {
+	Count int64 `json:"count"`
+	P50Us int64 `json:"p50Us"`
+	P95Us int64 `json:"p95Us"`
+	P99Us int64 `json:"p99Us"`
+	MaxUs int64 `json:"maxUs"`
+}
+
+// PoolStatsResponse holds the thread pool counters since the server started.
+// Panics counts the requests that crashed, by route.
+type PoolStatsResponse struct {
+	Queued    int                 `json:"queued"`
+	Workers   int                 `json:"workers"`
+	Busy      int                 `json:"busy"`
+	Completed int64               `json:"completed"`
+	Failed    int64               `json:"failed"`
+	Skipped   int64               `json:"skipped"`
+	Rejected  int64               `json:"rejected"`
+	Panics    map[string]int64    `json:"panics,omitempty"`
+	QueueWait PercentilesResponse `json:"queueWait"`
+	RunTime   PercentilesResponse `json:"runTime"`
+}
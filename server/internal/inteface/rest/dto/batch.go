@@ -0,0 +1,27 @@
+package dto
+
+import (
+	"encoding/json"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+)
+
+// BatchItem is a single request of a batch, body is the route body.
+type BatchItem struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type BatchRequest struct {
+	Requests []BatchItem `json:"requests"`
+}
+
+type BatchItemResponse struct {
+	Status tcpRouter.ResponseStatus `json:"status"`
+	Body   any                      `json:"body,omitempty"`
+}
+
+// BatchResponse holds a response for every request, in request order.
+type BatchResponse struct {
+	Responses []BatchItemResponse `json:"responses"`
+}
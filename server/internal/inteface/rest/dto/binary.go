@@ -0,0 +1,418 @@
+package dto
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"slices"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/binenc"
+)
+
+// Binary encodings of the DTOs for clients that send ContentBinary frames.
+// Every DTO is a plain sequence of its fields in declaration order.
+
+var errMetadataMismatch = errors.New("metadata keys and values do not match")
+
+func (request SearchRequest) MarshalBinary() ([]byte, error) {
+	writer := binenc.NewWriter(len(request.Query) + 4)
+	writer.WriteString(request.Query)
+	return writer.Data(), nil
+}
+
+func (request *SearchRequest) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	request.Query = reader.ReadString()
+	return reader.Close()
+}
+
+func (response SearchResponse) MarshalBinary() ([]byte, error) {
+	size := 4
+	for _, file := range response.Files {
+		size += len(file) + 2
+	}
+
+	writer := binenc.NewWriter(size)
+	writer.WriteStrings(response.Files)
+	return writer.Data(), nil
+}
+
+func (response *SearchResponse) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	response.Files = reader.ReadStrings()
+	return reader.Close()
+}
+
+func (request AddFileRequest) MarshalBinary() ([]byte, error) {
+	return marshalString(request.FileName), nil
+}
+
+func (request *AddFileRequest) UnmarshalBinary(data []byte) error {
+	return unmarshalString(data, &request.FileName)
+}
+
+func (request GetFileRequest) MarshalBinary() ([]byte, error) {
+	return marshalString(request.FileName), nil
+}
+
+func (request *GetFileRequest) UnmarshalBinary(data []byte) error {
+	return unmarshalString(data, &request.FileName)
+}
+
+func (response GetFileResponse) MarshalBinary() ([]byte, error) {
+	return marshalString(response.FileContent), nil
+}
+
+func (response *GetFileResponse) UnmarshalBinary(data []byte) error {
+	return unmarshalString(data, &response.FileContent)
+}
+
+func (request RemoveFileRequest) MarshalBinary() ([]byte, error) {
+	return marshalString(request.FileName), nil
+}
+
+func (request *RemoveFileRequest) UnmarshalBinary(data []byte) error {
+	return unmarshalString(data, &request.FileName)
+}
+
+func (request SubscribeRequest) MarshalBinary() ([]byte, error) {
+	writer := binenc.NewWriter(64)
+	writer.WriteStrings(request.Types)
+	return writer.Data(), nil
+}
+
+func (request *SubscribeRequest) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	request.Types = reader.ReadStrings()
+	return reader.Close()
+}
+
+func (request CreateJobRequest) MarshalBinary() ([]byte, error) {
+	writer := binenc.NewWriter(len(request.Dir) + len(request.Glob) + 64)
+	writer.WriteStrings(request.Paths)
+	writer.WriteString(request.Dir)
+	writer.WriteString(request.Glob)
+	return writer.Data(), nil
+}
+
+func (request *CreateJobRequest) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	request.Paths = reader.ReadStrings()
+	request.Dir = reader.ReadString()
+	request.Glob = reader.ReadString()
+	return reader.Close()
+}
+
+func (response JobResponse) MarshalBinary() ([]byte, error) {
+	return marshalString(response.Id), nil
+}
+
+func (response *JobResponse) UnmarshalBinary(data []byte) error {
+	return unmarshalString(data, &response.Id)
+}
+
+const (
+	uploadFlagFinal = 1 << iota
+	uploadFlagOverwrite
+)
+
+func (request UploadRequest) MarshalBinary() ([]byte, error) {
+	var flags uint64
+	if request.Final {
+		flags |= uploadFlagFinal
+	}
+	if request.Overwrite {
+		flags |= uploadFlagOverwrite
+	}
+
+	writer := binenc.NewWriter(len(request.Content) + len(request.Name) + 64)
+	writer.WriteString(request.UploadId)
+	writer.WriteString(request.Name)
+	writer.WriteString(request.Content)
+	writer.WriteUvarint(uint64(request.Offset))
+	writer.WriteUvarint(flags)
+	return writer.Data(), nil
+}
+
+func (request *UploadRequest) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	request.UploadId = reader.ReadString()
+	request.Name = reader.ReadString()
+	request.Content = reader.ReadString()
+	request.Offset = int64(reader.ReadUvarint())
+	flags := reader.ReadUvarint()
+	request.Final = flags&uploadFlagFinal != 0
+	request.Overwrite = flags&uploadFlagOverwrite != 0
+	return reader.Close()
+}
+
+func (response UploadResponse) MarshalBinary() ([]byte, error) {
+	writer := binenc.NewWriter(len(response.UploadId) + len(response.FileName) + 16)
+	writer.WriteString(response.UploadId)
+	writer.WriteUvarint(uint64(response.Size))
+	writer.WriteString(response.FileName)
+	return writer.Data(), nil
+}
+
+func (response *UploadResponse) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	response.UploadId = reader.ReadString()
+	response.Size = int64(reader.ReadUvarint())
+	response.FileName = reader.ReadString()
+	return reader.Close()
+}
+
+// Metadata is written as its sorted keys followed by their values.
+func (request AddDocumentRequest) MarshalBinary() ([]byte, error) {
+	keys := make([]string, 0, len(request.Metadata))
+	for key := range request.Metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	values := make([]string, len(keys))
+	for idx, key := range keys {
+		values[idx] = request.Metadata[key]
+	}
+
+	writer := binenc.NewWriter(len(request.Id) + len(request.Text) + 64)
+	writer.WriteString(request.Id)
+	writer.WriteString(request.Text)
+	writer.WriteStrings(keys)
+	writer.WriteStrings(values)
+	writer.WriteBool(request.Overwrite)
+	return writer.Data(), nil
+}
+
+func (request *AddDocumentRequest) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	request.Id = reader.ReadString()
+	request.Text = reader.ReadString()
+	keys := reader.ReadStrings()
+	values := reader.ReadStrings()
+	request.Overwrite = reader.ReadBool()
+	if err := reader.Close(); err != nil {
+		return err
+	}
+
+	if len(keys) != len(values) {
+		return errMetadataMismatch
+	}
+
+	request.Metadata = nil
+	if len(keys) > 0 {
+		request.Metadata = make(map[string]string, len(keys))
+		for idx, key := range keys {
+			request.Metadata[key] = values[idx]
+		}
+	}
+
+	return nil
+}
+
+func (response DocumentPathResponse) MarshalBinary() ([]byte, error) {
+	writer := binenc.NewWriter(len(response.Id) + len(response.Path) + 4)
+	writer.WriteString(response.Id)
+	writer.WriteString(response.Path)
+	return writer.Data(), nil
+}
+
+func (response *DocumentPathResponse) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	response.Id = reader.ReadString()
+	response.Path = reader.ReadString()
+	return reader.Close()
+}
+
+func (request ResizeWorkersRequest) MarshalBinary() ([]byte, error) {
+	writer := binenc.NewWriter(4)
+	writer.WriteUvarint(uint64(request.Workers))
+	return writer.Data(), nil
+}
+
+func (request *ResizeWorkersRequest) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	request.Workers = int(reader.ReadUvarint())
+	return reader.Close()
+}
+
+func (response WorkersResponse) MarshalBinary() ([]byte, error) {
+	writer := binenc.NewWriter(12)
+	writer.WriteUvarint(uint64(response.Workers))
+	writer.WriteUvarint(uint64(response.Target))
+	writer.WriteUvarint(uint64(response.Busy))
+	writer.WriteUvarint(uint64(response.Queued))
+	writer.WriteUvarint(uint64(response.MinWorkers))
+	writer.WriteUvarint(uint64(response.MaxWorkers))
+	return writer.Data(), nil
+}
+
+func (response *WorkersResponse) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	response.Workers = int(reader.ReadUvarint())
+	response.Target = int(reader.ReadUvarint())
+	response.Busy = int(reader.ReadUvarint())
+	response.Queued = int(reader.ReadUvarint())
+	response.MinWorkers = int(reader.ReadUvarint())
+	response.MaxWorkers = int(reader.ReadUvarint())
+	return reader.Close()
+}
+
+// Panics are written as their sorted routes followed by their counts.
+func (response PoolStatsResponse) MarshalBinary() ([]byte, error) {
+	routes := make([]string, 0, len(response.Panics))
+	for route := range response.Panics {
+		routes = append(routes, route)
+	}
+	slices.Sort(routes)
+
+	writer := binenc.NewWriter(64)
+	writer.WriteUvarint(uint64(response.Queued))
+	writer.WriteUvarint(uint64(response.Workers))
+	writer.WriteUvarint(uint64(response.Busy))
+	writer.WriteUvarint(uint64(response.Completed))
+	writer.WriteUvarint(uint64(response.Failed))
+	writer.WriteUvarint(uint64(response.Skipped))
+	writer.WriteUvarint(uint64(response.Rejected))
+	writer.WriteStrings(routes)
+	for _, route := range routes {
+		writer.WriteUvarint(uint64(response.Panics[route]))
+	}
+	writePercentiles(writer, response.QueueWait)
+	writePercentiles(writer, response.RunTime)
+	return writer.Data(), nil
+}
+
+func (response *PoolStatsResponse) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	response.Queued = int(reader.ReadUvarint())
+	response.Workers = int(reader.ReadUvarint())
+	response.Busy = int(reader.ReadUvarint())
+	response.Completed = int64(reader.ReadUvarint())
+	response.Failed = int64(reader.ReadUvarint())
+	response.Skipped = int64(reader.ReadUvarint())
+	response.Rejected = int64(reader.ReadUvarint())
+
+	routes := reader.ReadStrings()
+	response.Panics = nil
+	if len(routes) > 0 {
+		response.Panics = make(map[string]int64, len(routes))
+		for _, route := range routes {
+			response.Panics[route] = int64(reader.ReadUvarint())
+		}
+	}
+
+	response.QueueWait = readPercentiles(reader)
+	response.RunTime = readPercentiles(reader)
+	return reader.Close()
+}
+
+func writePercentiles(writer *binenc.Writer, percentiles PercentilesResponse) {
+	writer.WriteUvarint(uint64(percentiles.Count))
+	writer.WriteUvarint(uint64(percentiles.P50Us))
+	writer.WriteUvarint(uint64(percentiles.P95Us))
+	writer.WriteUvarint(uint64(percentiles.P99Us))
+	writer.WriteUvarint(uint64(percentiles.MaxUs))
+}
+
+func readPercentiles(reader *binenc.Reader) PercentilesResponse {
+	return PercentilesResponse{
+		Count: int64(reader.ReadUvarint()),
+		P50Us: int64(reader.ReadUvarint()),
+		P95Us: int64(reader.ReadUvarint()),
+		P99Us: int64(reader.ReadUvarint()),
+		MaxUs: int64(reader.ReadUvarint()),
+	}
+}
+
+// Items of a binary batch carry their bodies in binary as well.
+func (request BatchRequest) MarshalBinary() ([]byte, error) {
+	writer := binenc.NewWriter(64 * len(request.Requests))
+	writer.WriteUvarint(uint64(len(request.Requests)))
+	for _, item := range request.Requests {
+		writer.WriteString(item.Method)
+		writer.WriteString(item.Path)
+		writer.WriteBytes(item.Body)
+	}
+	return writer.Data(), nil
+}
+
+func (request *BatchRequest) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	count := reader.ReadUvarint()
+	request.Requests = nil
+	for i := uint64(0); i < count && reader.Err() == nil; i++ {
+		item := BatchItem{
+			Method: reader.ReadString(),
+			Path:   reader.ReadString(),
+		}
+		if body := reader.ReadBytes(); len(body) > 0 {
+			item.Body = bytes.Clone(body)
+		}
+		request.Requests = append(request.Requests, item)
+	}
+	return reader.Close()
+}
+
+// Bodies of a binary batch response are the binary bodies of its items,
+// a batch with an item body without a binary form is answered in JSON.
+func (response BatchResponse) MarshalBinary() ([]byte, error) {
+	writer := binenc.NewWriter(64 * len(response.Responses))
+	writer.WriteUvarint(uint64(len(response.Responses)))
+	for _, item := range response.Responses {
+		var body []byte
+		switch data := item.Body.(type) {
+		case nil:
+		case []byte:
+			body = data
+		case encoding.BinaryMarshaler:
+			var err error
+			if body, err = data.MarshalBinary(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, tcpRouter.ErrBinaryUnsupported
+		}
+
+		writer.WriteUvarint(uint64(item.Status))
+		writer.WriteBytes(body)
+	}
+	return writer.Data(), nil
+}
+
+// UnmarshalBinary leaves the bodies as raw bytes, their type depends on
+// the route of the item.
+func (response *BatchResponse) UnmarshalBinary(data []byte) error {
+	reader := binenc.NewReader(data)
+	count := reader.ReadUvarint()
+	response.Responses = nil
+	for i := uint64(0); i < count && reader.Err() == nil; i++ {
+		item := BatchItemResponse{Status: tcpRouter.ResponseStatus(reader.ReadUvarint())}
+		if body := reader.ReadBytes(); len(body) > 0 {
+			item.Body = bytes.Clone(body)
+		}
+		response.Responses = append(response.Responses, item)
+	}
+	return reader.Close()
+}
+
+func (response ErrorResponse) MarshalBinary() ([]byte, error) {
+	return marshalString(response.Message), nil
+}
+
+func (response *ErrorResponse) UnmarshalBinary(data []byte) error {
+	return unmarshalString(data, &response.Message)
+}
+
+func marshalString(value string) []byte {
+	writer := binenc.NewWriter(len(value) + 4)
+	writer.WriteString(value)
+	return writer.Data()
+}
+
+func unmarshalString(data []byte, value *string) error {
+	reader := binenc.NewReader(data)
+	*value = reader.ReadString()
+	return reader.Close()
+}
@@ -0,0 +1,144 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"testing"
+)
+
+func searchResponseFixture(files int) SearchResponse {
+	response := SearchResponse{Files: make([]string, files)}
+	for i := range response.Files {
+		response.Files[i] = fmt.Sprintf("resources/data/train/pos/%v_7.txt", i)
+	}
+
+	return response
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	values := []struct {
+		sent     interface{ MarshalBinary() ([]byte, error) }
+		received interface{ UnmarshalBinary([]byte) error }
+	}{
+		{SearchRequest{Query: "brilliant movie"}, &SearchRequest{}},
+		{searchResponseFixture(3), &SearchResponse{}},
+		{SearchResponse{Files: []string{}}, &SearchResponse{}},
+		{AddFileRequest{FileName: "a.txt"}, &AddFileRequest{}},
+		{GetFileRequest{FileName: "b.txt"}, &GetFileRequest{}},
+		{GetFileResponse{FileContent: "line\nline"}, &GetFileResponse{}},
+		{RemoveFileRequest{FileName: "c.txt"}, &RemoveFileRequest{}},
+		{ErrorResponse{Message: "could not add file"}, &ErrorResponse{}},
+		{SubscribeRequest{Types: []string{"file_added", "file_removed"}}, &SubscribeRequest{}},
+		{CreateJobRequest{Paths: []string{"a.txt", "b.txt"}}, &CreateJobRequest{}},
+		{CreateJobRequest{Paths: []string{}, Dir: "resources/data", Glob: "*.txt"}, &CreateJobRequest{}},
+		{JobResponse{Id: "7"}, &JobResponse{}},
+		{UploadRequest{UploadId: "u1", Content: "text", Offset: 4096, Final: true}, &UploadRequest{}},
+		{UploadResponse{UploadId: "u1", Size: 4100, FileName: "a.txt"}, &UploadResponse{}},
+		{AddDocumentRequest{Id: "d1", Text: "text", Metadata: map[string]string{"lang": "en", "a": "b"}}, &AddDocumentRequest{}},
+		{AddDocumentRequest{Id: "d2", Overwrite: true}, &AddDocumentRequest{}},
+		{DocumentPathResponse{Id: "d1", Path: "doc://d1"}, &DocumentPathResponse{}},
+		{ResizeWorkersRequest{Workers: 16}, &ResizeWorkersRequest{}},
+		{PoolStatsResponse{
+			Queued:    3,
+			Workers:   12,
+			Busy:      12,
+			Completed: 90210,
+			Failed:    7,
+			Rejected:  40,
+			Panics:    map[string]int64{"GET /index/search": 2, "POST /index/file": 1},
+			QueueWait: PercentilesResponse{Count: 90217, P50Us: 120, P95Us: 4800, P99Us: 20000, MaxUs: 310000},
+			RunTime:   PercentilesResponse{Count: 90217, P50Us: 300, P95Us: 900, P99Us: 1500, MaxUs: 2000000},
+		}, &PoolStatsResponse{}},
+		{PoolStatsResponse{Workers: 1}, &PoolStatsResponse{}},
+		{WorkersResponse{Workers: 12, Target: 8, Busy: 5, Queued: 300, MinWorkers: 4, MaxWorkers: 32}, &WorkersResponse{}},
+		{BatchRequest{Requests: []BatchItem{
+			{Method: "GET", Path: "/index/search", Body: []byte{5, 'q', 'u', 'e', 'r', 'y'}},
+			{Method: "GET", Path: "/health"},
+		}}, &BatchRequest{}},
+		{BatchResponse{Responses: []BatchItemResponse{
+			{Status: tcpRouter.StatusOK, Body: []byte{2, 'o', 'k'}},
+			{Status: tcpRouter.StatusNotFound},
+		}}, &BatchResponse{}},
+	}
+
+	for _, value := range values {
+		data, err := value.sent.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = value.received.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+
+		received := reflect.ValueOf(value.received).Elem().Interface()
+		if !reflect.DeepEqual(received, value.sent) {
+			t.Errorf("expected %+v, got %+v", value.sent, received)
+		}
+	}
+}
+
+func TestBinaryRejectsTrailingData(t *testing.T) {
+	data, _ := SearchRequest{Query: "movie"}.MarshalBinary()
+	if err := (&SearchRequest{}).UnmarshalBinary(append(data, 0)); err == nil {
+		t.Error("expected an error for trailing data")
+	}
+}
+
+func benchmarkSearchResponse(
+	b *testing.B,
+	marshal func(SearchResponse) ([]byte, error),
+	unmarshal func([]byte, *SearchResponse) error,
+) {
+	for _, files := range []int{10, 1000} {
+		response := searchResponseFixture(files)
+		b.Run(fmt.Sprintf("files=%v", files), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				data, err := marshal(response)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				var decoded SearchResponse
+				if err = unmarshal(data, &decoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSearchResponseJSON(b *testing.B) {
+	benchmarkSearchResponse(b,
+		func(response SearchResponse) ([]byte, error) { return json.Marshal(response) },
+		func(data []byte, response *SearchResponse) error { return json.Unmarshal(data, response) },
+	)
+}
+
+func BenchmarkSearchResponseBinary(b *testing.B) {
+	benchmarkSearchResponse(b,
+		SearchResponse.MarshalBinary,
+		func(data []byte, response *SearchResponse) error { return response.UnmarshalBinary(data) },
+	)
+}
+
+func BenchmarkSearchRequestJSON(b *testing.B) {
+	request := SearchRequest{Query: "brilliant movie"}
+	b.ReportAllocs()
+	for range b.N {
+		data, _ := json.Marshal(request)
+		_ = json.Unmarshal(data, &SearchRequest{})
+	}
+}
+
+func BenchmarkSearchRequestBinary(b *testing.B) {
+	request := SearchRequest{Query: "brilliant movie"}
+	b.ReportAllocs()
+	for range b.N {
+		data, _ := request.MarshalBinary()
+		_ = (&SearchRequest{}).UnmarshalBinary(data)
+	}
+}
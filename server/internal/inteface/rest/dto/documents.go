@@ -0,0 +1,26 @@
+package dto
+
+import "time"
+
+type AddDocumentRequest struct {
+	Id        string            `json:"id"`
+	Text      string            `json:"text"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Overwrite bool              `json:"overwrite,omitempty"`
+}
+
+// DocumentPathResponse is the path a document is indexed under, it is
+// returned by searches and accepted wherever a file name is.
+type DocumentPathResponse struct {
+	Id   string `json:"id"`
+	Path string `json:"path"`
+}
+
+type DocumentResponse struct {
+	Id        string            `json:"id"`
+	Path      string            `json:"path"`
+	Text      string            `json:"text"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
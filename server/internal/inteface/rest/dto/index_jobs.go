@@ -0,0 +1,28 @@
+package dto
+
+import "time"
+
+// CreateJobRequest lists the files to index, or a directory whose files
+// matching glob are indexed. Glob defaults to every file.
+type CreateJobRequest struct {
+	Paths []string `json:"paths,omitempty"`
+	Dir   string   `json:"dir,omitempty"`
+	Glob  string   `json:"glob,omitempty"`
+}
+
+type JobResponse struct {
+	Id string `json:"id"`
+}
+
+type JobStatusResponse struct {
+	Id         string     `json:"id"`
+	State      string     `json:"state"`
+	Total      int        `json:"total"`
+	Done       int        `json:"done"`
+	Skipped    int        `json:"skipped"`
+	Failed     int        `json:"failed"`
+	EtaMs      int64      `json:"etaMs,omitempty"`
+	Errors     []string   `json:"errors,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
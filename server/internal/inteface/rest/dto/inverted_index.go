@@ -23,3 +23,7 @@ type GetFileResponse struct {
 type RemoveFileRequest struct {
 	FileName string `json:"fileName"`
 }
+
+type SubscribeRequest struct {
+	Types []string `json:"types"`
+}
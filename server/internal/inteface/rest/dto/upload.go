@@ -0,0 +1,19 @@
+package dto
+
+// UploadRequest is a chunk of an uploaded document. The first chunk names
+// the document, the following ones carry the uploadId it was answered with
+// and the offset they start at.
+type UploadRequest struct {
+	UploadId  string `json:"uploadId,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Content   string `json:"content"`
+	Offset    int64  `json:"offset,omitempty"`
+	Final     bool   `json:"final,omitempty"`
+	Overwrite bool   `json:"overwrite,omitempty"`
+}
+
+type UploadResponse struct {
+	UploadId string `json:"uploadId"`
+	Size     int64  `json:"size"`
+	FileName string `json:"fileName,omitempty"`
+}
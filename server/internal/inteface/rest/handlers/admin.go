@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"server/internal/inteface/rest/dto"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+)
+
+type WorkerPool interface {
+	Size() threadpool.PoolSize
+	Resize(workers int) error
+	Stats() threadpool.Stats
+}
+
+type Admin struct {
+	pool   WorkerPool
+	logger Logger
+}
+
+func NewAdmin(pool WorkerPool, logger Logger) *Admin {
+	return &Admin{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+func (a *Admin) Workers(ctx *tcpRouter.RequestContext) error {
+	return ctx.Response(tcpRouter.StatusOK, workersResponse(a.pool.Size()))
+}
+
+// ResizeWorkers changes the number of thread pool workers and answers
+// with the pool size right after it.
+func (a *Admin) ResizeWorkers(ctx *tcpRouter.RequestContext) error {
+	const op = "Admin.ResizeWorkers"
+
+	var body dto.ResizeWorkersRequest
+	if err := ctx.ShouldParseBody(&body); err != nil {
+		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
+		a.logger.Log(msg)
+		return ctx.Response(tcpRouter.StatusBadRequest, dto.ErrorResponse{
+			Message: "could not parse request body",
+		})
+	}
+
+	if err := a.pool.Resize(body.Workers); err != nil {
+		msg := fmt.Sprintf("%v: error resizing to %v workers: %v", op, body.Workers, err)
+		a.logger.Log(msg)
+		return ctx.Response(resizeErrorStatus(err), dto.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.Response(tcpRouter.StatusOK, workersResponse(a.pool.Size()))
+}
+
+// Stats answers with the thread pool counters and the percentiles of how
+// long requests waited for a worker and ran.
+func (a *Admin) Stats(ctx *tcpRouter.RequestContext) error {
+	stats := a.pool.Stats()
+	return ctx.Response(tcpRouter.StatusOK, dto.PoolStatsResponse{
+		Queued:    stats.Queued,
+		Workers:   stats.Workers,
+		Busy:      stats.Busy,
+		Completed: stats.Completed,
+		Failed:    stats.Failed,
+		Skipped:   stats.Skipped,
+		Rejected:  stats.Rejected,
+		Panics:    stats.Panics,
+		QueueWait: percentilesResponse(stats.QueueWait),
+		RunTime:   percentilesResponse(stats.RunTime),
+	})
+}
+
+func percentilesResponse(percentiles threadpool.Percentiles) dto.PercentilesResponse {
+	return dto.PercentilesResponse{
+		Count: percentiles.Count,
+		P50Us: percentiles.P50.Microseconds(),
+		P95Us: percentiles.P95.Microseconds(),
+		P99Us: percentiles.P99.Microseconds(),
+		MaxUs: percentiles.Max.Microseconds(),
+	}
+}
+
+func workersResponse(size threadpool.PoolSize) dto.WorkersResponse {
+	return dto.WorkersResponse{
+		Workers:    size.Workers,
+		Target:     size.Target,
+		Busy:       size.Busy,
+		Queued:     size.Queued,
+		MinWorkers: size.MinWorkers,
+		MaxWorkers: size.MaxWorkers,
+	}
+}
+
+func resizeErrorStatus(err error) tcpRouter.ResponseStatus {
+	switch {
+	case errors.Is(err, threadpool.ErrInvalidWorkerCount):
+		return tcpRouter.StatusBadRequest
+	case errors.Is(err, threadpool.ErrPoolNotRunning):
+		return tcpRouter.StatusServiceUnavailable
+	default:
+		return tcpRouter.StatusInternalServerError
+	}
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"server/internal/inteface/rest/dto"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+)
+
+const (
+	// MaxBatchSize bounds the requests of one batch.
+	MaxBatchSize = 1000
+	// BatchParallelism bounds the pool tasks one batch queues at once,
+	// so a large batch does not fill the queue other clients share.
+	BatchParallelism = 4
+
+	batchPath   tcpRouter.RequestPath = "/batch"
+	adminPrefix                       = "/admin/"
+)
+
+type Dispatcher interface {
+	Handle(ctx context.Context, request *tcpRouter.Request, writer tcpRouter.ResponseWriter) error
+	Priority(meta tcpRouter.RequestMeta) threadpool.Priority
+}
+
+type Executor interface {
+	AddTask(task *threadpool.Task) error
+}
+
+type Batch struct {
+	router       Dispatcher
+	executor     Executor
+	maxBatchSize int
+	parallelism  int
+	taskIds      atomic.Int64
+	logger       Logger
+}
+
+func NewBatch(executor Executor, maxBatchSize int, parallelism int, logger Logger) *Batch {
+	if maxBatchSize <= 0 {
+		maxBatchSize = MaxBatchSize
+	}
+
+	if parallelism <= 0 {
+		parallelism = BatchParallelism
+	}
+
+	return &Batch{
+		executor:     executor,
+		maxBatchSize: maxBatchSize,
+		parallelism:  parallelism,
+		logger:       logger,
+	}
+}
+
+// SetRouter sets the router batched requests are dispatched through,
+// it has to be called before the first batch is handled.
+func (b *Batch) SetRouter(router Dispatcher) {
+	b.router = router
+}
+
+// batchRun is a run of GET requests of a batch with the same priority.
+// The handler and the pool tasks it queued take the requests one by one,
+// whoever comes first.
+type batchRun struct {
+	priority   threadpool.Priority
+	indexes    []int
+	requests   []*tcpRouter.Request
+	recorders  []*tcpRouter.ResponseRecorder
+	next       atomic.Int64
+	unfinished sync.WaitGroup
+}
+
+// take returns the index of the next request nobody took yet, false once
+// every request is taken.
+func (run *batchRun) take() (int, bool) {
+	idx := int(run.next.Add(1) - 1)
+	return idx, idx < len(run.requests)
+}
+
+// Handle runs every request of the batch and answers with their responses
+// in request order. A failed request only fails its own item. Consecutive
+// GET requests run in parallel on the thread pool, POST and DELETE run one
+// by one after everything before them finished, so a batch sees its own
+// changes in order. Items are encoded like the batch, a binary batch holds
+// binary bodies.
+func (b *Batch) Handle(ctx *tcpRouter.RequestContext) error {
+	const op = "Batch.Handle"
+
+	var body dto.BatchRequest
+	if err := ctx.ShouldParseBody(&body); err != nil {
+		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
+		b.logger.Log(msg)
+		return ctx.Response(tcpRouter.StatusBadRequest, dto.ErrorResponse{
+			Message: "could not parse request body",
+		})
+	}
+
+	if len(body.Requests) > b.maxBatchSize {
+		return ctx.Response(tcpRouter.StatusPayloadTooLarge, dto.ErrorResponse{
+			Message: fmt.Sprintf("a batch holds at most %v requests", b.maxBatchSize),
+		})
+	}
+
+	requests := make([]*tcpRouter.Request, len(body.Requests))
+	responses := make([]dto.BatchItemResponse, len(body.Requests))
+	parallel := make([]int, 0, len(body.Requests))
+	for idx, item := range body.Requests {
+		// A cancelled batch stops, nobody waits for the rest of it.
+		if err := ctx.Context().Err(); err != nil {
+			return err
+		}
+
+		request := &tcpRouter.Request{
+			Id: ctx.Request.Id + "/" + strconv.Itoa(idx),
+			RequestMeta: tcpRouter.RequestMeta{
+				Path:   tcpRouter.RequestPath(item.Path),
+				Method: tcpRouter.RequestMethod(item.Method),
+			},
+			ContentType: ctx.Request.ContentType,
+			Body:        item.Body,
+		}
+
+		if err := request.RequestMeta.Method.Validate(); err != nil {
+			responses[idx] = itemError(tcpRouter.StatusBadRequest, err.Error())
+			continue
+		}
+
+		if request.RequestMeta.Path == batchPath {
+			responses[idx] = itemError(tcpRouter.StatusBadRequest, "batches cannot be nested")
+			continue
+		}
+
+		// A batch would bypass whatever keeps clients off the admin routes,
+		// such as the HTTP gateway refusing them.
+		if strings.HasPrefix(string(request.RequestMeta.Path), adminPrefix) {
+			responses[idx] = itemError(tcpRouter.StatusForbidden, "admin routes cannot be batched")
+			continue
+		}
+
+		requests[idx] = request
+		if request.RequestMeta.Method == tcpRouter.GET {
+			parallel = append(parallel, idx)
+			continue
+		}
+
+		b.runParallel(ctx, requests, parallel, responses)
+		parallel = parallel[:0]
+		responses[idx] = b.run(ctx, request)
+	}
+	b.runParallel(ctx, requests, parallel, responses)
+
+	return ctx.Response(tcpRouter.StatusOK, dto.BatchResponse{Responses: responses})
+}
+
+// runParallel runs the requests at indexes on up to parallelism pool
+// tasks and the handler itself, which takes requests like the tasks do.
+// The handler occupies a worker too, so waiting for the pool alone could
+// wait forever once every worker is busy with a batch. The tasks wait in
+// the queue at the priority of the route of their requests, the handler
+// takes the requests of higher priority first.
+func (b *Batch) runParallel(
+	ctx *tcpRouter.RequestContext,
+	requests []*tcpRouter.Request,
+	indexes []int,
+	responses []dto.BatchItemResponse,
+) {
+	const op = "Batch.runParallel"
+
+	if len(indexes) == 0 {
+		return
+	}
+
+	runs := b.prioritizedRuns(ctx, requests, indexes)
+
+	// The handler takes a share of the requests itself, a pool that does
+	// not take a task leaves the rest to it as well.
+	helpers := min(b.parallelism, len(indexes)-1)
+queue:
+	for _, run := range runs {
+		count := min(helpers, len(run.requests))
+		helpers -= count
+
+		for range count {
+			task := threadpool.NewTask(b.taskIds.Add(1), func(context.Context) error {
+				return b.runRequests(ctx.Context(), run)
+			})
+			_ = task.SetPriority(run.priority)
+			task.SetContext(ctx.Context())
+			task.SetType("batch item")
+
+			if err := b.executor.AddTask(task); err != nil {
+				msg := fmt.Sprintf("%v: could not queue batch requests: %v", op, err)
+				b.logger.Log(msg)
+				break queue
+			}
+		}
+	}
+
+	for _, run := range runs {
+		_ = b.runRequests(ctx.Context(), run)
+	}
+
+	for _, run := range runs {
+		run.unfinished.Wait()
+		for i, idx := range run.indexes {
+			responses[idx] = recordedResponse(run.recorders[i])
+		}
+	}
+}
+
+// prioritizedRuns groups the requests at indexes by the priority of their
+// route, highest priority first.
+func (b *Batch) prioritizedRuns(
+	ctx *tcpRouter.RequestContext,
+	requests []*tcpRouter.Request,
+	indexes []int,
+) []*batchRun {
+	var runs []*batchRun
+	for _, priority := range []threadpool.Priority{threadpool.PriorityHigh, threadpool.PriorityNormal, threadpool.PriorityLow} {
+		run := &batchRun{priority: priority}
+		for _, idx := range indexes {
+			if b.router.Priority(requests[idx].RequestMeta) != priority {
+				continue
+			}
+
+			run.indexes = append(run.indexes, idx)
+			run.requests = append(run.requests, requests[idx])
+			run.recorders = append(run.recorders, tcpRouter.NewResponseRecorder(ctx.Done()))
+		}
+
+		if len(run.indexes) > 0 {
+			run.unfinished.Add(len(run.indexes))
+			runs = append(runs, run)
+		}
+	}
+
+	return runs
+}
+
+// runRequests runs requests of the run until every one is taken and
+// returns the error of the last one that failed.
+func (b *Batch) runRequests(ctx context.Context, run *batchRun) error {
+	var err error
+	for idx, ok := run.take(); ok; idx, ok = run.take() {
+		if handleErr := b.handle(ctx, run.requests[idx], run.recorders[idx]); handleErr != nil {
+			err = handleErr
+		}
+		run.unfinished.Done()
+	}
+
+	return err
+}
+
+func (b *Batch) run(ctx *tcpRouter.RequestContext, request *tcpRouter.Request) dto.BatchItemResponse {
+	recorder := tcpRouter.NewResponseRecorder(ctx.Done())
+	_ = b.handle(ctx.Context(), request, recorder)
+	return recordedResponse(recorder)
+}
+
+// handle dispatches a batched request in the context of the batch, a
+// panicking one is left unanswered and only fails its own item.
+func (b *Batch) handle(ctx context.Context, request *tcpRouter.Request, recorder *tcpRouter.ResponseRecorder) error {
+	return threadpool.CatchPanic(func() error {
+		return b.router.Handle(ctx, request, recorder)
+	})
+}
+
+func recordedResponse(recorder *tcpRouter.ResponseRecorder) dto.BatchItemResponse {
+	response := recorder.Response()
+	if response == nil {
+		return itemError(tcpRouter.StatusInternalServerError, "request was not answered")
+	}
+
+	return dto.BatchItemResponse{
+		Status: response.Status,
+		Body:   response.Body,
+	}
+}
+
+func itemError(status tcpRouter.ResponseStatus, message string) dto.BatchItemResponse {
+	return dto.BatchItemResponse{
+		Status: status,
+		Body:   dto.ErrorResponse{Message: message},
+	}
+}
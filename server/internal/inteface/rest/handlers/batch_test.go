@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"server/internal/inteface/rest/dto"
+	"sync"
+	"testing"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/mock"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+)
+
+var logs = mock.NewLogger()
+
+// dispatcherStub answers every request with its path, routes under
+// /high and /low have that priority.
+type dispatcherStub struct{}
+
+func (dispatcherStub) Handle(_ context.Context, request *tcpRouter.Request, writer tcpRouter.ResponseWriter) error {
+	ctx := tcpRouter.NewRequestContext(context.Background(), request, writer)
+	return ctx.Response(tcpRouter.StatusOK, string(request.RequestMeta.Path))
+}
+
+func (dispatcherStub) Priority(meta tcpRouter.RequestMeta) threadpool.Priority {
+	switch meta.Path {
+	case "/high":
+		return threadpool.PriorityHigh
+	case "/low":
+		return threadpool.PriorityLow
+	default:
+		return threadpool.PriorityNormal
+	}
+}
+
+// executorStub keeps the queued tasks without running them, the handler
+// takes every request itself.
+type executorStub struct {
+	lock  sync.Mutex
+	tasks []*threadpool.Task
+}
+
+func (executor *executorStub) AddTask(task *threadpool.Task) error {
+	executor.lock.Lock()
+	defer executor.lock.Unlock()
+	executor.tasks = append(executor.tasks, task)
+	return nil
+}
+
+func TestBatchQueuesItemsAtTheirRoutePriority(t *testing.T) {
+	executor := &executorStub{}
+	batch := NewBatch(executor, MaxBatchSize, 3, logs)
+	batch.SetRouter(dispatcherStub{})
+
+	paths := []string{"/low", "/normal", "/high", "/low", "/high"}
+	items := make([]dto.BatchItem, len(paths))
+	for idx, path := range paths {
+		items[idx] = dto.BatchItem{Method: "GET", Path: path}
+	}
+	body, _ := json.Marshal(dto.BatchRequest{Requests: items})
+
+	recorder := tcpRouter.NewResponseRecorder(nil)
+	request := &tcpRouter.Request{
+		Id:          "batch",
+		RequestMeta: tcpRouter.RequestMeta{Path: batchPath, Method: tcpRouter.POST},
+		Body:        body,
+	}
+	if err := batch.Handle(tcpRouter.NewRequestContext(context.Background(), request, recorder)); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := recorder.Response().Body.(dto.BatchResponse).Responses
+	for idx, response := range responses {
+		if response.Status != tcpRouter.StatusOK || response.Body != paths[idx] {
+			t.Errorf("item %v: expected the response to %v, got %+v", idx, paths[idx], response)
+		}
+	}
+
+	// Helpers go to the higher priorities first.
+	expected := []threadpool.Priority{threadpool.PriorityHigh, threadpool.PriorityHigh, threadpool.PriorityNormal}
+	if len(executor.tasks) != len(expected) {
+		t.Fatalf("expected %v queued tasks, got %v", len(expected), len(executor.tasks))
+	}
+	for idx, task := range executor.tasks {
+		if task.Priority != expected[idx] {
+			t.Errorf("task %v: expected priority %v, got %v", idx, expected[idx], task.Priority)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	docStore "server/internal/infrastructure/doc_store"
+	invertedIdx "server/internal/infrastructure/inverted_idx"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"server/internal/inteface/rest/dto"
+	"server/internal/service"
+)
+
+type DocumentsService interface {
+	Add(doc docStore.Document, overwrite bool) (string, error)
+	Get(id string) (docStore.Document, error)
+	Remove(id string) error
+}
+
+type Documents struct {
+	documentsService DocumentsService
+	logger           Logger
+}
+
+func NewDocuments(documentsService DocumentsService, logger Logger) *Documents {
+	return &Documents{
+		documentsService: documentsService,
+		logger:           logger,
+	}
+}
+
+func (d *Documents) Add(ctx *tcpRouter.RequestContext) error {
+	const op = "Documents.Add"
+
+	var body dto.AddDocumentRequest
+	err := ctx.ShouldParseBody(&body)
+	if err != nil {
+		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
+		d.logger.Log(msg)
+		return ctx.Response(tcpRouter.StatusBadRequest, dto.ErrorResponse{
+			Message: "could not parse request body",
+		})
+	}
+
+	path, err := d.documentsService.Add(docStore.Document{
+		Id:       body.Id,
+		Text:     body.Text,
+		Metadata: body.Metadata,
+	}, body.Overwrite)
+	if err != nil {
+		msg := fmt.Sprintf("%v: error adding document %v: %v", op, body.Id, err)
+		d.logger.Log(msg)
+		return ctx.Response(documentErrorStatus(err), dto.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.Response(tcpRouter.StatusCreated, dto.DocumentPathResponse{
+		Id:   body.Id,
+		Path: path,
+	})
+}
+
+func (d *Documents) Get(ctx *tcpRouter.RequestContext) error {
+	doc, err := d.documentsService.Get(ctx.Param("id"))
+	if err != nil {
+		return ctx.Response(documentErrorStatus(err), dto.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.Response(tcpRouter.StatusOK, dto.DocumentResponse{
+		Id:        doc.Id,
+		Path:      invertedIdx.DocumentPath(doc.Id),
+		Text:      doc.Text,
+		Metadata:  doc.Metadata,
+		CreatedAt: doc.CreatedAt,
+		UpdatedAt: doc.UpdatedAt,
+	})
+}
+
+func (d *Documents) Remove(ctx *tcpRouter.RequestContext) error {
+	if err := d.documentsService.Remove(ctx.Param("id")); err != nil {
+		return ctx.Response(documentErrorStatus(err), dto.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.Response(tcpRouter.StatusNoContent, nil)
+}
+
+func documentErrorStatus(err error) tcpRouter.ResponseStatus {
+	switch {
+	case errors.Is(err, service.ErrInvalidDocumentId):
+		return tcpRouter.StatusBadRequest
+	case errors.Is(err, docStore.ErrExists):
+		return tcpRouter.StatusConflict
+	case errors.Is(err, docStore.ErrNotFound):
+		return tcpRouter.StatusNotFound
+	default:
+		return indexErrorStatus(err)
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	eventBus "server/internal/infrastructure/event_bus"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"server/internal/inteface/rest/dto"
+	"sync/atomic"
+)
+
+// MaxSubscribers bounds the open subscriptions. Every subscription holds
+// a thread pool worker for as long as the client listens.
+const MaxSubscribers = 4
+
+type EventBus interface {
+	Subscribe(types ...eventBus.EventType) *eventBus.Subscription
+}
+
+type Events struct {
+	bus            EventBus
+	logger         Logger
+	maxSubscribers int32
+	subscribers    atomic.Int32
+}
+
+func NewEvents(bus EventBus, maxSubscribers int, logger Logger) *Events {
+	if maxSubscribers <= 0 {
+		maxSubscribers = MaxSubscribers
+	}
+
+	return &Events{
+		bus:            bus,
+		logger:         logger,
+		maxSubscribers: int32(maxSubscribers),
+	}
+}
+
+// Subscribe streams index events to the client until it disconnects or
+// the server shuts down. The request has to ask for a stream.
+func (e *Events) Subscribe(ctx *tcpRouter.RequestContext) error {
+	const op = "Events.Subscribe"
+
+	var body dto.SubscribeRequest
+	if err := ctx.ParseOptionalBody(&body); err != nil {
+		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
+		e.logger.Log(msg)
+		return ctx.Response(tcpRouter.StatusBadRequest, dto.ErrorResponse{
+			Message: "could not parse request body",
+		})
+	}
+
+	types := make([]eventBus.EventType, 0, len(body.Types))
+	for _, name := range body.Types {
+		eventType := eventBus.EventType(name)
+		if err := eventType.Validate(); err != nil {
+			return ctx.Response(tcpRouter.StatusBadRequest, dto.ErrorResponse{
+				Message: fmt.Sprintf("unknown event type %q", name),
+			})
+		}
+		types = append(types, eventType)
+	}
+
+	if !ctx.Request.Stream {
+		return ctx.Response(tcpRouter.StatusBadRequest, dto.ErrorResponse{
+			Message: "events are only sent as a stream",
+		})
+	}
+
+	if e.subscribers.Add(1) > e.maxSubscribers {
+		e.subscribers.Add(-1)
+		return ctx.Response(tcpRouter.StatusTooManyRequests, dto.ErrorResponse{
+			Message: "too many event subscribers",
+		})
+	}
+	defer e.subscribers.Add(-1)
+
+	subscription := e.bus.Subscribe(types...)
+	defer func() {
+		subscription.Close()
+		if dropped := subscription.Dropped(); dropped > 0 {
+			msg := fmt.Sprintf("%v: subscriber [%v] dropped %v events", op, ctx.Request.Id, dropped)
+			e.logger.Log(msg)
+		}
+	}()
+
+	stream := ctx.Stream(tcpRouter.StatusOK)
+	for {
+		select {
+		case <-ctx.Done():
+			_ = stream.Close()
+			return nil
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return stream.Close()
+			}
+
+			// The client is gone, there is nobody left to report an error to.
+			if err := stream.Send(event); err != nil {
+				return nil
+			}
+		}
+	}
+}
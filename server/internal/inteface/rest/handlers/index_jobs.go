@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"server/internal/inteface/rest/dto"
+	"server/internal/service"
+)
+
+type IndexJobsService interface {
+	Start(files []string) (string, error)
+	StartDir(dir string, glob string) (string, error)
+	Status(id string) (service.JobStatus, error)
+	Cancel(id string) error
+}
+
+type IndexJobs struct {
+	jobsService IndexJobsService
+	logger      Logger
+}
+
+func NewIndexJobs(jobsService IndexJobsService, logger Logger) *IndexJobs {
+	return &IndexJobs{
+		jobsService: jobsService,
+		logger:      logger,
+	}
+}
+
+func (j *IndexJobs) Create(ctx *tcpRouter.RequestContext) error {
+	const op = "IndexJobs.Create"
+
+	var body dto.CreateJobRequest
+	err := ctx.ShouldParseBody(&body)
+	if err != nil {
+		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
+		j.logger.Log(msg)
+		return ctx.Response(tcpRouter.StatusBadRequest, dto.ErrorResponse{
+			Message: "could not parse request body",
+		})
+	}
+
+	if (len(body.Paths) == 0) == (body.Dir == "") {
+		return ctx.Response(tcpRouter.StatusBadRequest, dto.ErrorResponse{
+			Message: "either paths or dir has to be set",
+		})
+	}
+
+	var id string
+	if body.Dir != "" {
+		glob := body.Glob
+		if glob == "" {
+			glob = "*"
+		}
+		id, err = j.jobsService.StartDir(body.Dir, glob)
+	} else {
+		id, err = j.jobsService.Start(body.Paths)
+	}
+
+	if err != nil {
+		msg := fmt.Sprintf("%v: error starting job: %v", op, err)
+		j.logger.Log(msg)
+		return ctx.Response(jobErrorStatus(err), dto.ErrorResponse{
+			Message: fmt.Sprintf("could not start job: %v", err),
+		})
+	}
+
+	return ctx.Response(tcpRouter.StatusCreated, dto.JobResponse{Id: id})
+}
+
+func (j *IndexJobs) Status(ctx *tcpRouter.RequestContext) error {
+	status, err := j.jobsService.Status(ctx.Param("id"))
+	if err != nil {
+		return ctx.Response(jobErrorStatus(err), dto.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	response := dto.JobStatusResponse{
+		Id:        status.Id,
+		State:     string(status.State),
+		Total:     status.Total,
+		Done:      status.Done,
+		Skipped:   status.Skipped,
+		Failed:    status.Failed,
+		EtaMs:     status.Eta.Milliseconds(),
+		Errors:    status.Errors,
+		CreatedAt: status.CreatedAt,
+	}
+	if !status.FinishedAt.IsZero() {
+		response.FinishedAt = &status.FinishedAt
+	}
+
+	return ctx.Response(tcpRouter.StatusOK, response)
+}
+
+func (j *IndexJobs) Cancel(ctx *tcpRouter.RequestContext) error {
+	if err := j.jobsService.Cancel(ctx.Param("id")); err != nil {
+		return ctx.Response(jobErrorStatus(err), dto.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.Response(tcpRouter.StatusNoContent, nil)
+}
+
+func jobErrorStatus(err error) tcpRouter.ResponseStatus {
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		return tcpRouter.StatusNotFound
+	case errors.Is(err, service.ErrJobFinished):
+		return tcpRouter.StatusConflict
+	case errors.Is(err, service.ErrNoJobFiles), errors.Is(err, filepath.ErrBadPattern):
+		return tcpRouter.StatusBadRequest
+	case errors.Is(err, fs.ErrNotExist):
+		return tcpRouter.StatusNotFound
+	default:
+		return tcpRouter.StatusInternalServerError
+	}
+}
@@ -1,9 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	invertedIdx "server/internal/infrastructure/inverted_idx"
 	tcpRouter "server/internal/infrastructure/tcp_server/router"
 	"server/internal/inteface/rest/dto"
+	"unicode/utf8"
+)
+
+// Parts sent per frame when the client asks for a streamed response.
+const (
+	searchStreamBatch = 256
+	fileStreamChunk   = 64 * 1024
 )
 
 type InvertedIndexService interface {
@@ -34,7 +43,7 @@ func (i *InvertedIndex) Search(ctx *tcpRouter.RequestContext) error {
 	const op = "InvertedIndex.Search"
 
 	var body dto.SearchRequest
-	err := ctx.ShouldParseBodyJSON(&body)
+	err := ctx.ShouldParseBody(&body)
 	if err != nil {
 		errorResponse := dto.ErrorResponse{
 			Message: "could not parse request body",
@@ -42,22 +51,30 @@ func (i *InvertedIndex) Search(ctx *tcpRouter.RequestContext) error {
 
 		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
 		i.logger.Log(msg)
-		return ctx.ResponseJSON(tcpRouter.StatusBadRequest, errorResponse)
+		return ctx.Response(tcpRouter.StatusBadRequest, errorResponse)
+	}
+
+	if err = ctx.Context().Err(); err != nil {
+		return err
 	}
 
 	result := i.invIndexService.Search(body.Query)
+	if ctx.Request.Stream {
+		return streamSearchResult(ctx, result)
+	}
+
 	response := dto.SearchResponse{
 		Files: result,
 	}
 
-	return ctx.ResponseJSON(tcpRouter.StatusOK, response)
+	return ctx.Response(tcpRouter.StatusOK, response)
 }
 
 func (i *InvertedIndex) SearchAny(ctx *tcpRouter.RequestContext) error {
 	const op = "InvertedIndex.SearchAny"
 
 	var body dto.SearchRequest
-	err := ctx.ShouldParseBodyJSON(&body)
+	err := ctx.ShouldParseBody(&body)
 	if err != nil {
 		errorResponse := dto.ErrorResponse{
 			Message: "could not parse request body",
@@ -65,22 +82,30 @@ func (i *InvertedIndex) SearchAny(ctx *tcpRouter.RequestContext) error {
 
 		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
 		i.logger.Log(msg)
-		return ctx.ResponseJSON(tcpRouter.StatusBadRequest, errorResponse)
+		return ctx.Response(tcpRouter.StatusBadRequest, errorResponse)
+	}
+
+	if err = ctx.Context().Err(); err != nil {
+		return err
 	}
 
 	result := i.invIndexService.SearchAny(body.Query)
+	if ctx.Request.Stream {
+		return streamSearchResult(ctx, result)
+	}
+
 	response := dto.SearchResponse{
 		Files: result,
 	}
 
-	return ctx.ResponseJSON(tcpRouter.StatusOK, response)
+	return ctx.Response(tcpRouter.StatusOK, response)
 }
 
 func (i *InvertedIndex) AddFile(ctx *tcpRouter.RequestContext) error {
 	const op = "InvertedIndex.AddFile"
 
 	var body dto.AddFileRequest
-	err := ctx.ShouldParseBodyJSON(&body)
+	err := ctx.ShouldParseBody(&body)
 	if err != nil {
 		errorResponse := dto.ErrorResponse{
 			Message: "could not parse request body",
@@ -88,7 +113,11 @@ func (i *InvertedIndex) AddFile(ctx *tcpRouter.RequestContext) error {
 
 		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
 		i.logger.Log(msg)
-		return ctx.ResponseJSON(tcpRouter.StatusBadRequest, errorResponse)
+		return ctx.Response(tcpRouter.StatusBadRequest, errorResponse)
+	}
+
+	if err = ctx.Context().Err(); err != nil {
+		return err
 	}
 
 	err = i.invIndexService.AddFile(body.FileName)
@@ -99,17 +128,17 @@ func (i *InvertedIndex) AddFile(ctx *tcpRouter.RequestContext) error {
 
 		msg := fmt.Sprintf("%v: error adding file: %v", op, err)
 		i.logger.Log(msg)
-		return ctx.ResponseJSON(tcpRouter.StatusNotFound, errorResponse)
+		return ctx.Response(indexErrorStatus(err), errorResponse)
 	}
 
-	return ctx.ResponseJSON(tcpRouter.StatusOK, nil)
+	return ctx.Response(tcpRouter.StatusCreated, nil)
 }
 
 func (i *InvertedIndex) GetFileContent(ctx *tcpRouter.RequestContext) error {
 	const op = "InvertedIndex.GetFileContent"
 
 	var body dto.GetFileRequest
-	err := ctx.ShouldParseBodyJSON(&body)
+	err := ctx.ShouldParseBody(&body)
 	if err != nil {
 		errorResponse := dto.ErrorResponse{
 			Message: "could not parse request body",
@@ -117,7 +146,7 @@ func (i *InvertedIndex) GetFileContent(ctx *tcpRouter.RequestContext) error {
 
 		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
 		i.logger.Log(msg)
-		return ctx.ResponseJSON(tcpRouter.StatusBadRequest, errorResponse)
+		return ctx.Response(tcpRouter.StatusBadRequest, errorResponse)
 	}
 
 	content, err := i.invIndexService.GetFileContent(body.FileName)
@@ -128,20 +157,24 @@ func (i *InvertedIndex) GetFileContent(ctx *tcpRouter.RequestContext) error {
 
 		msg := fmt.Sprintf("%v: error finding the file: %v", op, err)
 		i.logger.Log(msg)
-		return ctx.ResponseJSON(tcpRouter.StatusNotFound, errorResponse)
+		return ctx.Response(indexErrorStatus(err), errorResponse)
+	}
+
+	if ctx.Request.Stream {
+		return streamFileContent(ctx, content)
 	}
 
 	response := dto.GetFileResponse{
 		FileContent: string(content),
 	}
-	return ctx.ResponseJSON(tcpRouter.StatusOK, response)
+	return ctx.Response(tcpRouter.StatusOK, response)
 }
 
 func (i *InvertedIndex) RemoveFile(ctx *tcpRouter.RequestContext) error {
 	const op = "InvertedIndex.RemoveFile"
 
 	var body dto.RemoveFileRequest
-	err := ctx.ShouldParseBodyJSON(&body)
+	err := ctx.ShouldParseBody(&body)
 	if err != nil {
 		errorResponse := dto.ErrorResponse{
 			Message: "could not parse request body",
@@ -149,7 +182,7 @@ func (i *InvertedIndex) RemoveFile(ctx *tcpRouter.RequestContext) error {
 
 		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
 		i.logger.Log(msg)
-		return ctx.ResponseJSON(tcpRouter.StatusBadRequest, errorResponse)
+		return ctx.Response(tcpRouter.StatusBadRequest, errorResponse)
 	}
 
 	err = i.invIndexService.RemoveFile(body.FileName)
@@ -160,8 +193,58 @@ func (i *InvertedIndex) RemoveFile(ctx *tcpRouter.RequestContext) error {
 
 		msg := fmt.Sprintf("%v: error removing the file: %v", op, err)
 		i.logger.Log(msg)
-		return ctx.ResponseJSON(tcpRouter.StatusNotFound, errorResponse)
+		return ctx.Response(indexErrorStatus(err), errorResponse)
 	}
 
-	return ctx.ResponseJSON(tcpRouter.StatusOK, nil)
+	return ctx.Response(tcpRouter.StatusNoContent, nil)
+}
+
+func streamSearchResult(ctx *tcpRouter.RequestContext, files []string) error {
+	stream := ctx.Stream(tcpRouter.StatusOK)
+	for start := 0; start < len(files); start += searchStreamBatch {
+		if err := ctx.Context().Err(); err != nil {
+			return err
+		}
+
+		end := min(start+searchStreamBatch, len(files))
+		if err := stream.Send(dto.SearchResponse{Files: files[start:end]}); err != nil {
+			return err
+		}
+	}
+
+	return stream.Close()
+}
+
+// streamFileContent splits content on rune boundaries, so every part is
+// valid UTF-8 on its own and survives the JSON round trip.
+func streamFileContent(ctx *tcpRouter.RequestContext, content []byte) error {
+	stream := ctx.Stream(tcpRouter.StatusOK)
+	for len(content) > 0 {
+		if err := ctx.Context().Err(); err != nil {
+			return err
+		}
+
+		end := min(fileStreamChunk, len(content))
+		for end < len(content) && !utf8.RuneStart(content[end]) {
+			end++
+		}
+
+		if err := stream.Send(dto.GetFileResponse{FileContent: string(content[:end])}); err != nil {
+			return err
+		}
+		content = content[end:]
+	}
+
+	return stream.Close()
+}
+
+func indexErrorStatus(err error) tcpRouter.ResponseStatus {
+	switch {
+	case errors.Is(err, invertedIdx.ErrAlreadyIndexed):
+		return tcpRouter.StatusConflict
+	case errors.Is(err, invertedIdx.ErrNotIndexed), errors.Is(err, invertedIdx.ErrNotFound):
+		return tcpRouter.StatusNotFound
+	default:
+		return tcpRouter.StatusInternalServerError
+	}
 }
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"server/internal/inteface/rest/dto"
+	"server/internal/service"
+)
+
+type IngestService interface {
+	Upload(chunk service.Chunk) (service.UploadResult, error)
+}
+
+type Upload struct {
+	ingestService IngestService
+	logger        Logger
+}
+
+func NewUpload(ingestService IngestService, logger Logger) *Upload {
+	return &Upload{
+		ingestService: ingestService,
+		logger:        logger,
+	}
+}
+
+// Upload stores a chunk of a document. Every chunk but the final one is
+// answered with StatusOK and the upload id to continue with, the final one
+// with StatusCreated once the document is stored and indexed.
+func (u *Upload) Upload(ctx *tcpRouter.RequestContext) error {
+	const op = "Upload.Upload"
+
+	var body dto.UploadRequest
+	err := ctx.ShouldParseBody(&body)
+	if err != nil {
+		msg := fmt.Sprintf("%v: error parsing request body: %v", op, err)
+		u.logger.Log(msg)
+		return ctx.Response(tcpRouter.StatusBadRequest, dto.ErrorResponse{
+			Message: "could not parse request body",
+		})
+	}
+
+	result, err := u.ingestService.Upload(service.Chunk{
+		UploadId:  body.UploadId,
+		Name:      body.Name,
+		Content:   []byte(body.Content),
+		Offset:    body.Offset,
+		Final:     body.Final,
+		Overwrite: body.Overwrite,
+	})
+	if err != nil {
+		msg := fmt.Sprintf("%v: error uploading %v: %v", op, body.Name, err)
+		u.logger.Log(msg)
+		return ctx.Response(uploadErrorStatus(err), dto.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	response := dto.UploadResponse{
+		UploadId: result.UploadId,
+		Size:     result.Size,
+		FileName: result.FilePath,
+	}
+
+	if body.Final {
+		return ctx.Response(tcpRouter.StatusCreated, response)
+	}
+
+	return ctx.Response(tcpRouter.StatusOK, response)
+}
+
+func uploadErrorStatus(err error) tcpRouter.ResponseStatus {
+	switch {
+	case errors.Is(err, service.ErrInvalidDocumentName):
+		return tcpRouter.StatusBadRequest
+	case errors.Is(err, service.ErrDocumentExists), errors.Is(err, service.ErrUploadOffset):
+		return tcpRouter.StatusConflict
+	case errors.Is(err, service.ErrUploadNotFound):
+		return tcpRouter.StatusNotFound
+	case errors.Is(err, service.ErrUploadTooLarge), errors.Is(err, service.ErrUploadsTooLarge):
+		return tcpRouter.StatusPayloadTooLarge
+	case errors.Is(err, service.ErrTooManyUploads):
+		return tcpRouter.StatusTooManyRequests
+	default:
+		return indexErrorStatus(err)
+	}
+}
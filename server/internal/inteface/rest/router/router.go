@@ -1,6 +1,11 @@
 package router
 
-import tcpRouter "server/internal/infrastructure/tcp_server/router"
+import (
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"server/internal/inteface/rest/handlers"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+)
 
 type InvertedIndexHandlers interface {
 	Search(ctx *tcpRouter.RequestContext) error
@@ -10,21 +15,80 @@ type InvertedIndexHandlers interface {
 	RemoveFile(ctx *tcpRouter.RequestContext) error
 }
 
+type IndexJobsHandlers interface {
+	Create(ctx *tcpRouter.RequestContext) error
+	Status(ctx *tcpRouter.RequestContext) error
+	Cancel(ctx *tcpRouter.RequestContext) error
+}
+
+type UploadHandlers interface {
+	Upload(ctx *tcpRouter.RequestContext) error
+}
+
+type DocumentsHandlers interface {
+	Add(ctx *tcpRouter.RequestContext) error
+	Get(ctx *tcpRouter.RequestContext) error
+	Remove(ctx *tcpRouter.RequestContext) error
+}
+
+type EventsHandlers interface {
+	Subscribe(ctx *tcpRouter.RequestContext) error
+}
+
+type AdminHandlers interface {
+	Workers(ctx *tcpRouter.RequestContext) error
+	ResizeWorkers(ctx *tcpRouter.RequestContext) error
+	Stats(ctx *tcpRouter.RequestContext) error
+}
+
+type BatchHandlers interface {
+	Handle(ctx *tcpRouter.RequestContext) error
+	SetRouter(router handlers.Dispatcher)
+}
+
 func HealthCheck(ctx *tcpRouter.RequestContext) error {
-	return ctx.ResponseJSON(tcpRouter.StatusOK, nil)
+	return ctx.Response(tcpRouter.StatusOK, nil)
 }
 
 type Logger interface {
 	Log(...interface{})
 }
 
-func MustInitRouter(invIndexHandlers InvertedIndexHandlers, logger Logger) *tcpRouter.Router {
+func MustInitRouter(
+	invIndexHandlers InvertedIndexHandlers,
+	jobsHandlers IndexJobsHandlers,
+	uploadHandlers UploadHandlers,
+	documentsHandlers DocumentsHandlers,
+	eventsHandlers EventsHandlers,
+	batchHandlers BatchHandlers,
+	adminHandlers AdminHandlers,
+	logger Logger,
+) *tcpRouter.Router {
+	// Health checks and admin requests never wait behind other requests,
+	// requests that index files or documents wait behind searches.
 	router := tcpRouter.New(logger)
-	router.AddRoute(tcpRouter.GET, "/health", HealthCheck)
+	router.AddRouteWithPriority(tcpRouter.GET, "/health", threadpool.PriorityHigh, HealthCheck)
 	router.AddRoute(tcpRouter.GET, "/index/search", invIndexHandlers.Search)
 	router.AddRoute(tcpRouter.GET, "/index/search-any", invIndexHandlers.SearchAny)
 	router.AddRoute(tcpRouter.GET, "/index/file", invIndexHandlers.GetFileContent)
-	router.AddRoute(tcpRouter.POST, "/index/file", invIndexHandlers.AddFile)
+	router.AddRouteWithPriority(tcpRouter.POST, "/index/file", threadpool.PriorityLow, invIndexHandlers.AddFile)
 	router.AddRoute(tcpRouter.DELETE, "/index/file", invIndexHandlers.RemoveFile)
+	router.AddRouteWithPriority(tcpRouter.POST, "/index/upload", threadpool.PriorityLow, uploadHandlers.Upload)
+	router.AddRouteWithPriority(tcpRouter.POST, "/index/documents", threadpool.PriorityLow, documentsHandlers.Add)
+	router.AddRoute(tcpRouter.GET, "/index/documents/{id}", documentsHandlers.Get)
+	router.AddRoute(tcpRouter.DELETE, "/index/documents/{id}", documentsHandlers.Remove)
+	router.AddRoute(tcpRouter.POST, "/index/jobs", jobsHandlers.Create)
+	router.AddRoute(tcpRouter.GET, "/index/jobs/{id}", jobsHandlers.Status)
+	router.AddRoute(tcpRouter.DELETE, "/index/jobs/{id}", jobsHandlers.Cancel)
+	router.AddRoute(tcpRouter.GET, "/events/subscribe", eventsHandlers.Subscribe)
+	router.AddRoute(tcpRouter.POST, "/batch", batchHandlers.Handle)
+	// Admin routes are not authenticated, they are only served when the
+	// server is started with them enabled.
+	if adminHandlers != nil {
+		router.AddRouteWithPriority(tcpRouter.GET, "/admin/workers", threadpool.PriorityHigh, adminHandlers.Workers)
+		router.AddRouteWithPriority(tcpRouter.POST, "/admin/workers", threadpool.PriorityHigh, adminHandlers.ResizeWorkers)
+		router.AddRouteWithPriority(tcpRouter.GET, "/admin/stats", threadpool.PriorityHigh, adminHandlers.Stats)
+	}
+	batchHandlers.SetRouter(router)
 	return router
 }
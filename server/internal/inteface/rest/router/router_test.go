@@ -0,0 +1,679 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	docStore "server/internal/infrastructure/doc_store"
+	eventBus "server/internal/infrastructure/event_bus"
+	fileManager "server/internal/infrastructure/file_manager"
+	invertedIdx "server/internal/infrastructure/inverted_idx"
+	tcpServer "server/internal/infrastructure/tcp_server"
+	tcpRouter "server/internal/infrastructure/tcp_server/router"
+	"server/internal/inteface/rest/dto"
+	"server/internal/inteface/rest/handlers"
+	"server/internal/service"
+	"testing"
+	"time"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/binenc"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/mock"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/streamer"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+)
+
+var logs = mock.NewLogger()
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func fetch(t *testing.T, socket string, method tcpRouter.RequestMethod, path string, body any) tcpRouter.Response {
+	t.Helper()
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rawBody, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := json.Marshal(tcpRouter.Request{
+		RequestMeta: tcpRouter.RequestMeta{Path: tcpRouter.RequestPath(path), Method: method},
+		Body:        rawBody,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = streamer.WriteBuff(conn, 2048, request); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	raw, err := streamer.ReadBuff(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response tcpRouter.Response
+	if err = json.Unmarshal(raw, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+func search(t *testing.T, socket, query string) []string {
+	t.Helper()
+
+	response := fetch(t, socket, tcpRouter.GET, "/index/search", dto.SearchRequest{Query: query})
+	if response.Status != tcpRouter.StatusOK {
+		t.Fatalf("search: expected status OK, got %v", response.Status)
+	}
+
+	raw, _ := json.Marshal(response.Body)
+	var result dto.SearchResponse
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	return result.Files
+}
+
+// startServer serves the full router over a unix socket in dir with
+// threadCount workers, the index holds the indexed files and uploads go
+// to dir/uploads.
+func startServer(t *testing.T, dir string, threadCount int, indexed ...string) (*tcpServer.Server, string) {
+	t.Helper()
+	return startServerWithPool(t, dir, threadpool.New(logs), threadCount, indexed...)
+}
+
+func startServerWithPool(
+	t *testing.T,
+	dir string,
+	pool *threadpool.ThreadPool,
+	threadCount int,
+	indexed ...string,
+) (*tcpServer.Server, string) {
+	t.Helper()
+
+	files := fileManager.New(logs)
+	documents := docStore.New()
+	invIndex := invertedIdx.New(files, logs)
+	invIndex.SetDocumentSource(invertedIdx.NewDocumentSources(files, documents))
+	for _, file := range indexed {
+		if err := invIndex.AddFile(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bus := eventBus.New(eventBus.DefaultBufferSize)
+	jobs := service.NewIndexJobs(invIndex, files, pool, service.JobParallelism, logs)
+	ingest, err := service.NewIngest(filepath.Join(dir, "uploads"), invIndex, service.MaxUploadSize, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := MustInitRouter(
+		handlers.NewInvertedIndex(invIndex, logs),
+		handlers.NewIndexJobs(jobs, logs),
+		handlers.NewUpload(ingest, logs),
+		handlers.NewDocuments(service.NewDocuments(documents, invIndex, logs), logs),
+		handlers.NewEvents(bus, handlers.MaxSubscribers, logs),
+		handlers.NewBatch(pool, handlers.MaxBatchSize, handlers.BatchParallelism, logs),
+		handlers.NewAdmin(pool, logs),
+		logs,
+	)
+
+	socket := filepath.Join(dir, "server.sock")
+	config := tcpServer.Config{DisableTCP: true, UnixSocket: socket, UnixSocketMode: 0600}
+	server := tcpServer.New(config, pool, router, logs)
+	if err := server.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve(threadCount)
+	}()
+	t.Cleanup(server.Shutdown)
+
+	return server, socket
+}
+
+func TestRouterOverUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	first := writeFile(t, dir, "first.txt", "parallel computing course")
+	second := writeFile(t, dir, "second.txt", "parallel index server")
+	server, socket := startServer(t, dir, 2, first)
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected socket mode 0600, got %v", info.Mode().Perm())
+	}
+
+	if response := fetch(t, socket, tcpRouter.GET, "/health", nil); response.Status != tcpRouter.StatusOK {
+		t.Errorf("health: expected status OK, got %v", response.Status)
+	}
+
+	response := fetch(t, socket, tcpRouter.POST, "/index/file", dto.AddFileRequest{FileName: second})
+	if response.Status != tcpRouter.StatusCreated {
+		t.Errorf("add file: expected status Created, got %v", response.Status)
+	}
+
+	if files := search(t, socket, "parallel"); len(files) != 2 {
+		t.Errorf("search: expected both files, got %v", files)
+	}
+
+	response = fetch(t, socket, tcpRouter.GET, "/index/file", dto.GetFileRequest{FileName: second})
+	if response.Status != tcpRouter.StatusOK {
+		t.Errorf("file content: expected status OK, got %v", response.Status)
+	}
+
+	response = fetch(t, socket, tcpRouter.DELETE, "/index/file", dto.RemoveFileRequest{FileName: first})
+	if response.Status != tcpRouter.StatusNoContent {
+		t.Errorf("remove file: expected status No Content, got %v", response.Status)
+	}
+
+	if files := search(t, socket, "parallel"); len(files) != 1 || files[0] != second {
+		t.Errorf("search after remove: expected only %v, got %v", second, files)
+	}
+
+	server.Shutdown()
+	if _, err = os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket file should be removed on shutdown, got %v", err)
+	}
+}
+
+func TestShutdownDrainsQueuedSearches(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "file.txt", "parallel computing course")
+	pool := threadpool.New(logs)
+	server, socket := startServerWithPool(t, dir, pool, 1, file)
+
+	for !pool.IsWorking() {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The only worker is busy, so the searches are still queued once the
+	// shutdown starts.
+	release := make(chan struct{})
+	_ = pool.AddTask(threadpool.NewTask(0, func(context.Context) error {
+		<-release
+		return nil
+	}))
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	body, _ := json.Marshal(dto.SearchRequest{Query: "parallel"})
+	for i := range 4 {
+		request, _ := json.Marshal(tcpRouter.Request{
+			Id:              fmt.Sprintf("search-%v", i),
+			RequestMeta:     tcpRouter.RequestMeta{Path: "/index/search", Method: tcpRouter.GET},
+			Body:            body,
+			ConnectionAlive: true,
+		})
+		if err = streamer.WriteBuff(conn, 2048, request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	time.AfterFunc(100*time.Millisecond, func() { close(release) })
+	server.Shutdown()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := range 4 {
+		raw, err := streamer.ReadBuff(conn)
+		if err != nil {
+			t.Fatalf("expected 4 responses, got %v: %v", i, err)
+		}
+
+		var response tcpRouter.Response
+		if err = json.Unmarshal(raw, &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Status != tcpRouter.StatusOK {
+			t.Errorf("expected queued search %v to be served, got %v", response.Id, response.Status)
+		}
+	}
+}
+
+func TestBatchRunsEveryRequest(t *testing.T) {
+	dir := t.TempDir()
+	first := writeFile(t, dir, "first.txt", "parallel computing course")
+	second := writeFile(t, dir, "second.txt", "parallel index server")
+
+	// A single worker is taken by the batch itself, its reads still run.
+	_, socket := startServer(t, dir, 1, first)
+
+	searchBody, _ := json.Marshal(dto.SearchRequest{Query: "parallel"})
+	addBody, _ := json.Marshal(dto.AddFileRequest{FileName: second})
+	removeBody, _ := json.Marshal(dto.RemoveFileRequest{FileName: filepath.Join(dir, "missing.txt")})
+	items := []dto.BatchItem{
+		{Method: "GET", Path: "/index/search", Body: searchBody},
+		{Method: "POST", Path: "/index/file", Body: addBody},
+		{Method: "GET", Path: "/index/search", Body: searchBody},
+		{Method: "DELETE", Path: "/index/file", Body: removeBody},
+		{Method: "GET", Path: "/missing"},
+		{Method: "PUT", Path: "/index/file"},
+		{Method: "POST", Path: "/batch"},
+		{Method: "POST", Path: "/admin/workers"},
+	}
+	for range 20 {
+		items = append(items, dto.BatchItem{Method: "GET", Path: "/health"})
+	}
+
+	response := fetch(t, socket, tcpRouter.POST, "/batch", dto.BatchRequest{Requests: items})
+	if response.Status != tcpRouter.StatusOK {
+		t.Fatalf("expected status OK, got %v: %v", response.Status, response.Body)
+	}
+
+	raw, _ := json.Marshal(response.Body)
+	var batch struct {
+		Responses []struct {
+			Status tcpRouter.ResponseStatus `json:"status"`
+			Body   json.RawMessage          `json:"body"`
+		} `json:"responses"`
+	}
+	if err := json.Unmarshal(raw, &batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batch.Responses) != len(items) {
+		t.Fatalf("expected %v responses, got %v", len(items), len(batch.Responses))
+	}
+
+	expected := []tcpRouter.ResponseStatus{
+		tcpRouter.StatusOK,
+		tcpRouter.StatusCreated,
+		tcpRouter.StatusOK,
+		tcpRouter.StatusNotFound,
+		tcpRouter.StatusNotFound,
+		tcpRouter.StatusBadRequest,
+		tcpRouter.StatusBadRequest,
+		tcpRouter.StatusForbidden,
+	}
+	for idx, item := range batch.Responses {
+		status := tcpRouter.StatusOK
+		if idx < len(expected) {
+			status = expected[idx]
+		}
+		if item.Status != status {
+			t.Errorf("item %v: expected status %v, got %v", idx, status, item.Status)
+		}
+	}
+
+	for idx, count := range map[int]int{0: 1, 2: 2} {
+		var result dto.SearchResponse
+		if err := json.Unmarshal(batch.Responses[idx].Body, &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Files) != count {
+			t.Errorf("item %v: expected %v files, got %v", idx, count, result.Files)
+		}
+	}
+}
+
+func TestBinaryBatch(t *testing.T) {
+	dir := t.TempDir()
+	first := writeFile(t, dir, "first.txt", "parallel computing course")
+	second := writeFile(t, dir, "second.txt", "parallel index server")
+	_, socket := startServer(t, dir, 2, first, second)
+
+	queries := []string{"computing", "parallel"}
+	items := make([]dto.BatchItem, len(queries))
+	for idx, query := range queries {
+		body, _ := dto.SearchRequest{Query: query}.MarshalBinary()
+		items[idx] = dto.BatchItem{Method: "GET", Path: "/index/search", Body: body}
+	}
+	body, _ := dto.BatchRequest{Requests: items}.MarshalBinary()
+
+	request := tcpRouter.Request{
+		RequestMeta: tcpRouter.RequestMeta{Path: "/batch", Method: tcpRouter.POST},
+		Body:        body,
+	}
+	payload, err := request.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	frame := streamer.Frame{Kind: streamer.FrameMessage, ContentType: streamer.ContentBinary, Payload: payload}
+	if err = streamer.WriteFrameCompressed(conn, 2048, frame, streamer.Compression{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if frame, err = streamer.ReadFrame(conn, streamer.DefaultMaxMessageSize); err != nil {
+		t.Fatal(err)
+	}
+	if frame.ContentType != streamer.ContentBinary {
+		t.Fatalf("expected a binary response, got %s", frame.Payload)
+	}
+
+	reader := binenc.NewReader(frame.Payload)
+	_ = reader.ReadString()
+	status := tcpRouter.ResponseStatus(reader.ReadUvarint())
+	var batch dto.BatchResponse
+	if err = batch.UnmarshalBinary(reader.ReadBytes()); err != nil || status != tcpRouter.StatusOK {
+		t.Fatalf("expected status OK, got %v: %v", status, err)
+	}
+
+	expected := [][]string{{first}, {first, second}}
+	if len(batch.Responses) != len(expected) {
+		t.Fatalf("expected %v responses, got %v", len(expected), len(batch.Responses))
+	}
+	for idx, item := range batch.Responses {
+		var result dto.SearchResponse
+		if err = result.UnmarshalBinary(item.Body.([]byte)); err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(result.Files)
+		if !slices.Equal(result.Files, expected[idx]) {
+			t.Errorf("%v: expected %v, got %v", queries[idx], expected[idx], result.Files)
+		}
+	}
+}
+
+func TestLargeBatchQueuesFewTasks(t *testing.T) {
+	_, socket := startServer(t, t.TempDir(), 2)
+
+	items := make([]dto.BatchItem, 100)
+	for idx := range items {
+		items[idx] = dto.BatchItem{Method: "GET", Path: "/health"}
+	}
+
+	response := fetch(t, socket, tcpRouter.POST, "/batch", dto.BatchRequest{Requests: items})
+	if response.Status != tcpRouter.StatusOK {
+		t.Fatalf("expected status OK, got %v: %v", response.Status, response.Body)
+	}
+
+	// The batch itself and at most BatchParallelism tasks went through the
+	// pool, the stats request is still running.
+	response = fetch(t, socket, tcpRouter.GET, "/admin/stats", nil)
+	raw, _ := json.Marshal(response.Body)
+	var stats dto.PoolStatsResponse
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if tasks := stats.Completed + stats.Failed + stats.Skipped + int64(stats.Queued); tasks > 1+handlers.BatchParallelism {
+		t.Errorf("expected at most %v pool tasks for the batch, got %+v", 1+handlers.BatchParallelism, stats)
+	}
+}
+
+func TestIndexJobs(t *testing.T) {
+	dir := t.TempDir()
+	indexed := writeFile(t, dir, "indexed.txt", "parallel")
+	for i := range 30 {
+		writeFile(t, dir, fmt.Sprintf("file-%v.txt", i), fmt.Sprintf("parallel document %v", i))
+	}
+	writeFile(t, dir, "notes.md", "parallel notes")
+
+	_, socket := startServer(t, dir, 2, indexed)
+
+	response := fetch(t, socket, tcpRouter.POST, "/index/jobs", dto.CreateJobRequest{Dir: dir, Glob: "*.txt"})
+	if response.Status != tcpRouter.StatusCreated {
+		t.Fatalf("create job: expected status Created, got %v %v", response.Status, response.Body)
+	}
+	id := response.Body.(map[string]any)["id"].(string)
+
+	var status dto.JobStatusResponse
+	deadline := time.Now().Add(2 * time.Second)
+	for status.State != "completed" && time.Now().Before(deadline) {
+		response = fetch(t, socket, tcpRouter.GET, "/index/jobs/"+id, nil)
+		raw, _ := json.Marshal(response.Body)
+		if err := json.Unmarshal(raw, &status); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status.State != "completed" || status.Total != 31 || status.Done != 30 || status.Skipped != 1 {
+		t.Errorf("expected 30 files done and 1 skipped, got %+v", status)
+	}
+
+	if files := search(t, socket, "parallel"); len(files) != 31 {
+		t.Errorf("expected 31 indexed files, got %v", len(files))
+	}
+
+	if response = fetch(t, socket, tcpRouter.DELETE, "/index/jobs/"+id, nil); response.Status != tcpRouter.StatusConflict {
+		t.Errorf("cancel finished job: expected status Conflict, got %v", response.Status)
+	}
+
+	if response = fetch(t, socket, tcpRouter.GET, "/index/jobs/missing", nil); response.Status != tcpRouter.StatusNotFound {
+		t.Errorf("missing job: expected status Not Found, got %v", response.Status)
+	}
+
+	response = fetch(t, socket, tcpRouter.POST, "/index/jobs", dto.CreateJobRequest{Dir: dir, Paths: []string{indexed}})
+	if response.Status != tcpRouter.StatusBadRequest {
+		t.Errorf("paths and dir: expected status Bad Request, got %v", response.Status)
+	}
+}
+
+func TestUploadInChunks(t *testing.T) {
+	dir := t.TempDir()
+	_, socket := startServer(t, dir, 2)
+
+	response := fetch(t, socket, tcpRouter.POST, "/index/upload", dto.UploadRequest{
+		Name:    "notes.txt",
+		Content: "parallel ",
+	})
+	if response.Status != tcpRouter.StatusOK {
+		t.Fatalf("first chunk: expected status OK, got %v %v", response.Status, response.Body)
+	}
+	uploadId := response.Body.(map[string]any)["uploadId"].(string)
+
+	response = fetch(t, socket, tcpRouter.POST, "/index/upload", dto.UploadRequest{
+		UploadId: uploadId,
+		Content:  "out of order",
+		Offset:   3,
+	})
+	if response.Status != tcpRouter.StatusConflict {
+		t.Errorf("wrong offset: expected status Conflict, got %v", response.Status)
+	}
+
+	response = fetch(t, socket, tcpRouter.POST, "/index/upload", dto.UploadRequest{
+		UploadId: uploadId,
+		Content:  "uploaded notes",
+		Offset:   9,
+		Final:    true,
+	})
+	if response.Status != tcpRouter.StatusCreated {
+		t.Fatalf("final chunk: expected status Created, got %v %v", response.Status, response.Body)
+	}
+
+	filePath := filepath.Join(dir, "uploads", "notes.txt")
+	if files := search(t, socket, "uploaded"); len(files) != 1 || files[0] != filePath {
+		t.Errorf("expected %v to be indexed, got %v", filePath, files)
+	}
+
+	response = fetch(t, socket, tcpRouter.POST, "/index/upload", dto.UploadRequest{
+		Name:    "notes.txt",
+		Content: "replaced",
+		Final:   true,
+	})
+	if response.Status != tcpRouter.StatusConflict {
+		t.Errorf("duplicate: expected status Conflict, got %v", response.Status)
+	}
+
+	response = fetch(t, socket, tcpRouter.POST, "/index/upload", dto.UploadRequest{
+		Name:    "../escape.txt",
+		Content: "parallel",
+		Final:   true,
+	})
+	if response.Status != tcpRouter.StatusBadRequest {
+		t.Errorf("invalid name: expected status Bad Request, got %v", response.Status)
+	}
+}
+
+func TestDocumentsWithoutFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "file.txt", "parallel computing course")
+	_, socket := startServer(t, dir, 2, file)
+
+	response := fetch(t, socket, tcpRouter.POST, "/index/documents", dto.AddDocumentRequest{
+		Id:       "review-1",
+		Text:     "parallel pipelines never touch the disk",
+		Metadata: map[string]string{"source": "pipeline"},
+	})
+	if response.Status != tcpRouter.StatusCreated {
+		t.Fatalf("add document: expected status Created, got %v %v", response.Status, response.Body)
+	}
+
+	path := invertedIdx.DocumentPath("review-1")
+	if files := search(t, socket, "pipelines disk"); len(files) != 1 || files[0] != path {
+		t.Errorf("expected %v to be found, got %v", path, files)
+	}
+	if files := search(t, socket, "parallel"); len(files) != 2 {
+		t.Errorf("expected the file and the document, got %v", files)
+	}
+
+	response = fetch(t, socket, tcpRouter.GET, "/index/file", dto.GetFileRequest{FileName: path})
+	if response.Status != tcpRouter.StatusOK {
+		t.Errorf("document content: expected status OK, got %v", response.Status)
+	}
+
+	response = fetch(t, socket, tcpRouter.POST, "/index/documents", dto.AddDocumentRequest{Id: "review-1", Text: "other"})
+	if response.Status != tcpRouter.StatusConflict {
+		t.Errorf("duplicate: expected status Conflict, got %v", response.Status)
+	}
+
+	response = fetch(t, socket, tcpRouter.POST, "/index/documents", dto.AddDocumentRequest{
+		Id:        "review-1",
+		Text:      "replaced streaming text",
+		Overwrite: true,
+	})
+	if response.Status != tcpRouter.StatusCreated {
+		t.Errorf("overwrite: expected status Created, got %v %v", response.Status, response.Body)
+	}
+	if files := search(t, socket, "pipelines"); len(files) != 0 {
+		t.Errorf("words of the replaced text should be gone, got %v", files)
+	}
+
+	response = fetch(t, socket, tcpRouter.GET, "/index/documents/review-1", nil)
+	raw, _ := json.Marshal(response.Body)
+	var doc dto.DocumentResponse
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Text != "replaced streaming text" || doc.Path != path || doc.Metadata != nil {
+		t.Errorf("unexpected document %+v", doc)
+	}
+
+	if response = fetch(t, socket, tcpRouter.DELETE, "/index/documents/review-1", nil); response.Status != tcpRouter.StatusNoContent {
+		t.Errorf("remove: expected status No Content, got %v", response.Status)
+	}
+	if files := search(t, socket, "streaming"); len(files) != 0 {
+		t.Errorf("removed document should not be found, got %v", files)
+	}
+	if response = fetch(t, socket, tcpRouter.GET, "/index/documents/review-1", nil); response.Status != tcpRouter.StatusNotFound {
+		t.Errorf("removed document: expected status Not Found, got %v", response.Status)
+	}
+
+	response = fetch(t, socket, tcpRouter.POST, "/index/documents", dto.AddDocumentRequest{Id: "with space"})
+	if response.Status != tcpRouter.StatusBadRequest {
+		t.Errorf("invalid id: expected status Bad Request, got %v", response.Status)
+	}
+}
+
+func workers(t *testing.T, response tcpRouter.Response) dto.WorkersResponse {
+	t.Helper()
+
+	raw, _ := json.Marshal(response.Body)
+	var size dto.WorkersResponse
+	if err := json.Unmarshal(raw, &size); err != nil {
+		t.Fatal(err)
+	}
+
+	return size
+}
+
+func TestAdminRoutesAreOptIn(t *testing.T) {
+	router := MustInitRouter(
+		handlers.NewInvertedIndex(nil, logs),
+		handlers.NewIndexJobs(nil, logs),
+		handlers.NewUpload(nil, logs),
+		handlers.NewDocuments(nil, logs),
+		handlers.NewEvents(nil, handlers.MaxSubscribers, logs),
+		handlers.NewBatch(nil, handlers.MaxBatchSize, handlers.BatchParallelism, logs),
+		nil,
+		logs,
+	)
+
+	request := &tcpRouter.Request{RequestMeta: tcpRouter.RequestMeta{Path: "/admin/stats", Method: tcpRouter.GET}}
+	recorder := tcpRouter.NewResponseRecorder(nil)
+	_ = router.Handle(context.Background(), request, recorder)
+	if response := recorder.Response(); response == nil || response.Status != tcpRouter.StatusNotFound {
+		t.Errorf("expected admin routes to be missing unless enabled, got %+v", response)
+	}
+}
+
+func TestAdminResizesWorkers(t *testing.T) {
+	_, socket := startServer(t, t.TempDir(), 2)
+
+	response := fetch(t, socket, tcpRouter.GET, "/admin/workers", nil)
+	if size := workers(t, response); response.Status != tcpRouter.StatusOK || size.Target != 2 {
+		t.Errorf("expected 2 workers, got %v %+v", response.Status, size)
+	}
+
+	response = fetch(t, socket, tcpRouter.POST, "/admin/workers", dto.ResizeWorkersRequest{Workers: 5})
+	if size := workers(t, response); response.Status != tcpRouter.StatusOK || size.Target != 5 || size.Workers != 5 {
+		t.Errorf("expected 5 workers, got %v %+v", response.Status, size)
+	}
+
+	response = fetch(t, socket, tcpRouter.POST, "/admin/workers", dto.ResizeWorkersRequest{Workers: 0})
+	if response.Status != tcpRouter.StatusBadRequest {
+		t.Errorf("expected status Bad Request, got %v", response.Status)
+	}
+}
+
+func TestAdminStats(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "file.txt", "parallel computing course")
+	_, socket := startServer(t, dir, 2, file)
+
+	for range 3 {
+		search(t, socket, "parallel")
+	}
+
+	response := fetch(t, socket, tcpRouter.GET, "/admin/stats", nil)
+	raw, _ := json.Marshal(response.Body)
+	var stats dto.PoolStatsResponse
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Status != tcpRouter.StatusOK || stats.Workers != 2 || stats.Completed < 3 {
+		t.Errorf("expected the searches to be counted on 2 workers, got %v %+v", response.Status, stats)
+	}
+	if stats.RunTime.Count < 3 || stats.QueueWait.Count < 3 || stats.RunTime.MaxUs < stats.RunTime.P50Us {
+		t.Errorf("expected the durations of the searches, got %+v", stats)
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	docStore "server/internal/infrastructure/doc_store"
+	invertedIdx "server/internal/infrastructure/inverted_idx"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var ErrInvalidDocumentId = errors.New("invalid document id")
+
+type DocumentStore interface {
+	Put(doc docStore.Document, replace bool) error
+	Get(id string) (docStore.Document, error)
+	Delete(id string) error
+}
+
+type DocumentIndex interface {
+	AddFile(filePath string) error
+	RemoveFile(filePath string) error
+	HasFileProcessed(filePath string) bool
+}
+
+// Documents indexes text sent in requests. The text is kept in the
+// document store, which the index reads it back from, and indexed under
+// invertedIdx.DocumentPath of its id.
+type Documents struct {
+	store  DocumentStore
+	index  DocumentIndex
+	lock   sync.Mutex
+	logger Logger
+}
+
+func NewDocuments(store DocumentStore, index DocumentIndex, logger Logger) *Documents {
+	return &Documents{
+		store:  store,
+		index:  index,
+		logger: logger,
+	}
+}
+
+// Add stores and indexes doc and returns the path it is indexed under.
+// A document with the same id is only replaced with overwrite.
+func (documents *Documents) Add(doc docStore.Document, overwrite bool) (string, error) {
+	if err := validateDocumentId(doc.Id); err != nil {
+		return "", err
+	}
+
+	documents.lock.Lock()
+	defer documents.lock.Unlock()
+
+	path := invertedIdx.DocumentPath(doc.Id)
+
+	// The previous document is restored when the new one cannot be
+	// indexed, an overwrite that fails must not lose it.
+	var previous *docStore.Document
+	if overwrite {
+		if old, err := documents.store.Get(doc.Id); err == nil {
+			previous = &old
+		}
+	}
+
+	// The index reads the text it removes from the store,
+	// so the old text is removed before it is replaced.
+	wasIndexed := previous != nil && documents.index.HasFileProcessed(path)
+	if wasIndexed {
+		if err := documents.index.RemoveFile(path); err != nil {
+			return "", err
+		}
+	}
+
+	if err := documents.store.Put(doc, overwrite); err != nil {
+		documents.restore(previous, wasIndexed)
+		return "", err
+	}
+
+	if err := documents.index.AddFile(path); err != nil {
+		if previous != nil {
+			_ = documents.store.Put(*previous, true)
+			documents.restore(previous, wasIndexed)
+		} else {
+			_ = documents.store.Delete(doc.Id)
+		}
+		return "", err
+	}
+
+	msg := fmt.Sprintf("document %v indexed, %v bytes", path, len(doc.Text))
+	documents.logger.Log(msg)
+	return path, nil
+}
+
+// restore indexes the previous document again once it is back in the store.
+func (documents *Documents) restore(previous *docStore.Document, wasIndexed bool) {
+	if previous == nil || !wasIndexed {
+		return
+	}
+
+	path := invertedIdx.DocumentPath(previous.Id)
+	if err := documents.index.AddFile(path); err != nil {
+		msg := fmt.Sprintf("document %v could not be indexed again: %v", path, err)
+		documents.logger.Log(msg)
+	}
+}
+
+func (documents *Documents) Get(id string) (docStore.Document, error) {
+	return documents.store.Get(id)
+}
+
+// Remove removes the document from the index and the store.
+func (documents *Documents) Remove(id string) error {
+	documents.lock.Lock()
+	defer documents.lock.Unlock()
+
+	path := invertedIdx.DocumentPath(id)
+	if documents.index.HasFileProcessed(path) {
+		if err := documents.index.RemoveFile(path); err != nil {
+			return err
+		}
+	}
+
+	return documents.store.Delete(id)
+}
+
+// validateDocumentId accepts ids that fit in a single route path segment.
+func validateDocumentId(id string) error {
+	if id == "" || len(id) > maxDocumentName || strings.ContainsRune(id, '/') ||
+		strings.IndexFunc(id, unicode.IsSpace) >= 0 || strings.IndexFunc(id, unicode.IsControl) >= 0 {
+		return ErrInvalidDocumentId
+	}
+
+	return nil
+}
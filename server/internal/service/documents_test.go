@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	docStore "server/internal/infrastructure/doc_store"
+	invertedIdx "server/internal/infrastructure/inverted_idx"
+	"testing"
+)
+
+var errIndexFull = errors.New("index is full")
+
+// failingIndex fails the next AddFile once failNext is set.
+type failingIndex struct {
+	indexStub
+	failNext bool
+}
+
+func (index *failingIndex) AddFile(filePath string) error {
+	if index.failNext {
+		index.failNext = false
+		return errIndexFull
+	}
+
+	return index.indexStub.AddFile(filePath)
+}
+
+func TestDocumentsOverwriteKeepsPreviousOnIndexFailure(t *testing.T) {
+	store := docStore.New()
+	index := &failingIndex{indexStub: indexStub{indexed: map[string]bool{}}}
+	documents := NewDocuments(store, index, logs)
+
+	path, err := documents.Add(docStore.Document{Id: "review", Text: "first version"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index.failNext = true
+	if _, err = documents.Add(docStore.Document{Id: "review", Text: "second version"}, true); !errors.Is(err, errIndexFull) {
+		t.Fatalf("expected the index error, got %v", err)
+	}
+
+	doc, err := store.Get("review")
+	if err != nil || doc.Text != "first version" {
+		t.Errorf("expected the previous document to be kept, got %+v, %v", doc, err)
+	}
+	if !index.HasFileProcessed(path) {
+		t.Error("expected the previous document to be indexed again")
+	}
+}
+
+func TestDocumentsAddDropsNewDocumentOnIndexFailure(t *testing.T) {
+	store := docStore.New()
+	index := &failingIndex{indexStub: indexStub{indexed: map[string]bool{}}, failNext: true}
+	documents := NewDocuments(store, index, logs)
+
+	if _, err := documents.Add(docStore.Document{Id: "review", Text: "text"}, true); !errors.Is(err, errIndexFull) {
+		t.Fatalf("expected the index error, got %v", err)
+	}
+
+	if _, err := store.Get("review"); !errors.Is(err, docStore.ErrNotFound) {
+		t.Errorf("expected the document to be dropped, got %v", err)
+	}
+	if index.HasFileProcessed(invertedIdx.DocumentPath("review")) {
+		t.Error("expected the document not to be indexed")
+	}
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	eventBus "server/internal/infrastructure/event_bus"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job is already finished")
+	ErrNoJobFiles  = errors.New("job has no files to index")
+)
+
+type JobState string
+
+const (
+	JobRunning   JobState = "running"
+	JobCompleted JobState = "completed"
+	JobCancelled JobState = "cancelled"
+	JobFailed    JobState = "failed"
+)
+
+const (
+	// JobParallelism is how many files of one job are indexed at once.
+	JobParallelism = 4
+	// MaxJobErrors bounds the errors kept per job, the rest are only counted.
+	MaxJobErrors = 20
+	// MaxFinishedJobs is how many finished jobs are kept for status requests.
+	MaxFinishedJobs = 100
+	// JobRetryDelay is how long a job waits to queue its next file again
+	// when the queue of the pool is full.
+	JobRetryDelay = 100 * time.Millisecond
+)
+
+type Executor interface {
+	AddTask(task *threadpool.Task) error
+}
+
+type JobStatus struct {
+	Id    string
+	State JobState
+	Total int
+	// Done, Skipped and Failed count the processed files, Skipped are the
+	// ones that were indexed already.
+	Done    int
+	Skipped int
+	Failed  int
+	// Eta is the estimated time left, zero until the first file is processed.
+	Eta        time.Duration
+	Errors     []string
+	CreatedAt  time.Time
+	FinishedAt time.Time
+}
+
+// IndexJobs indexes batches of files in the background. Every file is a
+// separate thread pool task and a job never has more than its parallelism
+// of them queued or running, so requests keep being served meanwhile.
+type IndexJobs struct {
+	invertedIdx InvertedIndex
+	fileManager FileManager
+	executor    Executor
+	parallelism int
+	jobs        map[string]*indexJob
+	finished    []string
+	lock        sync.Mutex
+	jobIds      atomic.Int64
+	taskIds     atomic.Int64
+	events      EventPublisher
+	logger      Logger
+}
+
+func NewIndexJobs(
+	invertedIdx InvertedIndex,
+	fileManager FileManager,
+	executor Executor,
+	parallelism int,
+	logger Logger,
+) *IndexJobs {
+	if parallelism <= 0 {
+		parallelism = JobParallelism
+	}
+
+	return &IndexJobs{
+		invertedIdx: invertedIdx,
+		fileManager: fileManager,
+		executor:    executor,
+		parallelism: parallelism,
+		jobs:        make(map[string]*indexJob),
+		events:      noopPublisher{},
+		logger:      logger,
+	}
+}
+
+// SetEventPublisher makes jobs publish their progress to events.
+func (jobs *IndexJobs) SetEventPublisher(events EventPublisher) {
+	jobs.events = events
+}
+
+// Start indexes files and returns the id of the job.
+func (jobs *IndexJobs) Start(files []string) (string, error) {
+	if len(files) == 0 {
+		return "", ErrNoJobFiles
+	}
+
+	id := strconv.FormatInt(jobs.jobIds.Add(1), 10)
+	job := &indexJob{
+		id:        id,
+		files:     files,
+		state:     JobRunning,
+		workers:   min(jobs.parallelism, len(files)),
+		createdAt: time.Now(),
+		tracker:   eventBus.NewBuildTracker(jobs.events, "job-"+id, len(files)),
+	}
+
+	jobs.lock.Lock()
+	jobs.jobs[id] = job
+	jobs.lock.Unlock()
+
+	msg := fmt.Sprintf("index job [%v] started with %v files", id, len(files))
+	jobs.logger.Log(msg)
+
+	for range job.workers {
+		jobs.schedule(job)
+	}
+
+	return id, nil
+}
+
+// StartDir indexes the files under dir, including subdirectories, whose
+// names match glob, e.g. *.txt. Hidden files are left out.
+func (jobs *IndexJobs) StartDir(dir string, glob string) (string, error) {
+	if _, err := filepath.Match(glob, ""); err != nil {
+		return "", err
+	}
+
+	files, err := jobs.fileManager.GetFilesWithCond(dir, func(filePath string) bool {
+		matched, _ := filepath.Match(glob, filepath.Base(filePath))
+		return matched && !isHidden(filePath)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return jobs.Start(files)
+}
+
+func (jobs *IndexJobs) Status(id string) (JobStatus, error) {
+	job, err := jobs.get(id)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	return job.status(), nil
+}
+
+// Cancel stops a running job. Files being indexed at the moment are
+// finished, the rest are left out.
+func (jobs *IndexJobs) Cancel(id string) error {
+	job, err := jobs.get(id)
+	if err != nil {
+		return err
+	}
+
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	if job.state != JobRunning {
+		return ErrJobFinished
+	}
+
+	job.state = JobCancelled
+	return nil
+}
+
+func (jobs *IndexJobs) get(id string) (*indexJob, error) {
+	jobs.lock.Lock()
+	defer jobs.lock.Unlock()
+
+	job, ok := jobs.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	return job, nil
+}
+
+// schedule queues the next file of job. A full queue only delays the job,
+// a worker that cannot be queued or is dropped from the queue for any other
+// reason, e.g. because the server shuts down, fails the job.
+func (jobs *IndexJobs) schedule(job *indexJob) {
+	task := threadpool.NewTask(jobs.taskIds.Add(1), func(ctx context.Context) error {
+		jobs.step(job)
+		return nil
+	})
+
+	// Jobs run in the background, requests go first.
+	_ = task.SetPriority(threadpool.PriorityLow)
+	task.SetType("index job")
+	task.SetOnSkip(func(err error) {
+		if errors.Is(err, threadpool.ErrQueueFull) {
+			jobs.retry(job)
+			return
+		}
+		job.fail(fmt.Errorf("indexing was stopped: %w", err))
+		jobs.workerDone(job)
+	})
+
+	err := jobs.executor.AddTask(task)
+	if errors.Is(err, threadpool.ErrQueueFull) {
+		jobs.retry(job)
+		return
+	}
+
+	if err != nil {
+		job.fail(fmt.Errorf("could not schedule indexing: %w", err))
+		jobs.workerDone(job)
+	}
+}
+
+func (jobs *IndexJobs) retry(job *indexJob) {
+	time.AfterFunc(JobRetryDelay, func() {
+		jobs.schedule(job)
+	})
+}
+
+func (jobs *IndexJobs) step(job *indexJob) {
+	idx := int(job.next.Add(1) - 1)
+	if idx >= len(job.files) || !job.running() {
+		jobs.workerDone(job)
+		return
+	}
+
+	filePath := job.files[idx]
+	if jobs.invertedIdx.HasFileProcessed(filePath) {
+		job.fileSkipped()
+	} else {
+		// A file that makes indexing panic only fails itself, the job goes on.
+		err := threadpool.CatchPanic(func() error {
+			return jobs.invertedIdx.AddFile(filePath)
+		})
+		job.fileDone(filePath, err)
+	}
+
+	jobs.schedule(job)
+}
+
+func (jobs *IndexJobs) workerDone(job *indexJob) {
+	if !job.workerDone() {
+		return
+	}
+
+	job.tracker.Complete()
+	status := job.status()
+	msg := fmt.Sprintf("index job [%v] %v: %v done, %v skipped, %v failed",
+		job.id, status.State, status.Done, status.Skipped, status.Failed)
+	jobs.logger.Log(msg)
+
+	jobs.lock.Lock()
+	defer jobs.lock.Unlock()
+
+	jobs.finished = append(jobs.finished, job.id)
+	if len(jobs.finished) > MaxFinishedJobs {
+		delete(jobs.jobs, jobs.finished[0])
+		jobs.finished = jobs.finished[1:]
+	}
+}
+
+type indexJob struct {
+	id         string
+	files      []string
+	next       atomic.Int64
+	lock       sync.Mutex
+	state      JobState
+	workers    int
+	done       int
+	skipped    int
+	failed     int
+	errors     []string
+	createdAt  time.Time
+	finishedAt time.Time
+	tracker    *eventBus.BuildTracker
+}
+
+func (job *indexJob) running() bool {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	return job.state == JobRunning
+}
+
+func (job *indexJob) fileDone(filePath string, err error) {
+	job.tracker.FileDone(err)
+
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	if err == nil {
+		job.done++
+		return
+	}
+
+	job.failed++
+	if len(job.errors) < MaxJobErrors {
+		job.errors = append(job.errors, fmt.Sprintf("%v: %v", filePath, err))
+	}
+}
+
+func (job *indexJob) fileSkipped() {
+	job.tracker.FileDone(nil)
+
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.skipped++
+}
+
+func (job *indexJob) fail(err error) {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	if job.state != JobRunning {
+		return
+	}
+
+	job.state = JobFailed
+	job.errors = append(job.errors, err.Error())
+}
+
+// workerDone reports whether the last worker of the job is done,
+// which finishes the job.
+func (job *indexJob) workerDone() bool {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	job.workers--
+	if job.workers > 0 {
+		return false
+	}
+
+	if job.state == JobRunning {
+		job.state = JobCompleted
+	}
+	job.finishedAt = time.Now()
+	return true
+}
+
+func (job *indexJob) status() JobStatus {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	status := JobStatus{
+		Id:         job.id,
+		State:      job.state,
+		Total:      len(job.files),
+		Done:       job.done,
+		Skipped:    job.skipped,
+		Failed:     job.failed,
+		Errors:     append([]string(nil), job.errors...),
+		CreatedAt:  job.createdAt,
+		FinishedAt: job.finishedAt,
+	}
+
+	processed := job.done + job.skipped + job.failed
+	if job.state == JobRunning && processed > 0 {
+		perFile := time.Since(job.createdAt) / time.Duration(processed)
+		status.Eta = perFile * time.Duration(status.Total-processed)
+	}
+
+	return status
+}
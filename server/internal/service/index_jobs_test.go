@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/mock"
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+)
+
+var logs = mock.NewLogger()
+
+var errMissing = errors.New("missing")
+
+type indexStub struct {
+	lock    sync.Mutex
+	indexed map[string]bool
+}
+
+func (index *indexStub) AddFile(filePath string) error {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	if filePath == "missing.txt" {
+		return errMissing
+	}
+	index.indexed[filePath] = true
+	return nil
+}
+
+func (index *indexStub) RemoveFile(filePath string) error {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	delete(index.indexed, filePath)
+	return nil
+}
+
+func (index *indexStub) HasFileProcessed(filePath string) bool {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	return index.indexed[filePath]
+}
+
+// queueExecutor keeps tasks until the test runs them.
+type queueExecutor struct {
+	tasks []*threadpool.Task
+}
+
+func (executor *queueExecutor) AddTask(task *threadpool.Task) error {
+	executor.tasks = append(executor.tasks, task)
+	return nil
+}
+
+func (executor *queueExecutor) runOne() bool {
+	if len(executor.tasks) == 0 {
+		return false
+	}
+
+	task := executor.tasks[0]
+	executor.tasks = executor.tasks[1:]
+	_, _ = task.Run()
+	return true
+}
+
+func newJobsFixture(parallelism int) (*IndexJobs, *queueExecutor, *indexStub) {
+	index := &indexStub{indexed: map[string]bool{"indexed.txt": true}}
+	executor := &queueExecutor{}
+	return NewIndexJobs(index, nil, executor, parallelism, logs), executor, index
+}
+
+func TestIndexJobRunsWithBoundedParallelism(t *testing.T) {
+	jobs, executor, _ := newJobsFixture(2)
+
+	id, err := jobs.Start([]string{"a.txt", "indexed.txt", "missing.txt", "b.txt", "c.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for executor.runOne() {
+		if len(executor.tasks) > 2 {
+			t.Fatalf("expected at most 2 queued tasks, got %v", len(executor.tasks))
+		}
+	}
+
+	status, err := jobs.Status(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.State != JobCompleted || status.Done != 3 || status.Skipped != 1 || status.Failed != 1 {
+		t.Errorf("expected 3 done, 1 skipped and 1 failed, got %+v", status)
+	}
+	if len(status.Errors) != 1 || status.FinishedAt.IsZero() {
+		t.Errorf("expected the error of missing.txt and a finish time, got %+v", status)
+	}
+}
+
+func TestIndexJobCancel(t *testing.T) {
+	jobs, executor, index := newJobsFixture(1)
+
+	id, err := jobs.Start([]string{"a.txt", "b.txt", "c.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	executor.runOne()
+	if err = jobs.Cancel(id); err != nil {
+		t.Fatal(err)
+	}
+	for executor.runOne() {
+	}
+
+	status, _ := jobs.Status(id)
+	if status.State != JobCancelled || status.Done != 1 || index.HasFileProcessed("b.txt") {
+		t.Errorf("expected the job to stop after one file, got %+v", status)
+	}
+
+	if err = jobs.Cancel(id); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("expected ErrJobFinished, got %v", err)
+	}
+
+	if _, err = jobs.Status("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+
+	if _, err = jobs.Start(nil); !errors.Is(err, ErrNoJobFiles) {
+		t.Errorf("expected ErrNoJobFiles, got %v", err)
+	}
+}
+
+func TestIndexJobWaitsForRoomInFullQueue(t *testing.T) {
+	pool := threadpool.New(logs)
+	if err := pool.SetQueueCapacity(1, threadpool.PolicyReject); err != nil {
+		t.Fatal(err)
+	}
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	_ = pool.AddTask(threadpool.NewTask(0, func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	<-started
+
+	index := &indexStub{indexed: map[string]bool{}}
+	jobs := NewIndexJobs(index, nil, pool, 2, logs)
+	id, err := jobs.Start([]string{"a.txt", "b.txt", "c.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, _ := jobs.Status(id)
+		if status.State != JobRunning {
+			if status.State != JobCompleted || status.Done != 3 {
+				t.Errorf("expected the job to complete despite the full queue, got %+v", status)
+			}
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not finish, got %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	eventBus "server/internal/infrastructure/event_bus"
+	"strings"
+	"time"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+)
+
+type InvertedIndex interface {
+	AddFile(filePath string) error
+	HasFileProcessed(filePath string) bool
+}
+
+type FileManager interface {
+	GetFilesWithCond(dir string, cond func(fileName string) bool) ([]string, error)
+}
+
+type TaskScheduler interface {
+	ScheduleEvery(task *threadpool.Task, interval time.Duration) (*threadpool.ScheduledTask, error)
+}
+
+type Logger interface {
+	Log(...interface{})
+}
+
+type EventPublisher interface {
+	Publish(eventType eventBus.EventType, data any)
+}
+
+// UploadTracker reports the files an upload is still storing, the
+// scheduler leaves them to the upload that indexes them.
+type UploadTracker interface {
+	IsUploading(filePath string) bool
+}
+
+type noopPublisher struct{}
+
+func (noopPublisher) Publish(eventBus.EventType, any) {}
+
+type noUploads struct{}
+
+func (noUploads) IsUploading(string) bool { return false }
+
+type InvertedIndexScheduler struct {
+	invertedIdx InvertedIndex
+	fileManager FileManager
+	scheduler   TaskScheduler
+	logger      Logger
+	events      EventPublisher
+	uploads     UploadTracker
+}
+
+func NewSchedulerService(
+	invertedIdx InvertedIndex,
+	fileManager FileManager,
+	scheduler TaskScheduler,
+	logger Logger,
+) *InvertedIndexScheduler {
+	return &InvertedIndexScheduler{
+		invertedIdx: invertedIdx,
+		fileManager: fileManager,
+		scheduler:   scheduler,
+		logger:      logger,
+		events:      noopPublisher{},
+		uploads:     noUploads{},
+	}
+}
+
+// SetEventPublisher makes the scheduler publish the progress of every
+// batch of new files to events.
+func (iis *InvertedIndexScheduler) SetEventPublisher(events EventPublisher) {
+	iis.events = events
+}
+
+// SetUploadTracker makes scans skip the files uploads are storing, which
+// would otherwise be indexed before the upload gets to it.
+func (iis *InvertedIndexScheduler) SetUploadTracker(uploads UploadTracker) {
+	iis.uploads = uploads
+}
+
+// MonitorDir indexes new files of directory every period on the thread
+// pool. A scan that is still running when the next one is due skips it.
+// The returned task stops the monitoring once cancelled, a scan in
+// progress stops before its next file.
+func (iis *InvertedIndexScheduler) MonitorDir(directory string, period time.Duration) (*threadpool.ScheduledTask, error) {
+	task := threadpool.NewTask(0, func(ctx context.Context) error {
+		return iis.scanDir(ctx, directory)
+	})
+
+	// Scans run in the background, requests go first.
+	_ = task.SetPriority(threadpool.PriorityLow)
+	task.SetType("monitor dir")
+	return iis.scheduler.ScheduleEvery(task, period)
+}
+
+func (iis *InvertedIndexScheduler) scanDir(ctx context.Context, directory string) error {
+	// A cancelled scan still walks the directory, but takes no file.
+	files, err := iis.fileManager.GetFilesWithCond(directory, func(filePath string) bool {
+		return ctx.Err() == nil && !isHidden(filePath) && !iis.uploads.IsUploading(filePath) &&
+			!iis.invertedIdx.HasFileProcessed(filePath)
+	})
+
+	if err != nil {
+		iis.logger.Log(err)
+		return nil
+	}
+
+	addedFiles := 0
+	tracker := eventBus.NewBuildTracker(iis.events, "scheduler", len(files))
+	for _, filePath := range files {
+		if err := ctx.Err(); err != nil {
+			tracker.Complete()
+			msg := fmt.Sprintf("directory scan was stopped. added files: %v", addedFiles)
+			iis.logger.Log(msg)
+			return err
+		}
+
+		// An upload may have started storing the file since the scan.
+		if iis.uploads.IsUploading(filePath) {
+			tracker.FileDone(nil)
+			continue
+		}
+
+		err := iis.invertedIdx.AddFile(filePath)
+		tracker.FileDone(err)
+		if err != nil {
+			iis.logger.Log(err)
+		} else {
+			addedFiles++
+		}
+	}
+	if len(files) > 0 {
+		tracker.Complete()
+	}
+	msg := fmt.Sprintf("inverted index was updated successfully. added files: %v", addedFiles)
+	iis.logger.Log(msg)
+	return ctx.Err()
+}
+
+// isHidden reports whether the file name starts with a dot, such files are
+// never indexed from a directory, e.g. uploads that are still being written.
+func isHidden(filePath string) bool {
+	return strings.HasPrefix(filepath.Base(filePath), ".")
+}
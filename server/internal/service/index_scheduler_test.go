@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ArtemLymarenko/parallel-course-work/pkg/threadpool"
+)
+
+// dirStub lists a fixed set of files.
+type dirStub struct {
+	files []string
+}
+
+func (dir *dirStub) GetFilesWithCond(_ string, cond func(fileName string) bool) ([]string, error) {
+	var files []string
+	for _, file := range dir.files {
+		if cond(file) {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+func TestMonitorDirIndexesNewFiles(t *testing.T) {
+	pool := threadpool.New(logs)
+	pool.MustRun(1)
+	defer pool.MustTerminate()
+
+	scheduler := threadpool.NewScheduler(pool, logs)
+	scheduler.MustRun()
+	defer scheduler.MustTerminate()
+
+	index := &indexStub{indexed: map[string]bool{"indexed.txt": true}}
+	dir := &dirStub{files: []string{"indexed.txt", "new.txt", ".partial.txt"}}
+	monitor := NewSchedulerService(index, dir, scheduler, logs)
+
+	scheduled, err := monitor.MonitorDir("data", 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scheduled.Cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for !index.HasFileProcessed("new.txt") {
+		if time.Now().After(deadline) {
+			t.Fatal("new file was not indexed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if index.HasFileProcessed(".partial.txt") {
+		t.Error("hidden file should not be indexed")
+	}
+}
+
+// cancellingIndex cancels the scan once it indexed a file.
+type cancellingIndex struct {
+	indexStub
+	cancel context.CancelFunc
+}
+
+func (index *cancellingIndex) AddFile(filePath string) error {
+	defer index.cancel()
+	return index.indexStub.AddFile(filePath)
+}
+
+func TestScanDirStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	index := &cancellingIndex{indexStub: indexStub{indexed: map[string]bool{}}, cancel: cancel}
+	dir := &dirStub{files: []string{"first.txt", "second.txt", "third.txt"}}
+	monitor := NewSchedulerService(index, dir, nil, logs)
+
+	if err := monitor.scanDir(ctx, "data"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if len(index.indexed) != 1 {
+		t.Errorf("expected the scan to stop after the first file, got %v", index.indexed)
+	}
+}
@@ -0,0 +1,386 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidDocumentName = errors.New("invalid document name")
+	ErrDocumentExists      = errors.New("document already exists")
+	ErrUploadNotFound      = errors.New("upload not found or expired")
+	ErrUploadOffset        = errors.New("chunk offset does not match the uploaded size")
+	ErrUploadTooLarge      = errors.New("upload is too large")
+	ErrTooManyUploads      = errors.New("too many uploads in progress")
+	ErrUploadsTooLarge     = errors.New("uploads in progress are too large")
+)
+
+const (
+	// MaxUploadSize bounds the size of one uploaded document, in bytes.
+	MaxUploadSize = 64 << 20
+	// MaxUploads bounds the number of unfinished uploads.
+	MaxUploads = 32
+	// MaxPendingSize bounds the bytes written by all unfinished uploads.
+	MaxPendingSize = 512 << 20
+	// UploadTimeout is how long an unfinished upload waits for its next chunk.
+	UploadTimeout = 5 * time.Minute
+	// maxDocumentName is the longest name most file systems accept.
+	maxDocumentName = 255
+
+	uploadPrefix = ".upload-"
+)
+
+type IngestIndex interface {
+	AddFile(filePath string) error
+	RemoveFile(filePath string) error
+	HasFileProcessed(filePath string) bool
+}
+
+// Chunk is a piece of an uploaded document. The first chunk has no
+// UploadId, the following ones continue the upload it returned at Offset.
+type Chunk struct {
+	UploadId  string
+	Name      string
+	Content   []byte
+	Offset    int64
+	Final     bool
+	Overwrite bool
+}
+
+// UploadResult tells which upload to continue, or, once the final chunk
+// is written, where the document was stored.
+type UploadResult struct {
+	UploadId string
+	Size     int64
+	FilePath string
+}
+
+// Ingest stores uploaded documents in a directory inside the index root
+// and indexes them. Chunks are written to a hidden temporary file which
+// is moved to its name in one step after the last chunk, so the index and
+// the scheduler never see a half written document. Documents that are
+// being moved and indexed are reported by IsUploading, so the scheduler
+// leaves them to the upload.
+type Ingest struct {
+	dir            string
+	index          IngestIndex
+	maxSize        int64
+	maxUploads     int
+	maxPendingSize int64
+	pendingSize    int64
+	uploads        map[string]*upload
+	committing     map[string]int
+	lock           sync.Mutex
+	logger         Logger
+}
+
+type upload struct {
+	id        string
+	name      string
+	overwrite bool
+	tempPath  string
+	size      int64
+	touchedAt time.Time
+	lock      sync.Mutex
+}
+
+func NewIngest(dir string, index IngestIndex, maxSize int64, logger Logger) (*Ingest, error) {
+	if maxSize <= 0 {
+		maxSize = MaxUploadSize
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// Temporary files of uploads cut short by a restart are never finished.
+	stale, _ := filepath.Glob(filepath.Join(dir, uploadPrefix+"*"))
+	for _, tempPath := range stale {
+		_ = os.Remove(tempPath)
+	}
+
+	return &Ingest{
+		dir:            dir,
+		index:          index,
+		maxSize:        maxSize,
+		maxUploads:     MaxUploads,
+		maxPendingSize: MaxPendingSize,
+		uploads:        make(map[string]*upload),
+		committing:     make(map[string]int),
+		logger:         logger,
+	}, nil
+}
+
+// Upload writes a chunk. The document is stored and indexed with the final
+// one, an existing document with the same name is only replaced when the
+// first chunk asked to overwrite it.
+func (ingest *Ingest) Upload(chunk Chunk) (UploadResult, error) {
+	ingest.expireUploads()
+
+	current, err := ingest.upload(chunk)
+	if err != nil {
+		return UploadResult{}, err
+	}
+
+	current.lock.Lock()
+	defer current.lock.Unlock()
+
+	if err = ingest.write(current, chunk); err != nil {
+		return UploadResult{}, err
+	}
+
+	result := UploadResult{
+		UploadId: current.id,
+		Size:     current.size,
+	}
+
+	if !chunk.Final {
+		return result, nil
+	}
+
+	ingest.forget(current.id)
+	result.FilePath, err = ingest.commit(current)
+	return result, err
+}
+
+// upload returns the upload chunk continues, or starts a new one.
+func (ingest *Ingest) upload(chunk Chunk) (*upload, error) {
+	if chunk.UploadId != "" {
+		ingest.lock.Lock()
+		defer ingest.lock.Unlock()
+
+		current, ok := ingest.uploads[chunk.UploadId]
+		if !ok {
+			return nil, ErrUploadNotFound
+		}
+		return current, nil
+	}
+
+	if err := validateDocumentName(chunk.Name); err != nil {
+		return nil, err
+	}
+
+	if !chunk.Overwrite {
+		if _, err := os.Lstat(filepath.Join(ingest.dir, chunk.Name)); err == nil {
+			return nil, ErrDocumentExists
+		}
+	}
+
+	temp, err := os.CreateTemp(ingest.dir, uploadPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	_ = temp.Close()
+
+	id, err := newUploadId()
+	if err != nil {
+		_ = os.Remove(temp.Name())
+		return nil, err
+	}
+
+	current := &upload{
+		id:        id,
+		name:      chunk.Name,
+		overwrite: chunk.Overwrite,
+		tempPath:  temp.Name(),
+		touchedAt: time.Now(),
+	}
+
+	ingest.lock.Lock()
+	defer ingest.lock.Unlock()
+
+	if len(ingest.uploads) >= ingest.maxUploads {
+		_ = os.Remove(current.tempPath)
+		return nil, ErrTooManyUploads
+	}
+	ingest.uploads[id] = current
+
+	return current, nil
+}
+
+func (ingest *Ingest) write(current *upload, chunk Chunk) error {
+	if chunk.Offset != current.size {
+		return fmt.Errorf("%w: expected %v, got %v", ErrUploadOffset, current.size, chunk.Offset)
+	}
+
+	if current.size+int64(len(chunk.Content)) > ingest.maxSize {
+		ingest.forget(current.id)
+		_ = os.Remove(current.tempPath)
+		return ErrUploadTooLarge
+	}
+
+	// The chunk is refused, not the upload, it can be sent again once
+	// other uploads are finished.
+	if err := ingest.reserve(int64(len(chunk.Content))); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(current.tempPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		ingest.release(int64(len(chunk.Content)))
+		return err
+	}
+	defer file.Close()
+
+	n, err := file.Write(chunk.Content)
+	ingest.release(int64(len(chunk.Content) - n))
+	current.size += int64(n)
+	current.touchedAt = time.Now()
+	if err != nil {
+		return err
+	}
+
+	if chunk.Final {
+		return file.Sync()
+	}
+
+	return nil
+}
+
+// commit moves the finished upload to its name and indexes it. Without
+// overwrite the document is linked to its name, which fails instead of
+// replacing a document stored by a concurrent upload in the meantime.
+func (ingest *Ingest) commit(current *upload) (string, error) {
+	defer os.Remove(current.tempPath)
+
+	filePath := filepath.Join(ingest.dir, current.name)
+	ingest.markCommitting(filePath, 1)
+	defer ingest.markCommitting(filePath, -1)
+
+	if current.overwrite {
+		if err := ingest.replace(current.tempPath, filePath); err != nil {
+			return "", err
+		}
+	} else if err := os.Link(current.tempPath, filePath); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return "", ErrDocumentExists
+		}
+		return "", err
+	}
+
+	if err := ingest.index.AddFile(filePath); err != nil {
+		return "", err
+	}
+
+	msg := fmt.Sprintf("document %v uploaded, %v bytes", filePath, current.size)
+	ingest.logger.Log(msg)
+	return filePath, nil
+}
+
+// replace moves the upload over the document at filePath. The index reads
+// the file it removes, so the old content is removed before it is replaced
+// and indexed again if the document stays.
+func (ingest *Ingest) replace(tempPath, filePath string) error {
+	indexed := ingest.index.HasFileProcessed(filePath)
+	if indexed {
+		if err := ingest.index.RemoveFile(filePath); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tempPath, filePath); err != nil {
+		if indexed {
+			if restoreErr := ingest.index.AddFile(filePath); restoreErr != nil {
+				msg := fmt.Sprintf("could not index %v again: %v", filePath, restoreErr)
+				ingest.logger.Log(msg)
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// IsUploading reports whether an upload is moving the document at filePath
+// to its name or indexing it.
+func (ingest *Ingest) IsUploading(filePath string) bool {
+	ingest.lock.Lock()
+	defer ingest.lock.Unlock()
+	return ingest.committing[filepath.Clean(filePath)] > 0
+}
+
+func (ingest *Ingest) markCommitting(filePath string, delta int) {
+	ingest.lock.Lock()
+	defer ingest.lock.Unlock()
+
+	filePath = filepath.Clean(filePath)
+	ingest.committing[filePath] += delta
+	if ingest.committing[filePath] <= 0 {
+		delete(ingest.committing, filePath)
+	}
+}
+
+// reserve counts size against the bytes all unfinished uploads may write.
+func (ingest *Ingest) reserve(size int64) error {
+	ingest.lock.Lock()
+	defer ingest.lock.Unlock()
+
+	if ingest.pendingSize+size > ingest.maxPendingSize {
+		return ErrUploadsTooLarge
+	}
+	ingest.pendingSize += size
+	return nil
+}
+
+func (ingest *Ingest) release(size int64) {
+	ingest.lock.Lock()
+	defer ingest.lock.Unlock()
+	ingest.pendingSize -= size
+}
+
+// forget drops the upload and the bytes it counted against the pending
+// size, its lock has to be held.
+func (ingest *Ingest) forget(uploadId string) {
+	ingest.lock.Lock()
+	defer ingest.lock.Unlock()
+
+	if current, ok := ingest.uploads[uploadId]; ok {
+		ingest.pendingSize -= current.size
+		delete(ingest.uploads, uploadId)
+	}
+}
+
+// expireUploads drops uploads that did not get a chunk for UploadTimeout.
+func (ingest *Ingest) expireUploads() {
+	ingest.lock.Lock()
+	defer ingest.lock.Unlock()
+
+	for id, current := range ingest.uploads {
+		if !current.lock.TryLock() {
+			continue
+		}
+
+		if time.Since(current.touchedAt) > UploadTimeout {
+			ingest.pendingSize -= current.size
+			delete(ingest.uploads, id)
+			_ = os.Remove(current.tempPath)
+		}
+		current.lock.Unlock()
+	}
+}
+
+func validateDocumentName(name string) error {
+	if name == "" || len(name) > maxDocumentName || strings.HasPrefix(name, ".") ||
+		strings.ContainsAny(name, `/\`) || name != filepath.Base(name) {
+		return ErrInvalidDocumentName
+	}
+
+	return nil
+}
+
+func newUploadId() (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	fileManager "server/internal/infrastructure/file_manager"
+	invertedIdx "server/internal/infrastructure/inverted_idx"
+	"slices"
+	"testing"
+)
+
+func newIngestFixture(t *testing.T, maxSize int64) (*Ingest, *indexStub, string) {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "uploads")
+	index := &indexStub{indexed: map[string]bool{}}
+	ingest, err := NewIngest(dir, index, maxSize, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ingest, index, dir
+}
+
+func TestIngestUploadsInChunks(t *testing.T) {
+	ingest, index, dir := newIngestFixture(t, 0)
+
+	result, err := ingest.Upload(Chunk{Name: "doc.txt", Content: []byte("parallel ")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UploadId == "" || result.Size != 9 || result.FilePath != "" {
+		t.Fatalf("unexpected result of the first chunk: %+v", result)
+	}
+
+	_, err = ingest.Upload(Chunk{UploadId: result.UploadId, Content: []byte("x"), Offset: 4})
+	if !errors.Is(err, ErrUploadOffset) {
+		t.Errorf("expected ErrUploadOffset, got %v", err)
+	}
+
+	filePath := filepath.Join(dir, "doc.txt")
+	if _, err = os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("document should not exist before the final chunk, got %v", err)
+	}
+
+	result, err = ingest.Upload(Chunk{UploadId: result.UploadId, Content: []byte("course"), Offset: 9, Final: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FilePath != filePath || result.Size != 15 {
+		t.Errorf("unexpected result of the final chunk: %+v", result)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil || string(content) != "parallel course" {
+		t.Errorf("unexpected content %q: %v", content, err)
+	}
+	if !index.HasFileProcessed(filePath) {
+		t.Error("uploaded document should be indexed")
+	}
+
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, uploadPrefix+"*")); len(leftovers) != 0 {
+		t.Errorf("temporary files should be removed, got %v", leftovers)
+	}
+
+	if _, err = ingest.Upload(Chunk{UploadId: result.UploadId, Content: []byte("x"), Offset: 15}); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("finished upload: expected ErrUploadNotFound, got %v", err)
+	}
+}
+
+func TestIngestRejectsDuplicatesUnlessOverwrite(t *testing.T) {
+	ingest, index, dir := newIngestFixture(t, 0)
+	filePath := filepath.Join(dir, "doc.txt")
+
+	if _, err := ingest.Upload(Chunk{Name: "doc.txt", Content: []byte("first"), Final: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ingest.Upload(Chunk{Name: "doc.txt", Content: []byte("second"), Final: true})
+	if !errors.Is(err, ErrDocumentExists) {
+		t.Errorf("expected ErrDocumentExists, got %v", err)
+	}
+
+	// Both uploads start before either is stored, the later one loses.
+	first, _ := ingest.Upload(Chunk{Name: "race.txt", Content: []byte("first")})
+	second, _ := ingest.Upload(Chunk{Name: "race.txt", Content: []byte("second")})
+	if _, err = ingest.Upload(Chunk{UploadId: first.UploadId, Offset: 5, Final: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ingest.Upload(Chunk{UploadId: second.UploadId, Offset: 6, Final: true}); !errors.Is(err, ErrDocumentExists) {
+		t.Errorf("concurrent duplicate: expected ErrDocumentExists, got %v", err)
+	}
+
+	_, err = ingest.Upload(Chunk{Name: "doc.txt", Content: []byte("second"), Final: true, Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(filePath)
+	if string(content) != "second" {
+		t.Errorf("expected the document to be replaced, got %q", content)
+	}
+	if !index.HasFileProcessed(filePath) {
+		t.Error("replaced document should be indexed")
+	}
+}
+
+func TestIngestValidatesUploads(t *testing.T) {
+	ingest, _, _ := newIngestFixture(t, 8)
+
+	for _, name := range []string{"", ".hidden", "../escape.txt", "dir/doc.txt", `dir\doc.txt`, ".."} {
+		if _, err := ingest.Upload(Chunk{Name: name, Final: true}); !errors.Is(err, ErrInvalidDocumentName) {
+			t.Errorf("name %q: expected ErrInvalidDocumentName, got %v", name, err)
+		}
+	}
+
+	if _, err := ingest.Upload(Chunk{UploadId: "missing"}); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("expected ErrUploadNotFound, got %v", err)
+	}
+
+	result, err := ingest.Upload(Chunk{Name: "big.txt", Content: []byte("12345")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ingest.Upload(Chunk{UploadId: result.UploadId, Content: []byte("6789"), Offset: 5})
+	if !errors.Is(err, ErrUploadTooLarge) {
+		t.Errorf("expected ErrUploadTooLarge, got %v", err)
+	}
+}
+
+func TestIngestOverwriteRemovesOldWords(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	index := invertedIdx.New(fileManager.New(logs), logs)
+	ingest, err := NewIngest(dir, index, 0, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filePath := filepath.Join(dir, "doc.txt")
+	if _, err = ingest.Upload(Chunk{Name: "doc.txt", Content: []byte("zanzibar shared"), Final: true}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ingest.Upload(Chunk{Name: "doc.txt", Content: []byte("quokka shared"), Final: true, Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := index.Search("zanzibar"); len(result) != 0 {
+		t.Errorf("words of the replaced content should not be found, got %v", result)
+	}
+	for _, query := range []string{"quokka", "shared"} {
+		if result := index.Search(query); !slices.Equal(result, []string{filePath}) {
+			t.Errorf("%v: expected %v, got %v", query, filePath, result)
+		}
+	}
+}
+
+func TestIngestLimitsUploadsInProgress(t *testing.T) {
+	ingest, _, _ := newIngestFixture(t, 0)
+	ingest.maxUploads = 1
+	ingest.maxPendingSize = 8
+
+	first, err := ingest.Upload(Chunk{Name: "first.txt", Content: []byte("12345")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ingest.Upload(Chunk{Name: "second.txt", Content: []byte("1")}); !errors.Is(err, ErrTooManyUploads) {
+		t.Errorf("expected ErrTooManyUploads, got %v", err)
+	}
+
+	// The refused chunk leaves the upload as it was, a smaller one fits.
+	_, err = ingest.Upload(Chunk{UploadId: first.UploadId, Content: []byte("6789"), Offset: 5})
+	if !errors.Is(err, ErrUploadsTooLarge) {
+		t.Errorf("expected ErrUploadsTooLarge, got %v", err)
+	}
+	if _, err = ingest.Upload(Chunk{UploadId: first.UploadId, Content: []byte("678"), Offset: 5, Final: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ingest.Upload(Chunk{Name: "second.txt", Content: []byte("12345678"), Final: true}); err != nil {
+		t.Errorf("finished uploads should free their place, got %v", err)
+	}
+}
+
+// scanningIndex runs beforeAdd once, just before it indexes a file.
+type scanningIndex struct {
+	*invertedIdx.InvertedIndex
+	beforeAdd func()
+}
+
+func (index *scanningIndex) AddFile(filePath string) error {
+	if beforeAdd := index.beforeAdd; beforeAdd != nil {
+		index.beforeAdd = nil
+		beforeAdd()
+	}
+
+	return index.InvertedIndex.AddFile(filePath)
+}
+
+func TestIngestLeavesStoredDocumentToUpload(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "uploads")
+	manager := fileManager.New(logs)
+	index := &scanningIndex{InvertedIndex: invertedIdx.New(manager, logs)}
+	ingest, err := NewIngest(dir, index, 0, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	monitor := NewSchedulerService(index, manager, nil, logs)
+	monitor.SetUploadTracker(ingest)
+
+	// The scan runs once the document has its name but before the upload
+	// indexed it, which is when the scheduler used to index it first.
+	filePath := filepath.Join(dir, "doc.txt")
+	for _, chunk := range []Chunk{
+		{Name: "doc.txt", Content: []byte("zanzibar"), Final: true},
+		{Name: "doc.txt", Content: []byte("quokka"), Final: true, Overwrite: true},
+	} {
+		index.beforeAdd = func() { _ = monitor.scanDir(context.Background(), root) }
+		if _, err = ingest.Upload(chunk); err != nil {
+			t.Fatalf("%s: %v", chunk.Content, err)
+		}
+	}
+
+	if result := index.Search("zanzibar"); len(result) != 0 {
+		t.Errorf("words of the replaced content should not be found, got %v", result)
+	}
+	if result := index.Search("quokka"); !slices.Equal(result, []string{filePath}) {
+		t.Errorf("expected %v, got %v", filePath, result)
+	}
+}